@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/validate"
+)
+
+// alertFlags are the flags of the chats, that are alerted about problems of a run: too many failed
+// clinics, validation errors and vanished clinics, so the data owner learns about them without
+// reading cron mail.
+type alertFlags struct {
+	telegramToken *string
+	telegramChat  *string
+	slackToken    *string
+	slackChannel  *string
+}
+
+func newAlertFlags(fs *flag.FlagSet) *alertFlags {
+	return &alertFlags{
+		telegramToken: fs.String("telegram-token", "", "Telegram bot token to alert about problems of a run with"),
+		telegramChat:  fs.String("telegram-chat", "", "Telegram chat ID or @channel to send alerts to"),
+		slackToken:    fs.String("slack-token", "", "Slack bot token to alert about problems of a run with"),
+		slackChannel:  fs.String("slack-channel", "", "Slack channel to send alerts to, e.g. #vtb-dms"),
+	}
+}
+
+// alerter sends an alert into a chat.
+type alerter interface {
+	Alert(text string) error
+}
+
+// alerters returns the configured chats.
+func (f *alertFlags) alerters() ([]alerter, error) {
+	var list []alerter
+	if *f.telegramToken != "" || *f.telegramChat != "" {
+		if *f.telegramToken == "" || *f.telegramChat == "" {
+			return nil, fmt.Errorf("telegram alerts require both -telegram-token and -telegram-chat")
+		}
+		list = append(list, &telegramAlerter{*f.telegramToken, *f.telegramChat})
+	}
+	if *f.slackToken != "" || *f.slackChannel != "" {
+		if *f.slackToken == "" || *f.slackChannel == "" {
+			return nil, fmt.Errorf("slack alerts require both -slack-token and -slack-channel")
+		}
+		list = append(list, &slackAlerter{*f.slackToken, *f.slackChannel})
+	}
+	return list, nil
+}
+
+// runProblems describes the problems of a run, or returns empty string, if there are none.
+// Clinics are validated, if they're known, i.e. the run wasn't streaming.
+func runProblems(summary *runSummary, clinics []*dmsparse.Clinic, runErr error) string {
+	var problems []string
+	// vanished clinics are listed below, even if there are few of them
+	if runErr != nil && exitCode(runErr) != exitVanished {
+		problems = append(problems, runErr.Error())
+	}
+	if clinics != nil {
+		report := validate.Run(clinics, validate.Rules)
+		var rules []string
+		for _, r := range report.Rules {
+			// failed clinics are checked against the failure policy instead
+			if r.Severity == validate.Error && r.Violations > 0 && r.Name != "no-coordinates" {
+				rules = append(rules, fmt.Sprintf("%s: %d", r.Name, r.Violations))
+			}
+		}
+		if len(rules) > 0 {
+			sort.Strings(rules)
+			problems = append(problems, "validation errors: "+strings.Join(rules, ", "))
+		}
+	}
+	if len(summary.Vanished) > 0 {
+		problems = append(problems, fmt.Sprintf("%d of %d clinics of the previous dataset vanished", len(summary.Vanished), summary.Previous))
+	}
+	if len(problems) == 0 {
+		return ""
+	}
+
+	host, _ := os.Hostname()
+	var b strings.Builder
+	fmt.Fprintf(&b, "gen_points on %s: parsed %d, geocoded %d, failed %d\n", host, summary.Parsed, summary.Geocoded, summary.Failed)
+	for _, p := range problems {
+		b.WriteString("• " + p + "\n")
+	}
+	const maxListed = 10
+	for i, cc := range summary.Vanished {
+		if i == maxListed {
+			fmt.Fprintf(&b, "  ... and %d more vanished\n", len(summary.Vanished)-i)
+			break
+		}
+		fmt.Fprintf(&b, "  - %s, %s\n", cc.Name, cc.RawAddress)
+	}
+	return b.String()
+}
+
+// sendAlerts sends the text to every chat. Errors are logged, as alerts must not fail the run.
+func sendAlerts(alerters []alerter, text string) {
+	for _, a := range alerters {
+		if err := a.Alert(text); err != nil {
+			slog.Error("could not send alert", "chat", fmt.Sprintf("%T", a), "err", err)
+		}
+	}
+}
+
+// telegramAlerter sends alerts with Telegram Bot API.
+type telegramAlerter struct {
+	token string
+	chat  string
+}
+
+func (t *telegramAlerter) Alert(text string) error {
+	var resp struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	body := map[string]interface{}{"chat_id": t.chat, "text": text, "disable_web_page_preview": true}
+	if err := postChatAPI("https://api.telegram.org/bot"+t.token+"/sendMessage", "", body, &resp); err != nil {
+		// the URL contains the token, which mustn't be logged
+		return fmt.Errorf("telegram: %v", strings.ReplaceAll(err.Error(), t.token, "***"))
+	}
+	if !resp.OK {
+		return fmt.Errorf("telegram: %s", resp.Description)
+	}
+	return nil
+}
+
+// slackAlerter sends alerts with Slack Web API.
+type slackAlerter struct {
+	token   string
+	channel string
+}
+
+func (s *slackAlerter) Alert(text string) error {
+	var resp struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	body := map[string]interface{}{"channel": s.channel, "text": text}
+	if err := postChatAPI("https://slack.com/api/chat.postMessage", s.token, body, &resp); err != nil {
+		return fmt.Errorf("slack: %v", err)
+	}
+	if !resp.OK {
+		return fmt.Errorf("slack: %s", resp.Error)
+	}
+	return nil
+}
+
+// postChatAPI posts json body to the chat's API, authorized with bearer token, if it's set, and
+// decodes the response into v. Both APIs report errors in the response, even with error statuses.
+func postChatAPI(url, token string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: notifyTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: %v", resp.Status, err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Scopes of API tokens: read allows querying the dataset, write allows changing it too.
+const (
+	scopeRead  = "read"
+	scopeWrite = "write"
+)
+
+// publicRoutes are served without a token, even when the server requires one.
+var publicRoutes = map[string]bool{
+	"/openapi.json": true,
+	"/review":       true,
+	"/metrics":      true,
+	"/healthz":      true,
+	"/readyz":       true,
+}
+
+// apiToken is a static bearer token and its scope.
+type apiToken struct {
+	secret []byte
+	scope  string
+}
+
+// parseTokens parses tokens in "secret:scope" form, where scope is read or write.
+func parseTokens(values []string) ([]apiToken, error) {
+	tokens := make([]apiToken, 0, len(values))
+	for _, v := range values {
+		n := strings.LastIndex(v, ":")
+		if n < 1 {
+			return nil, fmt.Errorf("token must be secret:scope, got %q", v)
+		}
+		secret, scope := v[:n], v[n+1:]
+		if scope != scopeRead && scope != scopeWrite {
+			return nil, fmt.Errorf("unknown token scope %q, must be %s or %s", scope, scopeRead, scopeWrite)
+		}
+		tokens = append(tokens, apiToken{[]byte(secret), scope})
+	}
+	return tokens, nil
+}
+
+// requireTokens wraps h, so requests must carry "Authorization: Bearer <secret>" of one of tokens.
+// Requests, that change the dataset, i.e. any POST except GraphQL queries, need a token of write
+// scope. If there are no tokens, h is returned as is.
+func requireTokens(h http.Handler, tokens []apiToken) http.Handler {
+	if len(tokens) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicRoutes[r.URL.Path] {
+			h.ServeHTTP(w, r)
+			return
+		}
+		need := scopeRead
+		if r.Method == http.MethodPost && r.URL.Path != "/graphql" {
+			need = scopeWrite
+		}
+
+		scope, ok := tokenScope(r, tokens)
+		switch {
+		case !ok:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gen_points"`)
+			writeErrorResponse(w, http.StatusUnauthorized, "missing or invalid token")
+		case need == scopeWrite && scope != scopeWrite:
+			writeErrorResponse(w, http.StatusForbidden, "token doesn't have write scope")
+		default:
+			h.ServeHTTP(w, r)
+		}
+	})
+}
+
+// tokenScope returns the scope of the request's bearer token.
+func tokenScope(r *http.Request, tokens []apiToken) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return "", false
+	}
+	secret := []byte(strings.TrimSpace(auth[7:]))
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare(secret, t.secret) == 1 {
+			return t.scope, true
+		}
+	}
+	return "", false
+}
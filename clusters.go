@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// clusterCellSize is the size of the grid cell in screen pixels, clinics within a cell are clustered.
+const clusterCellSize = 60
+
+// markerCluster is a group of clinics close to each other at the zoom level.
+type markerCluster struct {
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+	Count int     `json:"count"`
+	// ID is the ID of the clinic, if the cluster is a single clinic.
+	ID string `json:"id,omitempty"`
+	// BBox is [minLat, minLon, maxLat, maxLon] of the clinics, so the map can zoom in to the cluster.
+	BBox [4]float64 `json:"bbox"`
+}
+
+// handleClusters returns clinics within the viewport ?bbox=minLat,minLon,maxLat,maxLon clustered
+// for the map at ?zoom= level, using the grid of clusterCellSize pixels in Web Mercator projection.
+func (s *server) handleClusters(w http.ResponseWriter, r *http.Request) {
+	zoom, err := strconv.Atoi(r.URL.Query().Get("zoom"))
+	if err != nil || zoom < 0 || zoom > 23 {
+		writeErrorResponse(w, http.StatusBadRequest, "zoom must be from 0 to 23")
+		return
+	}
+	bbox, err := parseBBox(r.URL.Query().Get("bbox"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	data := s.data.Load()
+	var clinics []*dmsparse.Clinic
+	for _, id := range data.spatial.InBox(bbox[0], bbox[1], bbox[2], bbox[3]) {
+		clinics = append(clinics, data.located[id])
+	}
+	writeJSONResponse(w, http.StatusOK, clusterClinics(clinics, bbox, zoom))
+}
+
+// parseBBox parses bounding box in "minLat,minLon,maxLat,maxLon" form.
+func parseBBox(s string) ([4]float64, error) {
+	var bbox [4]float64
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return bbox, fmt.Errorf("bbox must be minLat,minLon,maxLat,maxLon")
+	}
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return bbox, fmt.Errorf("bbox must be minLat,minLon,maxLat,maxLon")
+		}
+		bbox[i] = v
+	}
+	if bbox[0] > bbox[2] || bbox[1] > bbox[3] {
+		return bbox, fmt.Errorf("bbox min must not be greater than max")
+	}
+	return bbox, nil
+}
+
+func inBBox(bbox [4]float64, lat, lon float64) bool {
+	return lat >= bbox[0] && lon >= bbox[1] && lat <= bbox[2] && lon <= bbox[3]
+}
+
+func clusterClinics(clinics []*dmsparse.Clinic, bbox [4]float64, zoom int) []*markerCluster {
+	type cell struct{ x, y int }
+	var (
+		cells  = make(map[cell]*markerCluster)
+		order  []cell
+		single = make(map[cell]string)
+	)
+	for _, cc := range clinics {
+		lat, lon, ok := cc.LatLon()
+		if !ok || !inBBox(bbox, lat, lon) {
+			continue
+		}
+		x, y := mercatorPixel(lat, lon, zoom)
+		c := cell{int(x / clusterCellSize), int(y / clusterCellSize)}
+		m, ok := cells[c]
+		if !ok {
+			m = &markerCluster{BBox: [4]float64{lat, lon, lat, lon}}
+			cells[c] = m
+			order = append(order, c)
+			single[c] = cc.ID
+		}
+		// running centroid of the clinics in the cell
+		m.Count++
+		m.Lat += (lat - m.Lat) / float64(m.Count)
+		m.Lon += (lon - m.Lon) / float64(m.Count)
+		m.BBox = [4]float64{math.Min(m.BBox[0], lat), math.Min(m.BBox[1], lon), math.Max(m.BBox[2], lat), math.Max(m.BBox[3], lon)}
+	}
+
+	clusters := make([]*markerCluster, 0, len(order))
+	for _, c := range order {
+		m := cells[c]
+		if m.Count == 1 {
+			m.ID = single[c]
+		}
+		clusters = append(clusters, m)
+	}
+	sort.SliceStable(clusters, func(i, j int) bool { return clusters[i].Count > clusters[j].Count })
+	return clusters
+}
+
+// mercatorPixel returns the point's pixel coordinates on the Web Mercator map at the zoom level.
+func mercatorPixel(lat, lon float64, zoom int) (x, y float64) {
+	size := 256 * math.Exp2(float64(zoom))
+	sin := math.Sin(lat * math.Pi / 180)
+	// clamp to the latitude range of Web Mercator, ~85.05°
+	sin = math.Max(-0.9999, math.Min(0.9999, sin))
+	x = (lon + 180) / 360 * size
+	y = (0.5 - math.Log((1+sin)/(1-sin))/(4*math.Pi)) * size
+	return x, y
+}
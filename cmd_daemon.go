@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// runDaemon implements "daemon" command, that serves the dataset as "serve" does, and rebuilds it
+// on schedule: it fetches and parses the source again, geocodes new and changed clinics, publishes
+// the outputs and swaps the served dataset. A failed build keeps the previous dataset served.
+func runDaemon(args []string) error {
+	fs := newFlagSet("daemon", "")
+	var (
+		addr        = fs.String("addr", ":8080", "address to listen on")
+		inf         = newInputFlags(fs, "path to source DMS text document or dataset", "text")
+		schedFlag   = fs.String("schedule", "@daily", `rebuild schedule: cron expression, e.g. "0 6 * * 1-5", or "@every 6h", "@hourly", "@daily"`)
+		buildsFile  = fs.String("builds", "", "path to append the record of each build to, as NDJSON")
+		ovf         = fs.String("overrides", "", "path to overrides yaml or csv file, whose points and fields take precedence over geocoder results; fixes made in review UI are saved there")
+		maxFailures = fs.String("max-failures", "10%", "don't publish a build, if more clinics failed geocoding, either a number or a percentage of clinics; -1 allows any")
+		maxVanished = fs.String("max-vanished", "5%", "don't publish a build, if more clinics of the served dataset vanished, either a number or a percentage of clinics; -1 allows any")
+		gf          = newGeocodeFlags(fs)
+		ef          = newExportFlags(fs)
+		af          = newAPIFlags(fs)
+	)
+	parseFlags(fs, args)
+
+	if inf.isStdin() {
+		return usageError(fmt.Errorf("can't rebuild from stdin"))
+	}
+	sched, err := parseSchedule(*schedFlag)
+	if err != nil {
+		return usageError(err)
+	}
+	policy, err := parseFailurePolicy(*maxFailures)
+	if err != nil {
+		return usageError(err)
+	}
+	vanishedPolicy, err := parseFailurePolicy(*maxVanished)
+	if err != nil {
+		return usageError(fmt.Errorf("invalid max vanished: %v", err))
+	}
+	g, err := gf.geocoder()
+	if err != nil {
+		return usageError(err)
+	}
+
+	var ov *overrides
+	if *ovf != "" {
+		if ov, err = loadOverrides(*ovf); err != nil {
+			return inputError(err)
+		}
+	}
+
+	s := newServer(g, ov)
+	h, err := af.handler(s)
+	if err != nil {
+		return usageError(err)
+	}
+	af.startDiagnostics()
+	d := &daemon{
+		server:     s,
+		geocoder:   g,
+		input:      inf,
+		export:     ef,
+		provider:   *gf.provider,
+		concurrent: *gf.concurrency,
+		policy:     policy,
+		vanished:   vanishedPolicy,
+		buildsFile: *buildsFile,
+	}
+
+	srv := &http.Server{
+		Addr:    *addr,
+		Handler: h,
+	}
+	// the daemon can't go on serving a dataset, that's never rebuilt again
+	schedErr := make(chan error, 1)
+	go func() {
+		schedErr <- d.run(sched)
+		srv.Close()
+	}()
+
+	slog.Info("serving", "addr", *addr)
+	err = listenAndServe(srv)
+	select {
+	case err := <-schedErr:
+		return err
+	default:
+	}
+	return err
+}
+
+type daemon struct {
+	server     *server
+	geocoder   *geocoder
+	input      *inputFlags
+	export     *exportFlags
+	provider   string
+	concurrent int
+	policy     failurePolicy
+	vanished   failurePolicy
+	buildsFile string
+
+	// checksum is the checksum of the source of the last published build.
+	checksum string
+}
+
+// run builds the dataset now and then on schedule. It returns only if the schedule has no next time.
+func (d *daemon) run(sched *schedule) error {
+	d.build()
+	for {
+		next := sched.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("schedule has no next build time")
+		}
+		slog.Info("next build scheduled", "at", next)
+		time.Sleep(time.Until(next))
+		d.build()
+	}
+}
+
+// buildRecord is the record of a daemon's build.
+type buildRecord struct {
+	StartedAt    time.Time `json:"started_at"`
+	SourceSHA256 string    `json:"source_sha256,omitempty"`
+	// Status is "published", "unchanged", if the source didn't change since the last published build, or "failed".
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	*runSummary
+}
+
+func (d *daemon) build() {
+	start := time.Now()
+	rec := &buildRecord{StartedAt: start.UTC()}
+	if err := d.rebuild(rec); err != nil {
+		rec.Status, rec.Error = "failed", err.Error()
+		slog.Error("build failed", "err", err)
+	} else {
+		slog.Info("build finished", "status", rec.Status, "duration", time.Since(start))
+	}
+	if err := d.record(rec); err != nil {
+		slog.Error("could not record build", "err", err)
+	}
+}
+
+func (d *daemon) rebuild(rec *buildRecord) error {
+	start := time.Now()
+	calls, hits := d.geocoder.Calls(), d.geocoder.CacheHits()
+
+	clinics, checksum, err := d.input.read()
+	if err != nil {
+		return err
+	}
+	rec.SourceSHA256 = checksum
+	if checksum == d.checksum {
+		rec.Status = "unchanged"
+		return nil
+	}
+
+	// clinics, that were already geocoded in the served dataset, aren't geocoded again
+	served := d.server.data.Load()
+	reusePoints(clinics, served.byID)
+	// overridden clinics aren't geocoded
+	if d.server.overrides != nil {
+		d.server.overrides.Apply(clinics)
+	}
+	geocodeClinics(d.geocoder, d.provider, d.concurrent, clinics, nil, nil)
+	if err := d.geocoder.Close(); err != nil {
+		return err
+	}
+
+	rec.runSummary = newRunSummary(clinics, d.geocoder.Calls()-calls, d.geocoder.CacheHits()-hits, time.Since(start))
+	if err := d.policy.Check(rec.Failed, rec.Parsed); err != nil {
+		return err
+	}
+	if err := checkVanished(rec.runSummary, served.clinics, clinics, d.vanished); err != nil {
+		return err
+	}
+
+	sortClinics(clinics)
+	if len(d.export.outFiles) > 0 {
+		opts, err := d.export.options()
+		if err != nil {
+			return err
+		}
+		opts.SourceSHA256 = checksum
+		opts.Provider = d.provider
+		if err := d.export.write(clinics, opts); err != nil {
+			return err
+		}
+	}
+
+	d.server.SetClinics(clinics)
+	d.checksum = checksum
+	rec.Status = "published"
+	return nil
+}
+
+func (d *daemon) record(rec *buildRecord) error {
+	if d.buildsFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(d.buildsFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
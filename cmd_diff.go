@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/narqo/vtb-dms/export"
+)
+
+// runDiff implements "diff old.json new.json" command.
+func runDiff(args []string) error {
+	fs := newFlagSet("diff", "old.json new.json")
+	threshold := fs.Float64("move-threshold", 50, "report clinics which points moved more than this distance, in meters")
+	parseFlags(fs, args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return usageError(fmt.Errorf("expected two datasets, got %d arguments", fs.NArg()))
+	}
+
+	oldClinics, err := export.ReadDataset(fs.Arg(0))
+	if err != nil {
+		return inputError(err)
+	}
+	newClinics, err := export.ReadDataset(fs.Arg(1))
+	if err != nil {
+		return inputError(err)
+	}
+
+	export.Diff(oldClinics, newClinics, *threshold).Print(os.Stdout)
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// runDistricts implements "districts" command, that groups clinics by administrative district of
+// their city, which the geocoder found, and reports the number of clinics per district along with
+// coverage gaps: districts with fewer than -min-clinics clinics, including the districts of -districts
+// list, which have none, so the insurance team knows, where new clinic contracts are needed.
+func runDistricts(args []string) error {
+	fs := newFlagSet("districts", "")
+	var (
+		inf        = newInputFlags(fs, "path to json, yaml or ndjson dataset", "ndjson")
+		format     = fs.String("format", "text", "report format: text or json")
+		outFile    = fs.String("out", "", "path to write the report to (default stdout)")
+		listFile   = fs.String("districts", "", `path to the list of districts to cover, one per line as "city; district", or just "district" of any city`)
+		minClinics = fs.Int("min-clinics", 1, "number of clinics, below which a district is a coverage gap")
+	)
+	parseFlags(fs, args)
+
+	if *format != "text" && *format != "json" {
+		return usageError(fmt.Errorf("unknown report format: %q", *format))
+	}
+	var list []districtKey
+	if *listFile != "" {
+		var err error
+		if list, err = readDistricts(*listFile); err != nil {
+			return inputError(err)
+		}
+	}
+
+	clinics, _, err := inf.read()
+	if err != nil {
+		return inputError(err)
+	}
+	report := newDistrictReport(clinics, list, *minClinics)
+
+	out := os.Stdout
+	if *outFile != "" && *outFile != "-" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			return outputError(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = printDistrictReport(out, report)
+	}
+	if err != nil {
+		return outputError(err)
+	}
+	return nil
+}
+
+type districtKey struct {
+	City     string
+	District string
+}
+
+// districtStat is the number of clinics in the district.
+type districtStat struct {
+	City     string `json:"city"`
+	District string `json:"district"`
+	Clinics  int    `json:"clinics"`
+	// Share is the share of the district's clinics among the clinics of its city.
+	Share float64 `json:"share"`
+	Gap   bool    `json:"gap"`
+}
+
+type districtReport struct {
+	Clinics   int             `json:"clinics"`
+	Districts []*districtStat `json:"districts"`
+	Gaps      int             `json:"gaps"`
+	// Unknown are the clinics, whose district isn't known, because they weren't geocoded, or were
+	// geocoded before districts were kept.
+	Unknown int `json:"unknown"`
+}
+
+func newDistrictReport(clinics []*dmsparse.Clinic, list []districtKey, minClinics int) *districtReport {
+	r := &districtReport{Clinics: len(clinics)}
+	stats := make(map[districtKey]*districtStat)
+	cities := make(map[string]int)
+	for _, cc := range clinics {
+		if cc.District == "" {
+			r.Unknown++
+			continue
+		}
+		k := districtKey{cc.City, cc.District}
+		st := stats[k]
+		if st == nil {
+			st = &districtStat{City: cc.City, District: cc.District}
+			stats[k] = st
+		}
+		st.Clinics++
+		cities[cc.City]++
+	}
+
+	// districts of the list without clinics are added; a district without a city matches any city
+	for _, k := range list {
+		found := false
+		for sk := range stats {
+			if strings.EqualFold(sk.District, k.District) && (k.City == "" || strings.EqualFold(sk.City, k.City)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			stats[k] = &districtStat{City: k.City, District: k.District}
+		}
+	}
+
+	for _, st := range stats {
+		if n := cities[st.City]; n > 0 {
+			st.Share = float64(st.Clinics) / float64(n)
+		}
+		if st.Clinics < minClinics {
+			st.Gap = true
+			r.Gaps++
+		}
+		r.Districts = append(r.Districts, st)
+	}
+	sort.Slice(r.Districts, func(i, j int) bool {
+		a, b := r.Districts[i], r.Districts[j]
+		if a.City != b.City {
+			return a.City < b.City
+		}
+		if a.Clinics != b.Clinics {
+			return a.Clinics > b.Clinics
+		}
+		return a.District < b.District
+	})
+	return r
+}
+
+// readDistricts reads the list of districts. Empty lines and lines starting with # are skipped.
+func readDistricts(path string) ([]districtKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list []districtKey
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var k districtKey
+		if city, district, ok := strings.Cut(line, ";"); ok {
+			k.City, k.District = strings.TrimSpace(city), strings.TrimSpace(district)
+		} else {
+			k.District = line
+		}
+		if k.District == "" {
+			return nil, fmt.Errorf("%s: no district in %q", path, line)
+		}
+		list = append(list, k)
+	}
+	return list, s.Err()
+}
+
+func printDistrictReport(w io.Writer, r *districtReport) error {
+	var covered int
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "CITY\tDISTRICT\tCLINICS\tSHARE\t\n")
+	for _, st := range r.Districts {
+		gap := ""
+		if st.Gap {
+			gap = "gap"
+		}
+		city := st.City
+		if city == "" {
+			city = "-"
+		}
+		if st.Clinics > 0 {
+			covered++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.0f%%\t%s\n", city, st.District, st.Clinics, st.Share*100, gap)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d clinics in %d districts, %d coverage gaps, %d clinics without district\n",
+		r.Clinics, covered, r.Gaps, r.Unknown)
+	return err
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/narqo/vtb-dms/validate"
+)
+
+// runDuplicates implements "duplicates" command, that finds clinics, which are likely the same clinic
+// with differently spelled addresses, e.g. coming from different source files, and suggests, which
+// of them to keep.
+func runDuplicates(args []string) error {
+	fs := newFlagSet("duplicates", "")
+	var (
+		inf        = newInputFlags(fs, "path to json, yaml or ndjson dataset", "ndjson")
+		format     = fs.String("format", "text", "output format: text or json")
+		outFile    = fs.String("out", "", "path to write merge suggestions to (default stdout)")
+		similarity = fs.Float64("min-similarity", validate.DuplicateSimilarity, "similarity of addresses from 0 to 1, from which clinics are considered duplicates")
+		distance   = fs.Float64("distance", validate.DuplicateDistance, "maximum distance between points of duplicates in meters")
+	)
+	parseFlags(fs, args)
+
+	if *format != "text" && *format != "json" {
+		return usageError(fmt.Errorf("unknown output format: %q", *format))
+	}
+
+	clinics, _, err := inf.read()
+	if err != nil {
+		return inputError(err)
+	}
+	sortClinics(clinics)
+	dups := validate.Duplicates(clinics, *similarity, *distance)
+
+	out := os.Stdout
+	if *outFile != "" && *outFile != "-" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			return outputError(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(dups)
+	} else {
+		err = printDuplicates(out, dups)
+	}
+	if err != nil {
+		return outputError(err)
+	}
+	return nil
+}
+
+func printDuplicates(w io.Writer, dups []validate.Duplicate) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "SIMILARITY\tDISTANCE\tKEEP\tMERGE\n")
+	for _, d := range dups {
+		dist := "-"
+		if d.Distance >= 0 {
+			dist = fmt.Sprintf("%.0fm", d.Distance)
+		}
+		fmt.Fprintf(tw, "%.2f\t%s\t%s %s, %s\t%s %s, %s\n", d.Similarity, dist,
+			d.KeepID, d.Keep.Name, d.Keep.RawAddress, d.MergeID, d.Merge.Name, d.Merge.RawAddress)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d merge suggestions\n", len(dups))
+	return err
+}
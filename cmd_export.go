@@ -0,0 +1,29 @@
+package main
+
+// runExport implements "export" command, that converts a dataset into output formats without geocoding it.
+func runExport(args []string) error {
+	fs := newFlagSet("export", "")
+	var (
+		inf = newInputFlags(fs, "path to json, yaml or ndjson dataset", "ndjson")
+		ef  = newExportFlags(fs)
+	)
+	parseFlags(fs, args)
+
+	clinics, checksum, err := inf.read()
+	if err != nil {
+		return inputError(err)
+	}
+
+	opts, err := ef.options()
+	if err != nil {
+		return inputError(err)
+	}
+	opts.SourceSHA256 = checksum
+
+	sortClinics(clinics)
+
+	if err := ef.write(clinics, opts); err != nil {
+		return outputError(err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// runNormalize implements "normalize" command, that cleans up clinics of a dataset: collapses
+// repeated whitespace, joins phones uniformly, fills in missing IDs and drops blank clinics.
+func runNormalize(args []string) error {
+	fs := newFlagSet("normalize", "")
+	var (
+		inf = newInputFlags(fs, "path to json, yaml or ndjson dataset", "ndjson")
+		ef  = newExportFlags(fs)
+	)
+	parseFlags(fs, args)
+
+	clinics, checksum, err := inf.read()
+	if err != nil {
+		return inputError(err)
+	}
+
+	opts, err := ef.options()
+	if err != nil {
+		return inputError(err)
+	}
+	opts.SourceSHA256 = checksum
+
+	n := 0
+	for _, cc := range clinics {
+		dmsparse.Normalize(cc)
+		if cc.Name == "" && cc.RawAddress == "" {
+			continue
+		}
+		clinics[n] = cc
+		n++
+	}
+	clinics = clinics[:n]
+
+	if err := ef.write(clinics, opts); err != nil {
+		return outputError(err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/narqo/vtb-dms/export"
+)
+
+// runWithStdio runs the command with stdin read from input and returns what it wrote to stdout.
+func runWithStdio(t *testing.T, run func(args []string) error, args []string, input string) string {
+	t.Helper()
+	dir := t.TempDir()
+	in := filepath.Join(dir, "stdin")
+	if err := os.WriteFile(in, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdin, err := os.Open(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdin, stdout
+	defer func() { os.Stdin, os.Stdout = oldStdin, oldStdout }()
+	if err := run(args); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestParseNormalizePipeline(t *testing.T) {
+	const doc = `1. "Поликлиника"
+ООО  "ДИРЕКЦИЯ"
+г. Москва, ул.Новая Басманная, д.10
+8 (495) 925-88-78
+
+ООО "Лечебный центр"
+г. Москва, ул. Тимура Фрунзе, д. 15/1
+8 (495) 786-45-25, 8 (495) 786-45-20
+
+`
+	parsed := runWithStdio(t, runParse, nil, doc)
+	normalized := runWithStdio(t, runNormalize, nil, parsed)
+
+	clinics, err := export.ReadNDJSON(strings.NewReader(normalized))
+	if err != nil {
+		t.Fatalf("normalize output isn't ndjson: %v\n%s", err, normalized)
+	}
+	if len(clinics) != 2 {
+		t.Fatalf("got %d clinics, want 2:\n%s", len(clinics), normalized)
+	}
+	if got, want := clinics[0].Name, `ООО "ДИРЕКЦИЯ"`; got != want {
+		t.Errorf("name: got %q, want %q", got, want)
+	}
+}
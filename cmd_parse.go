@@ -0,0 +1,27 @@
+package main
+
+// runParse implements "parse" command, that parses DMS text document into a dataset without geocoding it.
+func runParse(args []string) error {
+	fs := newFlagSet("parse", "")
+	var (
+		inf = newInputFlags(fs, "path to DMS text document", "text")
+		ef  = newExportFlags(fs)
+	)
+	parseFlags(fs, args)
+
+	clinics, checksum, err := inf.read()
+	if err != nil {
+		return inputError(err)
+	}
+
+	opts, err := ef.options()
+	if err != nil {
+		return inputError(err)
+	}
+	opts.SourceSHA256 = checksum
+
+	if err := ef.write(clinics, opts); err != nil {
+		return outputError(err)
+	}
+	return nil
+}
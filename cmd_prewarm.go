@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/narqo/vtb-dms/geocode"
+)
+
+// maxPrewarmFailures is the number of failed requests in a row, after which prewarm stops, as
+// the provider is likely out of quota.
+const maxPrewarmFailures = 10
+
+// runPrewarm implements "prewarm" command, that geocodes addresses into the cache at a low rate,
+// e.g. during off-peak hours, so the following build of the dataset is resolved from the cache.
+// Addresses, that are already cached, are skipped, so prewarm can be interrupted and run again.
+func runPrewarm(args []string) error {
+	fs := newFlagSet("prewarm", "")
+	var (
+		inf         = newInputFlags(fs, "path to DMS text document or dataset, whose addresses to geocode", "text")
+		addrFile    = fs.String("addresses", "", "path to text file with an address per line to geocode instead of -in, or - for stdin")
+		rate        = fs.Float64("rate", 1, "requests per second")
+		maxRequests = fs.Int("max-requests", 0, "stop after this many requests, e.g. to keep within the daily quota; 0 means no limit")
+		duration    = fs.Duration("for", 0, "stop after this time, e.g. before peak hours; 0 means no limit")
+		saveEvery   = fs.Duration("save-interval", time.Minute, "how often to save the cache, so an interrupted prewarm keeps its progress")
+		gf          = newGeocodeFlags(fs)
+	)
+	parseFlags(fs, args)
+
+	if *gf.cache == "" {
+		return usageError(fmt.Errorf("prewarm requires -cache"))
+	}
+	if *rate <= 0 {
+		return usageError(fmt.Errorf("invalid rate: %g", *rate))
+	}
+	g, err := gf.geocoder()
+	if err != nil {
+		return usageError(err)
+	}
+
+	var addresses []string
+	if *addrFile != "" {
+		addresses, err = readAddresses(*addrFile)
+	} else {
+		addresses, err = datasetAddresses(inf)
+	}
+	if err != nil {
+		return inputError(err)
+	}
+
+	var (
+		pending []string
+		seen    = make(map[string]bool)
+	)
+	for _, addr := range addresses {
+		key := geocode.CacheKey(addr)
+		if seen[key] || g.cache.Cached(addr) {
+			continue
+		}
+		seen[key] = true
+		pending = append(pending, addr)
+	}
+	slog.Info("prewarming cache", "addresses", len(addresses), "pending", len(pending), "rate", *rate)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	var (
+		ticker   = time.NewTicker(time.Duration(float64(time.Second) / *rate))
+		lastSave = time.Now()
+		done     int
+		failed   int
+		inRow    int
+	)
+	defer ticker.Stop()
+loop:
+	for _, addr := range pending {
+		if *maxRequests > 0 && done+failed >= *maxRequests {
+			slog.Info("reached max requests", "requests", done+failed)
+			break
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("stopping prewarm", "reason", context.Cause(ctx))
+			break loop
+		case <-ticker.C:
+		}
+
+		if _, err := g.Geocode(addr); err != nil {
+			slog.Warn("could not geocode address", "address", addr, "err", err)
+			failed++
+			if inRow++; inRow == maxPrewarmFailures {
+				slog.Error("too many failed requests in a row, provider may be out of quota", "failed", inRow)
+				break
+			}
+			continue
+		}
+		done++
+		inRow = 0
+		if time.Since(lastSave) >= *saveEvery {
+			if err := g.Close(); err != nil {
+				return outputError(fmt.Errorf("could not save geocoder cache: %v", err))
+			}
+			lastSave = time.Now()
+		}
+	}
+
+	if err := g.Close(); err != nil {
+		return outputError(fmt.Errorf("could not save geocoder cache: %v", err))
+	}
+	fmt.Fprintf(os.Stderr, "geocoded %d, failed %d, left %d of %d addresses not in cache\n",
+		done, failed, len(pending)-done-failed, len(pending))
+	return nil
+}
+
+// readAddresses reads addresses from the text file, one per line. Blank lines are skipped.
+func readAddresses(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var addresses []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if addr := strings.TrimSpace(sc.Text()); addr != "" {
+			addresses = append(addresses, addr)
+		}
+	}
+	return addresses, sc.Err()
+}
+
+// datasetAddresses returns raw addresses of clinics of the input, which aren't geocoded yet.
+func datasetAddresses(inf *inputFlags) ([]string, error) {
+	clinics, _, err := inf.read()
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, cc := range clinics {
+		if _, _, ok := cc.LatLon(); !ok && cc.RawAddress != "" {
+			addresses = append(addresses, cc.RawAddress)
+		}
+	}
+	return addresses, nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/spatial"
+)
+
+// runRoute implements "route" command, that orders the clinics of a site inspection trip into a short
+// visiting route from the start point, and writes it as GPX, for navigators, or GeoJSON, for maps.
+// Distances are straight-line, so the route is a plan of the order of visits, not of the roads.
+func runRoute(args []string) error {
+	fs := newFlagSet("route", "")
+	var (
+		inf       = newInputFlags(fs, "path to json, yaml or ndjson dataset", "ndjson")
+		ids       = fs.String("ids", "", "comma-separated IDs of clinics to visit")
+		idsFile   = fs.String("ids-file", "", "path to IDs of clinics to visit, one per line")
+		from      = fs.String("from", "", `start point of the route as "lat,lon", e.g. the office`)
+		roundTrip = fs.Bool("round-trip", false, "return to the start point at the end of the route")
+		format    = fs.String("format", "gpx", "route format: gpx or geojson")
+		outFile   = fs.String("out", "", "path to write the route to (default stdout)")
+	)
+	parseFlags(fs, args)
+
+	if *format != "gpx" && *format != "geojson" {
+		return usageError(fmt.Errorf("unknown route format: %q", *format))
+	}
+	var start dmsparse.Point
+	if n, err := fmt.Sscanf(*from, "%g,%g", &start.Lat, &start.Lon); err != nil || n != 2 {
+		return usageError(fmt.Errorf("invalid -from %q, expected lat,lon", *from))
+	}
+	list := splitIDs(*ids)
+	if *idsFile != "" {
+		fromFile, err := readIDs(*idsFile)
+		if err != nil {
+			return inputError(err)
+		}
+		list = append(list, fromFile...)
+	}
+	if len(list) == 0 {
+		return usageError(fmt.Errorf("-ids or -ids-file is required"))
+	}
+
+	clinics, _, err := inf.read()
+	if err != nil {
+		return inputError(err)
+	}
+	stops, err := routeClinics(clinics, list)
+	if err != nil {
+		return inputError(err)
+	}
+
+	lats, lons := make([]float64, len(stops)), make([]float64, len(stops))
+	for i, cc := range stops {
+		lats[i], lons[i], _ = cc.LatLon()
+	}
+	order, length := spatial.Route(start.Lat, start.Lon, lats, lons, *roundTrip)
+	route := make([]*dmsparse.Clinic, len(order))
+	for i, j := range order {
+		route[i] = stops[j]
+	}
+	slog.Info("planned route", "stops", len(route), "km", fmt.Sprintf("%.1f", length/1000))
+
+	out := os.Stdout
+	if *outFile != "" && *outFile != "-" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			return outputError(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if *format == "gpx" {
+		err = writeRouteGPX(out, start, route, *roundTrip)
+	} else {
+		err = writeRouteGeoJSON(out, start, route, *roundTrip)
+	}
+	if err != nil {
+		return outputError(err)
+	}
+	return nil
+}
+
+func splitIDs(s string) []string {
+	var ids []string
+	for _, id := range strings.Split(s, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// readIDs reads IDs, one per line. Empty lines and lines starting with # are skipped.
+func readIDs(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ids []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids = append(ids, line)
+	}
+	return ids, s.Err()
+}
+
+// routeClinics returns the clinics of the IDs, each once. All of them must be in the dataset and be geocoded.
+func routeClinics(clinics []*dmsparse.Clinic, ids []string) ([]*dmsparse.Clinic, error) {
+	byID := make(map[string]*dmsparse.Clinic, len(clinics))
+	for _, cc := range clinics {
+		byID[cc.ID] = cc
+	}
+	var (
+		stops               []*dmsparse.Clinic
+		seen                = make(map[string]bool)
+		unknown, ungeocoded []string
+	)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		cc, ok := byID[id]
+		if !ok {
+			unknown = append(unknown, id)
+			continue
+		}
+		if _, _, ok := cc.LatLon(); !ok {
+			ungeocoded = append(ungeocoded, id)
+			continue
+		}
+		stops = append(stops, cc)
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("no clinics %s in dataset", strings.Join(unknown, ", "))
+	}
+	if len(ungeocoded) > 0 {
+		return nil, fmt.Errorf("clinics %s aren't geocoded", strings.Join(ungeocoded, ", "))
+	}
+	return stops, nil
+}
+
+// routeStopName is the name of the stop, prefixed with its number in the route, e.g. "3. Клиника".
+func routeStopName(n int, cc *dmsparse.Clinic) string {
+	return fmt.Sprintf("%d. %s", n, cc.Name)
+}
+
+func routeStopAddress(cc *dmsparse.Clinic) string {
+	if cc.Address != "" {
+		return cc.Address
+	}
+	return cc.RawAddress
+}
+
+// writeRouteGPX writes the route as GPX 1.1 with a waypoint per stop and the route through them.
+func writeRouteGPX(w io.Writer, start dmsparse.Point, route []*dmsparse.Clinic, roundTrip bool) error {
+	type gpxPoint struct {
+		Lat  float64 `xml:"lat,attr"`
+		Lon  float64 `xml:"lon,attr"`
+		Name string  `xml:"name,omitempty"`
+		Desc string  `xml:"desc,omitempty"`
+	}
+	doc := struct {
+		XMLName   xml.Name   `xml:"gpx"`
+		Version   string     `xml:"version,attr"`
+		Creator   string     `xml:"creator,attr"`
+		Xmlns     string     `xml:"xmlns,attr"`
+		Waypoints []gpxPoint `xml:"wpt"`
+		Route     []gpxPoint `xml:"rte>rtept"`
+	}{
+		Version: "1.1",
+		Creator: "gen_points",
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+	}
+	startPoint := gpxPoint{Lat: start.Lat, Lon: start.Lon, Name: "Start"}
+	doc.Route = append(doc.Route, startPoint)
+	for i, cc := range route {
+		lat, lon, _ := cc.LatLon()
+		p := gpxPoint{Lat: lat, Lon: lon, Name: routeStopName(i+1, cc), Desc: routeStopAddress(cc)}
+		if cc.Phone != "" {
+			p.Desc += "; " + cc.Phone
+		}
+		doc.Waypoints = append(doc.Waypoints, p)
+		doc.Route = append(doc.Route, p)
+	}
+	if roundTrip {
+		doc.Route = append(doc.Route, startPoint)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeRouteGeoJSON writes the route as GeoJSON FeatureCollection of the route's LineString, followed
+// by a Point per stop with its number in the route.
+func writeRouteGeoJSON(w io.Writer, start dmsparse.Point, route []*dmsparse.Clinic, roundTrip bool) error {
+	type feature struct {
+		Type       string                 `json:"type"`
+		ID         string                 `json:"id,omitempty"`
+		Geometry   map[string]interface{} `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+	line := [][2]float64{{start.Lon, start.Lat}}
+	var stops []feature
+	for i, cc := range route {
+		lat, lon, _ := cc.LatLon()
+		line = append(line, [2]float64{lon, lat})
+		stops = append(stops, feature{
+			Type:     "Feature",
+			ID:       cc.ID,
+			Geometry: map[string]interface{}{"type": "Point", "coordinates": [2]float64{lon, lat}},
+			Properties: map[string]interface{}{
+				"stop":    i + 1,
+				"name":    cc.Name,
+				"address": routeStopAddress(cc),
+				"phone":   cc.Phone,
+			},
+		})
+	}
+	if roundTrip {
+		line = append(line, [2]float64{start.Lon, start.Lat})
+	}
+	fc := struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}{
+		Type: "FeatureCollection",
+		Features: append([]feature{{
+			Type:       "Feature",
+			Geometry:   map[string]interface{}{"type": "LineString", "coordinates": line},
+			Properties: map[string]interface{}{"stops": len(route), "round_trip": roundTrip},
+		}}, stops...),
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(fc)
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/export"
+)
+
+// runPipeline implements "run" command, that parses, geocodes and exports clinics in one pass.
+func runPipeline(args []string) error {
+	return geocodeCommand("run", "text", args)
+}
+
+// runGeocode implements "geocode" command, that geocodes clinics of a dataset, produced by "parse"
+// or by a previous run. Clinics, which already have points, aren't geocoded again.
+func runGeocode(args []string) error {
+	return geocodeCommand("geocode", "ndjson", args)
+}
+
+func geocodeCommand(name, stdinFormat string, args []string) error {
+	fs := newFlagSet(name, "")
+	var (
+		inf          = newInputFlags(fs, "path to input file: DMS text document or json, yaml or ndjson dataset", stdinFormat)
+		streamFile   = fs.String("stream", "", "path to write clinics as NDJSON as soon as they are geocoded")
+		summaryFile  = fs.String("summary", "", "path to write run summary as json")
+		metricsFile  = fs.String("metrics-file", "", "path to write Prometheus metrics in text format, e.g. for node_exporter textfile collector")
+		progressMode = fs.String("progress", "auto", "progress reporting: bar, log, none or auto (bar if stderr is a terminal, log otherwise)")
+		watchMode    = fs.Bool("watch", false, "watch input for changes and run again on each change, until interrupted")
+		watchPoll    = fs.Duration("watch-interval", time.Second, "how often to check input for changes in -watch mode")
+		maxFailures  = fs.String("max-failures", "10%", "fail with exit code 4 if more clinics failed geocoding, either a number or a percentage of clinics; -1 allows any")
+		maxVanished  = fs.String("max-vanished", "5%", "fail with exit code 7 without writing outputs, if more clinics of -prev dataset vanished, either a number or a percentage of clinics; -1 allows any")
+		ovf          = fs.String("overrides", "", "path to overrides yaml or csv file, whose points and fields take precedence over geocoder results")
+		mergeFile    = fs.String("merge", "", "path to existing json, yaml or ndjson dataset to fold the clinics into: the existing clinics are updated field by field and kept, new ones are appended, so the dataset can be maintained incrementally; the existing points are reused, rather than geocoded again")
+		mergePolicy  = fs.String("merge-conflict", "new", "which of the fields, set in both -merge dataset and the input, but differing, win: new, existing, or fail to fail the run; manually set points and -overrides are always kept")
+		reviewFile   = fs.String("review-csv", "", "path to write clinics with confidence below -review-threshold as CSV for manual review")
+		reviewBelow  = fs.Float64("review-threshold", 0.6, "confidence, below which clinics are written to -review-csv")
+		streaming    = fs.Bool("streaming", false, "read, geocode and write clinics one by one with bounded memory, e.g. for national datasets; input must be ndjson or text, output a single local ndjson file, clinics aren't sorted")
+		notifyURL    = fs.String("notify-url", "", "URL to POST json notification with the run summary, dataset version and checksums of outputs to, when a run finishes")
+		eventsSink   = fs.String("events", "", "sink to emit added, updated and removed clinics since -prev dataset to: path of NDJSON file to append to, - for stdout, webhook URL, or kafka+http(s)://rest-proxy/topics/<topic> for Kafka REST Proxy")
+		estimate     = fs.Bool("estimate", false, "report the number of geocoder requests the run would make per provider, after cache and already geocoded clinics are accounted for, and exit without making any")
+		gf           = newGeocodeFlags(fs)
+		nf           = newEnrichFlags(fs)
+		af           = newAlertFlags(fs)
+		ef           = newExportFlags(fs)
+	)
+	parseFlags(fs, args)
+
+	policy, err := parseFailurePolicy(*maxFailures)
+	if err != nil {
+		return usageError(err)
+	}
+	vanishedPolicy, err := parseFailurePolicy(*maxVanished)
+	if err != nil {
+		return usageError(fmt.Errorf("invalid max vanished: %v", err))
+	}
+	geocoder, err := gf.geocoder()
+	if err != nil {
+		return usageError(err)
+	}
+	if *streaming && (*watchMode || *estimate || *streamFile != "" || *reviewFile != "" || *ef.prevFile != "" || *mergeFile != "") {
+		return usageError(fmt.Errorf("-streaming doesn't support -watch, -estimate, -stream, -review-csv, -prev and -merge"))
+	}
+	if !slices.Contains(mergePolicies, *mergePolicy) {
+		return usageError(fmt.Errorf("unknown -merge-conflict %q, expected one of %s", *mergePolicy, strings.Join(mergePolicies, ", ")))
+	}
+	if *eventsSink != "" && *ef.prevFile == "" {
+		return usageError(fmt.Errorf("-events requires -prev dataset to compare clinics with"))
+	}
+	alerters, err := af.alerters()
+	if err != nil {
+		return usageError(err)
+	}
+	sources, err := nf.sources()
+	if err != nil {
+		return inputError(err)
+	}
+	categories, err := nf.classifier()
+	if err != nil {
+		return inputError(err)
+	}
+	if *streaming && len(sources) > 0 {
+		return usageError(fmt.Errorf("-streaming doesn't support enrichment"))
+	}
+
+	// prev are clinics of the previous run in -watch mode, so unchanged clinics aren't geocoded again,
+	// even without -cache
+	var prev map[string]*dmsparse.Clinic
+
+	// report notifies -notify-url and alerts the chats about the finished run. Clinics are nil, if
+	// the run was streaming.
+	report := func(summary *runSummary, clinics []*dmsparse.Clinic, checksum string, artifacts []artifact, runErr error) {
+		if *notifyURL != "" {
+			var version string
+			if clinics != nil {
+				version = datasetHash(clinics)
+			}
+			notifyWebhook(*notifyURL, newRunNotification(summary, version, checksum, artifacts, runErr))
+		}
+		if len(alerters) > 0 {
+			if text := runProblems(summary, clinics, runErr); text != "" {
+				sendAlerts(alerters, text)
+			}
+		}
+	}
+
+	// finish reports the summary of the run and checks it against the failure policy
+	finish := func(summary *runSummary, clinics []*dmsparse.Clinic, checksum string) error {
+		summary.Print(os.Stderr)
+		if *summaryFile != "" {
+			if err := summary.WriteFile(*summaryFile); err != nil {
+				return outputError(err)
+			}
+		}
+		if *metricsFile != "" {
+			if err := metrics.WriteFile(*metricsFile); err != nil {
+				return outputError(err)
+			}
+		}
+		err := policy.Check(summary.Failed, summary.Parsed)
+		report(summary, clinics, checksum, ef.artifacts(), err)
+		return err
+	}
+
+	runOnce := func() error {
+		startTime := time.Now()
+
+		// overrides are read on each run, as they may change in -watch mode
+		var (
+			ov  *overrides
+			err error
+		)
+		if *ovf != "" {
+			if ov, err = loadOverrides(*ovf); err != nil {
+				return inputError(err)
+			}
+		}
+
+		if *streaming {
+			progress, err := newProgress(*progressMode, -1)
+			if err != nil {
+				return usageError(err)
+			}
+			summary, err := geocodeStreaming(inf, ef, geocoder, *gf.provider, *gf.concurrency, ov, categories, progress)
+			if err != nil {
+				return err
+			}
+			summary.WallTime = time.Since(startTime).Seconds()
+			return finish(summary, nil, "")
+		}
+
+		clinics, checksum, err := inf.read()
+		if err != nil {
+			return inputError(err)
+		}
+
+		opts, err := ef.options()
+		if err != nil {
+			return inputError(err)
+		}
+		opts.SourceSHA256 = checksum
+		opts.Provider = *gf.provider
+
+		if prev != nil {
+			reusePoints(clinics, prev)
+		}
+		var existing []*dmsparse.Clinic
+		if *mergeFile != "" {
+			if existing, err = export.ReadDataset(*mergeFile); err != nil {
+				return inputError(fmt.Errorf("could not read dataset to merge into: %v", err))
+			}
+			byID := make(map[string]*dmsparse.Clinic, len(existing))
+			for _, cc := range existing {
+				byID[cc.ID] = cc
+			}
+			reusePoints(clinics, byID)
+		}
+		// overridden clinics aren't geocoded
+		if ov != nil {
+			n := ov.Apply(clinics)
+			slog.Info("applied overrides", "clinics", n, "overrides", ov.Len())
+		}
+		// categories are by names, which overrides may fix
+		classifyClinics(categories, clinics)
+		if *estimate {
+			geocoder.estimate(*gf.provider, clinics).Print(os.Stdout)
+			return nil
+		}
+
+		progress, err := newProgress(*progressMode, countPending(clinics))
+		if err != nil {
+			return usageError(err)
+		}
+
+		var stream *export.StreamWriter
+		if *streamFile != "" {
+			stream, err = export.NewStreamWriter(*streamFile, opts)
+			if err != nil {
+				return outputError(err)
+			}
+		}
+
+		geocodeClinics(geocoder, *gf.provider, *gf.concurrency, clinics, stream, progress)
+
+		if err := stream.Close(); err != nil {
+			return outputError(err)
+		}
+		if err := geocoder.Close(); err != nil {
+			return outputError(fmt.Errorf("could not save geocoder cache: %v", err))
+		}
+		enrichClinics(sources, clinics, *gf.concurrency)
+		if prev != nil {
+			for _, cc := range clinics {
+				prev[cc.ID] = cc
+			}
+		}
+		if existing != nil {
+			var stats mergeStats
+			if clinics, stats, err = mergeDataset(existing, clinics, *mergePolicy); err != nil {
+				return inputError(err)
+			}
+			slog.Info("merged dataset", "updated", stats.Updated, "kept", stats.Kept, "added", stats.Added, "clinics", len(clinics))
+			// overrides take precedence over the existing dataset too
+			if ov != nil {
+				ov.Apply(clinics)
+			}
+		}
+
+		sortClinics(clinics)
+
+		summary := newRunSummary(clinics, geocoder.Calls(), geocoder.CacheHits(), time.Since(startTime))
+		if opts.Prev != nil {
+			// unlike failures, a dataset, that lost clinics, e.g. because a page of the source is missing,
+			// is never written, so it can't replace the previous one
+			if err := checkVanished(summary, opts.Prev, clinics, vanishedPolicy); err != nil {
+				summary.Print(os.Stderr)
+				report(summary, clinics, checksum, nil, err)
+				return err
+			}
+		}
+
+		// outputs are written even if too many clinics failed, so the failures can be inspected
+		if err := ef.write(clinics, opts); err != nil {
+			return outputError(err)
+		}
+		if *reviewFile != "" {
+			var low []*dmsparse.Clinic
+			for _, cc := range clinics {
+				if cc.Confidence < *reviewBelow {
+					low = append(low, cc)
+				}
+			}
+			if err := export.WriteFile(*reviewFile, "review-csv", low, opts); err != nil {
+				return outputError(err)
+			}
+		}
+		if *eventsSink != "" {
+			if err := emitEvents(*eventsSink, changeEvents(opts.Prev, clinics)); err != nil {
+				return outputError(fmt.Errorf("could not emit change events: %v", err))
+			}
+		}
+
+		return finish(summary, clinics, checksum)
+	}
+
+	if !*watchMode || *estimate {
+		return runOnce()
+	}
+	if inf.isStdin() {
+		return usageError(fmt.Errorf("can't watch stdin"))
+	}
+	paths, err := inf.paths()
+	if err != nil {
+		return usageError(err)
+	}
+	prev = make(map[string]*dmsparse.Clinic)
+	watch(append(paths, *ef.templateFile, *ef.prevFile, *ovf), *watchPoll, runOnce)
+	return nil
+}
+
+// reusePoints copies points of geocoded clinics from prev to the clinics with the same ID,
+// i.e. with the same name and address.
+func reusePoints(clinics []*dmsparse.Clinic, prev map[string]*dmsparse.Clinic) {
+	for _, cc := range clinics {
+		p, ok := prev[cc.ID]
+		if !ok {
+			continue
+		}
+		if _, _, ok := cc.LatLon(); ok {
+			continue
+		}
+		if _, _, ok := p.LatLon(); ok {
+			cc.Points, cc.Address, cc.City, cc.District = p.Points, p.Address, p.City, p.District
+			cc.Precision, cc.Confidence = p.Precision, p.Confidence
+		}
+	}
+}
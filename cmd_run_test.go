@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+func TestReusePoints(t *testing.T) {
+	p := &dmsparse.Clinic{
+		ID:         "a",
+		Points:     []float64{55.75, 37.61},
+		Address:    "Россия, Москва, улица Новая, 1",
+		City:       "Москва",
+		District:   "Центральный административный округ",
+		Precision:  "exact",
+		Confidence: 0.9,
+	}
+	cc := &dmsparse.Clinic{ID: "a"}
+	reusePoints([]*dmsparse.Clinic{cc}, map[string]*dmsparse.Clinic{"a": p})
+	if !reflect.DeepEqual(cc, p) {
+		t.Errorf("reusePoints: got %+v, want %+v", cc, p)
+	}
+}
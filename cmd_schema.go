@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/narqo/vtb-dms/export"
+)
+
+// runSchema implements "schema" command, that prints JSON Schema of the json output.
+func runSchema(args []string) error {
+	if len(args) != 0 {
+		return usageError(fmt.Errorf("usage: %s schema", os.Args[0]))
+	}
+	_, err := fmt.Print(export.JSONSchema)
+	return err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/search"
+)
+
+// runSearch implements "search query..." command, that writes clinics of a dataset, matching the query,
+// with the best matches first.
+func runSearch(args []string) error {
+	fs := newFlagSet("search", "query...")
+	var (
+		inf   = newInputFlags(fs, "path to json, yaml or ndjson dataset", "ndjson")
+		limit = fs.Int("limit", 20, "maximum number of clinics to find, 0 for all")
+		ef    = newExportFlags(fs)
+	)
+	parseFlags(fs, args)
+	query := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		fs.Usage()
+		return usageError(fmt.Errorf("no search query"))
+	}
+
+	clinics, checksum, err := inf.read()
+	if err != nil {
+		return inputError(err)
+	}
+	opts, err := ef.options()
+	if err != nil {
+		return inputError(err)
+	}
+	opts.SourceSHA256 = checksum
+
+	hits := search.New(clinics).Search(query, *limit)
+	found := make([]*dmsparse.Clinic, len(hits))
+	for i, h := range hits {
+		found[i] = h.Clinic
+	}
+	if err := ef.write(found, opts); err != nil {
+		return outputError(err)
+	}
+	return nil
+}
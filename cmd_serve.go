@@ -0,0 +1,483 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/geocode"
+	"github.com/narqo/vtb-dms/search"
+	"github.com/narqo/vtb-dms/spatial"
+)
+
+// runServe implements "serve" command, that serves a geocoded dataset over HTTP REST API:
+//
+//	GET  /clinics               list clinics, optionally filtered by ?city=, ?precision=, ?geocoded=, ?category=, ?program= or ?id=;
+//	                            with ?limit= or ?cursor= the list is paginated by ID
+//	GET  /clinics/nearest       list clinics nearest to ?lat=&lon=, up to ?limit= (default 10)
+//	GET  /clinics/within        list clinics within ?radius_m= of ?lat=&lon=, or within ?bbox=minLat,minLon,maxLat,maxLon
+//	GET  /clinics/search        search clinics by name and address with ?q=, up to ?limit= (default 20) per page
+//	GET  /clinics/{id}          get clinic by ID
+//	POST /clinics/{id}/geocode  geocode clinic again, bypassing the cache
+//	GET  /clusters              clinics clustered for the map at ?zoom= within ?bbox=minLat,minLon,maxLat,maxLon
+//	GET  /graphql               GraphQL endpoint, also as POST; GET /graphql/schema prints the schema
+//	GET  /review                web UI for reviewing clinics, that failed geocoding or have low precision
+//	GET  /metrics               Prometheus metrics
+//	GET  /openapi.json          OpenAPI document of the API
+//	GET  /healthz               liveness probe
+//	GET  /readyz                readiness probe: the dataset is loaded, the geocoder and its cache are reachable
+//
+// With -persist-index the spatial index is saved next to the dataset and reloaded on the next start,
+// unless the dataset changed.
+//
+// Paginated endpoints set "Link: <url>; rel=next" header to the URL of the next page, if there is one.
+// With -token flags the API requires bearer tokens, see requireTokens. With -cors-origin flags
+// browsers may call it from the given origins.
+func runServe(args []string) error {
+	fs := newFlagSet("serve", "")
+	var (
+		addr = fs.String("addr", ":8080", "address to listen on")
+		inf  = newInputFlags(fs, "path to json, yaml or ndjson dataset", "ndjson")
+		ovf  = fs.String("overrides", "", "path to overrides yaml or csv file, whose points and fields take precedence over geocoder results; fixes made in review UI are saved there")
+		gf   = newGeocodeFlags(fs)
+		af   = newAPIFlags(fs)
+
+		persistIndex = fs.Bool("persist-index", false, "save the spatial index next to the dataset, as <dataset>"+spatialIndexExt+", and reload it on start, unless the dataset changed")
+	)
+	parseFlags(fs, args)
+
+	g, err := gf.geocoder()
+	if err != nil {
+		return usageError(err)
+	}
+	var ov *overrides
+	if *ovf != "" {
+		if ov, err = loadOverrides(*ovf); err != nil {
+			return inputError(err)
+		}
+	}
+	clinics, _, err := inf.read()
+	if err != nil {
+		return inputError(err)
+	}
+
+	s := newServer(g, ov)
+	if *persistIndex {
+		paths, err := inf.paths()
+		if err != nil {
+			return inputError(err)
+		}
+		if len(paths) != 1 || inf.isStdin() || isURL(paths[0]) {
+			return usageError(fmt.Errorf("-persist-index requires a single input file"))
+		}
+		s.indexPath = paths[0] + spatialIndexExt
+	}
+	s.SetClinics(clinics)
+	h, err := af.handler(s)
+	if err != nil {
+		return usageError(err)
+	}
+	af.startDiagnostics()
+
+	srv := &http.Server{
+		Addr:    *addr,
+		Handler: h,
+	}
+	slog.Info("serving", "addr", *addr, "clinics", len(clinics))
+	if err := listenAndServe(srv); err != nil {
+		return err
+	}
+	return g.Close()
+}
+
+// server serves the dataset over HTTP. The dataset is immutable, changes replace it as a whole,
+// so handlers read it without locking.
+type server struct {
+	http.Handler
+
+	geocoder *geocoder
+	// overrides, if set, are applied to the dataset and store fixes made in review UI.
+	overrides *overrides
+	// indexPath, if set, is the file, the spatial index of the dataset is saved to and loaded from.
+	indexPath string
+	data      atomic.Pointer[dataset]
+	// loaded is set, once the dataset is loaded, e.g. after the first daemon's build.
+	loaded        atomic.Bool
+	geocoderCheck geocoderCheck
+	// mu serializes changes of the dataset.
+	mu sync.Mutex
+}
+
+// dataset is the served clinics with their indexes by ID and by location.
+type dataset struct {
+	clinics []*dmsparse.Clinic
+	byID    map[string]*dmsparse.Clinic
+	// spatial indexes geocoded clinics, IDs of the index are positions in located.
+	spatial *spatial.Index
+	located []*dmsparse.Clinic
+	text    *search.Index
+	// hash identifies the version of the dataset, modified is the time the version was served first.
+	hash     string
+	modified time.Time
+}
+
+// newDataset indexes clinics. If indexPath is set, the spatial index is loaded from it, see loadSpatialIndex.
+func newDataset(clinics []*dmsparse.Clinic, indexPath string) *dataset {
+	d := &dataset{
+		clinics:  clinics,
+		byID:     make(map[string]*dmsparse.Clinic, len(clinics)),
+		hash:     datasetHash(clinics),
+		modified: time.Now(),
+	}
+	var lats, lons []float64
+	for _, cc := range clinics {
+		d.byID[cc.ID] = cc
+		if lat, lon, ok := cc.LatLon(); ok {
+			lats, lons = append(lats, lat), append(lons, lon)
+			d.located = append(d.located, cc)
+		}
+	}
+	if indexPath != "" {
+		d.spatial = loadSpatialIndex(indexPath, d.hash, lats, lons)
+	} else {
+		d.spatial = spatial.New(lats, lons)
+	}
+	d.text = search.New(clinics)
+	return d
+}
+
+func newServer(g *geocoder, ov *overrides) *server {
+	s := &server{geocoder: g, overrides: ov}
+
+	mux := openAPIMux{http.NewServeMux()}
+	mux.HandleFunc("GET /clinics", s.handleList)
+	mux.HandleFunc("GET /clinics/nearest", s.handleNearest)
+	mux.HandleFunc("GET /clinics/search", s.handleSearch)
+	mux.HandleFunc("GET /clinics/within", s.handleWithin)
+	mux.HandleFunc("GET /clinics/{id}", s.handleGet)
+	mux.HandleFunc("GET /clusters", s.handleClusters)
+	mux.HandleFunc("POST /clinics/{id}/geocode", s.handleGeocode)
+	mux.HandleFunc("GET /graphql", s.handleGraphQL)
+	mux.HandleFunc("POST /graphql", s.handleGraphQL)
+	mux.HandleFunc("GET /graphql/schema", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, graphqlSchema)
+	})
+	mux.HandleFunc("GET /review", s.handleReviewPage)
+	mux.HandleFunc("GET /review/clinics", s.handleReviewList)
+	mux.HandleFunc("POST /review/clinics/{id}", s.handleReviewFix)
+	mux.Handle("GET /metrics", metrics)
+	mux.HandleFunc("GET /openapi.json", handleOpenAPI)
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+	s.Handler = s.conditionalGET(mux)
+	s.store(newDataset(nil, ""))
+
+	return s
+}
+
+// SetClinics replaces the served dataset.
+func (s *server) SetClinics(clinics []*dmsparse.Clinic) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.overrides != nil {
+		s.overrides.Apply(clinics)
+	}
+	sortClinics(clinics)
+	s.store(newDataset(clinics, s.indexPath))
+	s.loaded.Store(true)
+}
+
+func (s *server) handleList(w http.ResponseWriter, r *http.Request) {
+	filter, err := queryFilter(r)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	clinics := filter.Apply(s.data.Load().clinics)
+
+	q := r.URL.Query()
+	if !q.Has("limit") && !q.Has("cursor") {
+		writeJSONResponse(w, http.StatusOK, clinics)
+		return
+	}
+	limit, after, err := pageQuery(r, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, next := pageByID(clinics, after, limit)
+	if next != "" {
+		setNextPage(w, r, next)
+	}
+	writeJSONResponse(w, http.StatusOK, page)
+}
+
+// queryFilter returns the filter from the request's query parameters, named after filter keys,
+// e.g. ?city=Москва&geocoded=true. Repeated parameters are alternative values.
+func queryFilter(r *http.Request) (clinicFilter, error) {
+	f := make(clinicFilter)
+	q := r.URL.Query()
+	for _, key := range filterKeys {
+		if vals, ok := q[key]; ok {
+			if err := f.Add(key, vals...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return f, nil
+}
+
+func (s *server) handleNearest(w http.ResponseWriter, r *http.Request) {
+	lat, lon, err := parseLatLon(r)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxNearestLimit {
+			writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("limit must be from 1 to %d", maxNearestLimit))
+			return
+		}
+	}
+
+	data := s.data.Load()
+	hits := data.spatial.Nearest(lat, lon, limit)
+	writeJSONResponse(w, http.StatusOK, clinicHits(data, hits))
+}
+
+func (s *server) handleWithin(w http.ResponseWriter, r *http.Request) {
+	data := s.data.Load()
+	if v := r.URL.Query().Get("bbox"); v != "" {
+		bbox, err := parseBBox(v)
+		if err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		clinics := []*dmsparse.Clinic{}
+		for _, id := range data.spatial.InBox(bbox[0], bbox[1], bbox[2], bbox[3]) {
+			clinics = append(clinics, data.located[id])
+		}
+		writeJSONResponse(w, http.StatusOK, clinics)
+		return
+	}
+
+	lat, lon, err := parseLatLon(r)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	radius, err := strconv.ParseFloat(r.URL.Query().Get("radius_m"), 64)
+	if err != nil || radius <= 0 || radius > maxWithinRadius {
+		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("radius_m must be a number from 0 to %d", maxWithinRadius))
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, clinicHits(data, data.spatial.Within(lat, lon, radius)))
+}
+
+// maxWithinRadius limits the radius of within query, in meters.
+const maxWithinRadius = 100000
+
+// maxNearestLimit limits the number of clinics in nearest query response.
+const maxNearestLimit = 100
+
+// parseLatLon returns the point from lat and lon query parameters.
+func parseLatLon(r *http.Request) (lat, lon float64, err error) {
+	q := r.URL.Query()
+	lat, err = strconv.ParseFloat(q.Get("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("lat must be a number from -90 to 90")
+	}
+	lon, err = strconv.ParseFloat(q.Get("lon"), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("lon must be a number from -180 to 180")
+	}
+	return lat, lon, nil
+}
+
+// clinicDistance is a clinic found by a spatial query, encoded to json with the distance to it.
+type clinicDistance struct {
+	*dmsparse.Clinic
+	Distance float64
+}
+
+func (c clinicDistance) MarshalJSON() ([]byte, error) {
+	data, err := c.Clinic.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	// the clinic is always encoded as a non-empty object
+	return append(data[:len(data)-1], fmt.Sprintf(`,"distance_m":%.0f}`, c.Distance)...), nil
+}
+
+func clinicHits(data *dataset, hits []spatial.Hit) []clinicDistance {
+	clinics := make([]clinicDistance, len(hits))
+	for i, h := range hits {
+		clinics[i] = clinicDistance{data.located[h.ID], h.Distance}
+	}
+	return clinics
+}
+
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if strings.TrimSpace(q) == "" {
+		writeErrorResponse(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit, cursor, err := pageQuery(r, 20, maxSearchLimit)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// search results are ranked, so the cursor is the offset of the page
+	var offset int
+	if cursor != "" {
+		if offset, err = strconv.Atoi(cursor); err != nil || offset < 0 {
+			writeErrorResponse(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+	}
+
+	data := s.data.Load()
+	// there can't be more hits than clinics, so a huge offset doesn't make the search rank them all
+	// or overflow the limit
+	offset = min(offset, len(data.clinics))
+	hits := data.text.Search(q, offset+limit+1)
+	if offset > len(hits) {
+		offset = len(hits)
+	}
+	hits = hits[offset:]
+	if len(hits) > limit {
+		hits = hits[:limit]
+		setNextPage(w, r, strconv.Itoa(offset+limit))
+	}
+	clinics := make([]*dmsparse.Clinic, len(hits))
+	for i, h := range hits {
+		clinics[i] = h.Clinic
+	}
+	writeJSONResponse(w, http.StatusOK, clinics)
+}
+
+// maxSearchLimit limits the number of clinics in a page of search results.
+const maxSearchLimit = 100
+
+func (s *server) handleGet(w http.ResponseWriter, r *http.Request) {
+	cc, ok := s.data.Load().byID[r.PathValue("id")]
+	if !ok {
+		writeErrorResponse(w, http.StatusNotFound, "clinic not found")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, cc)
+}
+
+func (s *server) handleGeocode(w http.ResponseWriter, r *http.Request) {
+	old, ok := s.data.Load().byID[r.PathValue("id")]
+	if !ok {
+		writeErrorResponse(w, http.StatusNotFound, "clinic not found")
+		return
+	}
+
+	// geocode a copy, so concurrent readers never see the clinic half-updated
+	cc := *old
+	if err := geocode.GeocodeClinic(refreshGeocoder{s.geocoder}, &cc); err != nil {
+		slog.Warn("could not geocode clinic", "id", cc.ID, "address", cc.RawAddress, "err", err)
+		writeErrorResponse(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	// an obviously wrong point must not replace the clinic's one, as in batch geocoding
+	if err := rejectOutlier(&cc); err != nil {
+		slog.Warn("could not geocode clinic", "id", cc.ID, "address", cc.RawAddress, "err", err)
+		writeErrorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	// the dataset may have been rebuilt, while the clinic was geocoded
+	s.mu.Lock()
+	err := s.replace(old, &cc)
+	s.mu.Unlock()
+	if err != nil {
+		writeErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, &cc)
+}
+
+var errClinicChanged = errors.New("clinic was changed meanwhile, e.g. the dataset was rebuilt, retry the request")
+
+// replace replaces old clinic in the served dataset with cc. If the dataset no longer has old clinic,
+// as it was changed since old was loaded, errClinicChanged is returned. The caller must hold s.mu.
+func (s *server) replace(old, cc *dmsparse.Clinic) error {
+	data := s.data.Load()
+	if data.byID[old.ID] != old {
+		return errClinicChanged
+	}
+	clinics := make([]*dmsparse.Clinic, len(data.clinics))
+	for i, c := range data.clinics {
+		if c == old {
+			c = cc
+		}
+		clinics[i] = c
+	}
+	s.store(newDataset(clinics, ""))
+	return nil
+}
+
+// refreshGeocoder is Geocoder, that bypasses the cache.
+type refreshGeocoder struct {
+	g *geocoder
+}
+
+func (r refreshGeocoder) Geocode(address string) (*geocode.Result, error) {
+	return r.g.Refresh(address)
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("could not write response", "err", err)
+	}
+}
+
+func writeErrorResponse(w http.ResponseWriter, status int, msg string) {
+	writeJSONResponse(w, status, struct {
+		Error string `json:"error"`
+	}{msg})
+}
+
+// listenAndServe serves until the process is interrupted, then shuts the server down gracefully.
+func listenAndServe(srv *http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	slog.Info("shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/export"
+	"github.com/narqo/vtb-dms/geocode"
+)
+
+// gRPC status codes, see https://grpc.github.io/grpc/core/md_doc_statuscodes.html.
+const (
+	grpcUnknown           = 2
+	grpcInvalidArgument   = 3
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+)
+
+// grpcMaxMessageSize is the size of the largest request message, so a client can't make the server
+// allocate as much memory, as the length prefix of a message allows.
+const grpcMaxMessageSize = 16 << 20
+
+// grpcError is an error with gRPC status code.
+type grpcError struct {
+	code int
+	msg  string
+}
+
+func (e *grpcError) Error() string { return e.msg }
+
+// grpcMethod handles a unary RPC: it decodes the request message and returns the response message.
+type grpcMethod func(req []byte) ([]byte, error)
+
+// runServeGRPC implements "serve-grpc" command, that serves ClinicService, see proto/clinic_service.proto.
+// gRPC runs over unencrypted HTTP/2 and is implemented on top of net/http, with messages encoded by hand;
+// only unary calls without message compression are supported.
+func runServeGRPC(args []string) error {
+	fs := newFlagSet("serve-grpc", "")
+	var (
+		addr = fs.String("addr", ":9090", "address to listen on")
+		gf   = newGeocodeFlags(fs)
+		ef   = newExportFlags(fs)
+	)
+	parseFlags(fs, args)
+
+	g, err := gf.geocoder()
+	if err != nil {
+		return usageError(err)
+	}
+
+	methods := map[string]grpcMethod{
+		"/vtbdms.ClinicService/ParseDocument": grpcParseDocument,
+		"/vtbdms.ClinicService/GeocodeClinic": func(req []byte) ([]byte, error) {
+			return grpcGeocodeClinic(g, req)
+		},
+		"/vtbdms.ClinicService/ExportDataset": func(req []byte) ([]byte, error) {
+			opts, err := ef.options()
+			if err != nil {
+				return nil, err
+			}
+			opts.Provider = *gf.provider
+			return grpcExportDataset(opts, req)
+		},
+	}
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{
+		Addr:      *addr,
+		Handler:   grpcHandler(methods),
+		Protocols: &protocols,
+	}
+	slog.Info("serving gRPC", "addr", *addr)
+	if err := listenAndServe(srv); err != nil {
+		return err
+	}
+	return g.Close()
+}
+
+func grpcHandler(methods map[string]grpcMethod) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.ProtoMajor != 2 {
+			http.Error(w, "gRPC requires POST over HTTP/2", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+
+		method, ok := methods[r.URL.Path]
+		if !ok {
+			grpcStatus(w, &grpcError{grpcUnimplemented, "unknown method " + r.URL.Path})
+			return
+		}
+		req, err := grpcReadMessage(r.Body)
+		if err != nil {
+			grpcStatus(w, err)
+			return
+		}
+		resp, err := method(req)
+		if err != nil {
+			slog.Warn("gRPC call failed", "method", r.URL.Path, "err", err)
+			grpcStatus(w, err)
+			return
+		}
+
+		var frame [5]byte
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(resp)))
+		w.Write(frame[:])
+		w.Write(resp)
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	})
+}
+
+// grpcReadMessage reads a single length-prefixed message of the request.
+func grpcReadMessage(r io.Reader) ([]byte, error) {
+	var frame [5]byte
+	if _, err := io.ReadFull(r, frame[:]); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, "could not read request message: " + err.Error()}
+	}
+	if frame[0] != 0 {
+		return nil, &grpcError{grpcUnimplemented, "compressed messages aren't supported"}
+	}
+	size := binary.BigEndian.Uint32(frame[1:])
+	if size > grpcMaxMessageSize {
+		return nil, &grpcError{grpcResourceExhausted, fmt.Sprintf("request message of %d bytes exceeds the limit of %d bytes", size, grpcMaxMessageSize)}
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, "could not read request message: " + err.Error()}
+	}
+	return msg, nil
+}
+
+// grpcStatus writes the error as trailers-only response.
+func grpcStatus(w http.ResponseWriter, err error) {
+	code := grpcUnknown
+	var e *grpcError
+	if errors.As(err, &e) {
+		code = e.code
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", grpcPercentEncode(err.Error()))
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcPercentEncode encodes the status message as the gRPC spec requires: the bytes, that aren't
+// printable ASCII, and % itself, are percent-encoded, as e.g. Cyrillic text isn't valid in a header.
+func grpcPercentEncode(msg string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= 0x20 && c <= 0x7E && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xF])
+	}
+	return b.String()
+}
+
+func grpcParseDocument(req []byte) ([]byte, error) {
+	var doc []byte
+	err := export.PbRange(req, func(field int, v []byte, _ uint64) error {
+		if field == 1 {
+			doc = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	clinics, err := dmsparse.Parse(bytes.NewReader(doc))
+	if err != nil {
+		return nil, &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	return export.MarshalProto(clinics), nil
+}
+
+func grpcGeocodeClinic(g geocode.Geocoder, req []byte) ([]byte, error) {
+	cc, err := export.UnmarshalClinicProto(req)
+	if err != nil {
+		return nil, &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	if cc.RawAddress == "" {
+		return nil, &grpcError{grpcInvalidArgument, "no raw address in clinic"}
+	}
+	if cc.ID == "" {
+		cc.ID = dmsparse.ClinicID(cc)
+	}
+	if err := geocode.GeocodeClinic(g, cc); err != nil {
+		return nil, err
+	}
+	return export.MarshalClinicProto(cc), nil
+}
+
+func grpcExportDataset(opts *export.Options, req []byte) ([]byte, error) {
+	var (
+		clinics []*dmsparse.Clinic
+		format  = "json"
+	)
+	err := export.PbRange(req, func(field int, v []byte, _ uint64) error {
+		switch field {
+		case 1:
+			cc, err := export.UnmarshalClinicProto(v)
+			if err != nil {
+				return err
+			}
+			clinics = append(clinics, cc)
+		case 2:
+			format = string(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &grpcError{grpcInvalidArgument, err.Error()}
+	}
+
+	var buf bytes.Buffer
+	if err := export.Write(&buf, format, clinics, opts); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, fmt.Sprintf("could not export %s: %v", format, err)}
+	}
+	return export.PbAppendBytes(nil, 1, buf.Bytes()), nil
+}
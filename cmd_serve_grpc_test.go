@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGRPCReadMessage(t *testing.T) {
+	msg, err := grpcReadMessage(bytes.NewReader([]byte{0, 0, 0, 0, 3, 'a', 'b', 'c'}))
+	if err != nil || string(msg) != "abc" {
+		t.Fatalf("grpcReadMessage = %q, %v, want abc", msg, err)
+	}
+
+	// a client must not make the server allocate 4 GiB
+	_, err = grpcReadMessage(bytes.NewReader([]byte{0, 0xFF, 0xFF, 0xFF, 0xFF}))
+	var e *grpcError
+	if !errors.As(err, &e) || e.code != grpcResourceExhausted {
+		t.Fatalf("grpcReadMessage of oversized message: got %v, want ResourceExhausted", err)
+	}
+}
+
+func TestGRPCStatusMessage(t *testing.T) {
+	w := httptest.NewRecorder()
+	grpcStatus(w, &grpcError{grpcInvalidArgument, "нет адреса: 100%\nстрока"})
+	want := "%D0%BD%D0%B5%D1%82 %D0%B0%D0%B4%D1%80%D0%B5%D1%81%D0%B0: 100%25%0A%D1%81%D1%82%D1%80%D0%BE%D0%BA%D0%B0"
+	if got := w.Header().Get("Grpc-Message"); got != want {
+		t.Errorf("Grpc-Message = %q, want %q", got, want)
+	}
+	if got := w.Header().Get("Grpc-Status"); got != "3" {
+		t.Errorf("Grpc-Status = %q, want 3", got)
+	}
+}
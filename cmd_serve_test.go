@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/geocode"
+)
+
+func TestServerReplaceAfterRebuild(t *testing.T) {
+	s := newServer(nil, nil)
+	s.SetClinics([]*dmsparse.Clinic{
+		{ID: "1", Name: "Клиника 1", RawAddress: "г. Москва"},
+		{ID: "2", Name: "Клиника 2", RawAddress: "г. Казань"},
+	})
+
+	// a change of one clinic keeps the others
+	old := s.data.Load().byID["1"]
+	fixed := *old
+	fixed.Points = []float64{55.75, 37.61}
+	s.mu.Lock()
+	err := s.replace(old, &fixed)
+	s.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := s.data.Load(); data.byID["1"] != &fixed || len(data.clinics) != 2 {
+		t.Fatalf("replace() didn't update the dataset: %+v", data.clinics)
+	}
+
+	// a change, that started before the rebuild, must not restore the old dataset
+	old = s.data.Load().byID["2"]
+	s.SetClinics([]*dmsparse.Clinic{{ID: "2", Name: "Клиника 2", RawAddress: "г. Казань, ул. Баумана, 1"}})
+	stale := *old
+	stale.Points = []float64{55.79, 49.1}
+	s.mu.Lock()
+	err = s.replace(old, &stale)
+	s.mu.Unlock()
+	if !errors.Is(err, errClinicChanged) {
+		t.Errorf("replace() of stale clinic error = %v, want %v", err, errClinicChanged)
+	}
+	data := s.data.Load()
+	if len(data.clinics) != 1 || data.clinics[0].RawAddress != "г. Казань, ул. Баумана, 1" {
+		t.Errorf("rebuilt dataset was replaced: %+v", data.clinics)
+	}
+}
+
+type staticGeocoder geocode.Result
+
+func (g *staticGeocoder) Geocode(string) (*geocode.Result, error) {
+	res := geocode.Result(*g)
+	return &res, nil
+}
+
+func (g *staticGeocoder) Calls() int64 { return 0 }
+
+func (g *staticGeocoder) Ping(context.Context) error { return nil }
+
+func TestHandleGeocodeRejectsOutlier(t *testing.T) {
+	novosibirsk := &staticGeocoder{Lat: 55.03, Lon: 82.92, Address: "Россия, Новосибирск, улица Новая, 1", City: "Новосибирск", Precision: "exact"}
+	s := newServer(&geocoder{Geocoder: novosibirsk}, nil)
+	clinic := &dmsparse.Clinic{ID: "1", Name: "Клиника", RawAddress: "г. Москва, ул. Новая, д. 1", Points: []float64{55.75, 37.61}, City: "Москва", Precision: "exact"}
+	s.SetClinics([]*dmsparse.Clinic{clinic})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/clinics/1/geocode", nil))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("POST /clinics/1/geocode status = %d, want 422: %s", w.Code, w.Body)
+	}
+	if cc := s.data.Load().byID["1"]; cc != clinic {
+		t.Errorf("the rejected point replaced the clinic: %+v", cc)
+	}
+}
+
+func TestHandleSearchHugeOffset(t *testing.T) {
+	s := newServer(nil, nil)
+	s.SetClinics([]*dmsparse.Clinic{
+		{ID: "1", Name: "Стоматология на Новой", RawAddress: "г. Москва, ул. Новая, д. 1"},
+		{ID: "2", Name: "Стоматология на Старой", RawAddress: "г. Москва, ул. Старая, д. 2"},
+	})
+
+	for _, offset := range []string{"1", "9223372036854775807"} {
+		w := httptest.NewRecorder()
+		cursor := base64.RawURLEncoding.EncodeToString([]byte(offset))
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/clinics/search?q=стоматология&limit=1&cursor="+cursor, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("search with offset %s: status = %d: %s", offset, w.Code, w.Body)
+			continue
+		}
+		var clinics []*dmsparse.Clinic
+		if err := json.Unmarshal(w.Body.Bytes(), &clinics); err != nil {
+			t.Fatal(err)
+		}
+		want := 1
+		if offset != "1" {
+			want = 0
+		}
+		if len(clinics) != want || w.Header().Get("Link") != "" {
+			t.Errorf("search with offset %s: got %d clinics, Link %q, want %d clinics and no next page", offset, len(clinics), w.Header().Get("Link"), want)
+		}
+	}
+}
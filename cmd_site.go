@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/narqo/vtb-dms/export"
+)
+
+// runSite implements "site" command, that renders a static site with a page per clinic and city index pages.
+func runSite(args []string) error {
+	fs := newFlagSet("site", "")
+	var (
+		inf       = newInputFlags(fs, "path to json, yaml or ndjson dataset", "ndjson")
+		outDir    = fs.String("out", "", "path to the directory to render the site into")
+		templates = fs.String("templates", "", "path to the directory with index.html, city.html and clinic.html templates, overriding the built-in ones")
+		filter    = fs.String("filter", "", `render only clinics matching the filter, e.g. "city=Москва"`)
+	)
+	parseFlags(fs, args)
+	if *outDir == "" {
+		return usageError(fmt.Errorf("-out directory is required"))
+	}
+	f, err := parseFilter(*filter)
+	if err != nil {
+		return usageError(err)
+	}
+
+	clinics, _, err := inf.read()
+	if err != nil {
+		return inputError(err)
+	}
+	sortClinics(clinics)
+
+	if err := export.WriteSite(*outDir, *templates, f.Apply(clinics)); err != nil {
+		return outputError(err)
+	}
+	return nil
+}
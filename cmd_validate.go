@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/narqo/vtb-dms/validate"
+)
+
+// runValidate implements "validate" command, that checks the dataset against validation rules
+// and reports violations. It fails, if there are violations of -fail-on severity or higher, so it
+// can gate publishing the dataset.
+func runValidate(args []string) error {
+	fs := newFlagSet("validate", "")
+	var (
+		inf     = newInputFlags(fs, "path to json, yaml or ndjson dataset", "ndjson")
+		format  = fs.String("format", "text", "report format: text or json")
+		outFile = fs.String("out", "", "path to write the report to (default stdout)")
+		failOn  = fs.String("fail-on", "error", "fail if there are violations of this severity or higher: info, warning, error or none")
+		country = fs.String("country-bbox", "", "bounding box minLat,minLon,maxLat,maxLon, where all clinics must be (default Russia)")
+	)
+	parseFlags(fs, args)
+
+	if *country != "" {
+		bbox, err := parseCountryBBox(*country)
+		if err != nil {
+			return usageError(err)
+		}
+		validate.Country = bbox
+	}
+	if *format != "text" && *format != "json" {
+		return usageError(fmt.Errorf("unknown report format: %q", *format))
+	}
+	var minSeverity validate.Severity
+	if *failOn != "none" {
+		var err error
+		if minSeverity, err = validate.ParseSeverity(*failOn); err != nil {
+			return usageError(err)
+		}
+	}
+
+	clinics, _, err := inf.read()
+	if err != nil {
+		return inputError(err)
+	}
+	sortClinics(clinics)
+	report := validate.Run(clinics, validate.Rules)
+
+	out := os.Stdout
+	if *outFile != "" && *outFile != "-" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			return outputError(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = printValidationReport(out, report)
+	}
+	if err != nil {
+		return outputError(err)
+	}
+
+	if *failOn != "none" {
+		if n := report.Count(minSeverity); n > 0 {
+			return &exitError{exitInvalid, fmt.Errorf("%d violations of %s severity or higher", n, minSeverity)}
+		}
+	}
+	return nil
+}
+
+func printValidationReport(w io.Writer, r *validate.Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "RULE\tSEVERITY\tVIOLATIONS\tDESCRIPTION\n")
+	for _, rule := range r.Rules {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", rule.Name, rule.Severity, rule.Violations, rule.Description)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(r.Issues) > 0 {
+		fmt.Fprintln(w)
+	}
+	for _, is := range r.Issues {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s: %s\n", is.Severity, is.Rule, is.ID, is.Name, is.Message)
+	}
+	_, err := fmt.Fprintf(w, "\n%d clinics checked, %d errors, %d warnings\n",
+		r.Clinics, r.Count(validate.Error), r.Count(validate.Warning)-r.Count(validate.Error))
+	return err
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"os"
+
+	"github.com/narqo/vtb-dms/export"
+)
+
+// runVerify implements "verify" command, that checks output files against checksums and signatures,
+// written with -checksum and -sign-key, e.g. before the files are published to CDN.
+func runVerify(args []string) error {
+	fs := newFlagSet("verify", "<file>...")
+	pubKey := fs.String("pubkey", "", "path to PEM Ed25519 public key to check signatures with; without it only checksums are checked")
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		return usageError(fmt.Errorf("no files to verify"))
+	}
+	var key ed25519.PublicKey
+	if *pubKey != "" {
+		var err error
+		if key, err = export.ReadPublicKey(*pubKey); err != nil {
+			return usageError(err)
+		}
+	}
+
+	var failed int
+	for _, path := range fs.Args() {
+		if err := export.VerifyChecksum(path, key); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: FAILED: %v\n", path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: OK\n", path)
+	}
+	if failed > 0 {
+		return &exitError{exitCorrupt, fmt.Errorf("%d of %d files failed verification", failed, fs.NArg())}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// collations are the languages, which output can be sorted with the collation rules of.
+var collations = []string{"ru"}
+
+// sortClinicsCollated sorts clinics by city, then by name, with Russian collation rules, so lists match
+// the order of the published catalogue. Clinics without a city go last.
+func sortClinicsCollated(clinics []*dmsparse.Clinic) {
+	slices.SortStableFunc(clinics, func(a, b *dmsparse.Clinic) int {
+		if (a.City == "") != (b.City == "") {
+			if a.City == "" {
+				return 1
+			}
+			return -1
+		}
+		if c := compareRu(a.City, b.City); c != 0 {
+			return c
+		}
+		if c := compareRu(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+}
+
+const ruAlphabet = "абвгдежзийклмнопрстуфхцчшщъыьэюя"
+
+// compareRu compares strings the way a Russian reader orders them: letters in the order of the alphabet
+// regardless of case, with ё as е, and with punctuation, e.g. quotes, ignored. Digits go before letters,
+// and Cyrillic letters before Latin ones. Strings, that are equal so, are ordered with ё after е, then
+// lowercase before uppercase, and finally by bytes.
+func compareRu(a, b string) int {
+	ka, kb := ruKey(a), ruKey(b)
+	for level := 0; level < 3; level++ {
+		if c := slices.Compare(ka[level], kb[level]); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// ruKey returns primary, secondary and tertiary weights of the letters of s.
+func ruKey(s string) [3][]int {
+	var key [3][]int
+	for _, r := range strings.TrimSpace(s) {
+		lower := unicode.ToLower(r)
+		var (
+			primary   int
+			secondary int
+			tertiary  int
+		)
+		if lower != r {
+			tertiary = 1
+		}
+		if lower == 'ё' {
+			lower, secondary = 'е', 1
+		}
+		switch {
+		case unicode.IsSpace(r) || unicode.Is(unicode.Pd, r):
+			// hyphenated words sort as separate ones, e.g. "альфа-центр" as "альфа центр"
+			primary = 1
+		case '0' <= r && r <= '9':
+			primary = 10 + int(r-'0')
+		case strings.ContainsRune(ruAlphabet, lower):
+			primary = 100 + strings.IndexRune(ruAlphabet, lower)/2 // Cyrillic letters are 2 bytes long
+		case 'a' <= lower && lower <= 'z':
+			primary = 200 + int(lower-'a')
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			primary = 1000 + int(lower)
+		default:
+			continue
+		}
+		// runs of spaces, e.g. left after punctuation, weigh as one
+		if primary == 1 && len(key[0]) > 0 && key[0][len(key[0])-1] == 1 {
+			continue
+		}
+		key[0] = append(key[0], primary)
+		key[1] = append(key[1], secondary)
+		key[2] = append(key[2], tertiary)
+	}
+	return key
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// datasetHash returns the hash of clinics' content, that identifies the version of the dataset.
+func datasetHash(clinics []*dmsparse.Clinic) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, cc := range clinics {
+		enc.Encode(cc)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// store replaces the served dataset. Last-Modified time is kept, if the content didn't change.
+func (s *server) store(d *dataset) {
+	if prev := s.data.Load(); prev != nil && prev.hash == d.hash {
+		d.modified = prev.modified
+	}
+	s.data.Store(d)
+}
+
+// conditionalGET sets ETag and Last-Modified headers of the dataset version to responses of GET
+// requests to dataset endpoints, and answers 304 Not Modified, if the client has that version already.
+func (s *server) conditionalGET(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || !isDatasetPath(r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		data := s.data.Load()
+		etag := `"` + data.hash + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", data.modified.UTC().Format(http.TimeFormat))
+
+		if inm := r.Header.Get("If-None-Match"); inm != "" {
+			if etagMatch(inm, etag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		} else if t, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !data.modified.Truncate(time.Second).After(t) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// isDatasetPath reports whether responses at path depend only on the request and the dataset version.
+func isDatasetPath(path string) bool {
+	return path == "/clinics" || strings.HasPrefix(path, "/clinics/") || path == "/clusters" ||
+		path == "/graphql" || path == "/review/clinics"
+}
+
+// etagMatch reports whether If-None-Match header value matches etag, using weak comparison.
+func etagMatch(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
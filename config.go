@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// envPrefix is the prefix of environment variables, that override flags, e.g. GEN_POINTS_API_KEY for -api-key.
+// Values of repeated flags are comma-separated, e.g. GEN_POINTS_FORMAT=json,xml.
+const envPrefix = "GEN_POINTS_"
+
+// parseFlags parses command line and fills in flags, which weren't set explicitly, from the environment
+// and the config file. Command line takes precedence over the environment, the environment over the config.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	var (
+		configFile = fs.String("config", os.Getenv(envPrefix+"CONFIG"), "path to config file (default $"+envPrefix+"CONFIG)")
+		debug      = fs.Bool("debug", false, "enable debug logging, same as -log-level debug")
+		logLevel   = fs.String("log-level", "info", "log level: debug, info, warn or error")
+		logFormat  = fs.String("log-format", "text", "log format: text or json")
+		showVer    = fs.Bool("version", false, "print version and exit")
+	)
+	fs.Parse(args)
+
+	if *showVer {
+		fmt.Println(generator())
+		os.Exit(0)
+	}
+
+	if err := applyConfig(fs, *configFile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
+	}
+
+	if *debug {
+		*logLevel = "debug"
+	}
+	if err := setupLogger(*logLevel, *logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
+	}
+}
+
+// setupLogger configures the default slog logger, that writes to stderr.
+func setupLogger(level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch format {
+	case "text":
+		h = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid log format %q", format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+func applyConfig(fs *flag.FlagSet, path string) error {
+	var config map[string][]string
+	if path != "" {
+		var err error
+		config, err = readConfig(path)
+		if err != nil {
+			return fmt.Errorf("could not read config %s: %v", path, err)
+		}
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] || f.Name == "config" {
+			return
+		}
+		values, ok := config[f.Name]
+		if env, envOk := os.LookupEnv(envName(f.Name)); envOk {
+			values, ok = []string{env}, true
+			if _, isList := f.Value.(*stringsFlag); isList {
+				values = splitList(env)
+			}
+		}
+		if !ok {
+			return
+		}
+		for _, v := range values {
+			if err = fs.Set(f.Name, v); err != nil {
+				err = fmt.Errorf("invalid value %q for %s: %v", v, f.Name, err)
+				return
+			}
+		}
+	})
+	return err
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// readConfig reads config file of flat "key: value" lines, where keys are flag names. Repeated
+// flags, like out and format, are set with lists, e.g.
+//
+//	# gen_points.yaml
+//	api-key: 0123abcd
+//	concurrency: 5
+//	cache: /var/cache/vtb-dms/geocode.json
+//	out: [public/clinics.json, public/clinics.xml]
+//	format: [json, xml]
+//
+// Keys, which aren't flags of the running command, are ignored, so a single config may be shared
+// between commands.
+func readConfig(path string) (map[string][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config := make(map[string][]string)
+	s := bufio.NewScanner(f)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' || line == "---" {
+			continue
+		}
+		n := strings.Index(line, ":")
+		if n < 0 {
+			return nil, fmt.Errorf("line %d: missing ':' in %q", lineno, line)
+		}
+		key, val := strings.TrimSpace(line[:n]), strings.TrimSpace(line[n+1:])
+		if strings.HasPrefix(val, "[") && strings.HasSuffix(val, "]") {
+			config[key] = splitList(val[1 : len(val)-1])
+		} else {
+			config[key] = []string{unquote(val)}
+		}
+	}
+	return config, s.Err()
+}
+
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = unquote(strings.TrimSpace(v)); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
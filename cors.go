@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsPolicy allows browsers to call the API from other origins, e.g. the web map.
+type corsPolicy struct {
+	// origins are the allowed origins, "*" allows any.
+	origins []string
+	methods []string
+	maxAge  time.Duration
+}
+
+// corsHeaders are the request headers, the API clients may send, and the response headers, they may read.
+const (
+	corsAllowHeaders  = "Authorization, Content-Type, If-None-Match, If-Modified-Since"
+	corsExposeHeaders = "ETag, Last-Modified, Link"
+)
+
+// Handler wraps h, so it sets CORS headers to responses to the allowed origins, and answers preflight requests.
+func (c *corsPolicy) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		if !c.allowed(origin) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		hdr := w.Header()
+		if slices.Contains(c.origins, "*") {
+			hdr.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			hdr.Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			hdr.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+			hdr.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			hdr.Set("Access-Control-Max-Age", strconv.Itoa(int(c.maxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		hdr.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (c *corsPolicy) allowed(origin string) bool {
+	for _, o := range c.origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// serveDebug serves pprof endpoints at addr, e.g. "localhost:6060", so memory and goroutine leaks of
+// a long-running server can be profiled with "go tool pprof http://localhost:6060/debug/pprof/heap".
+// The endpoints are served apart from the API, so they are never exposed with it.
+func serveDebug(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	slog.Info("serving pprof", "addr", addr)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			slog.Error("could not serve pprof", "addr", addr, "err", err)
+		}
+	}()
+}
+
+// logRuntimeStats logs memory, GC and goroutine stats of the process every interval.
+func logRuntimeStats(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			slog.Info("runtime stats",
+				"goroutines", runtime.NumGoroutine(),
+				"heap_alloc_mb", m.HeapAlloc>>20,
+				"heap_objects", m.HeapObjects,
+				"sys_mb", m.Sys>>20,
+				"gc_runs", m.NumGC,
+				"gc_pause_total", time.Duration(m.PauseTotalNs),
+			)
+		}
+	}()
+}
@@ -0,0 +1,162 @@
+package dmsparse
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+type Clinic struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	RawAddress string `json:"raw_address"`
+	Phone      string `json:"phone"`
+	Address    string `json:"address,omitempty"`
+	City       string `json:"city,omitempty"`
+	// District is the administrative district of the city, e.g. "Центральный административный округ".
+	District  string `json:"district,omitempty"`
+	Precision string `json:"precision,omitempty"`
+	// Confidence is how much clinic's points can be trusted, from 0 to 1.
+	Confidence float64 `json:"confidence,omitempty"`
+	// Points are clinic's coordinates as [lat, lon] pair.
+	Points []float64 `json:"points"`
+	// Categories are clinic's categories by its name, e.g. dental or pediatric.
+	Categories []string `json:"categories,omitempty"`
+	// Programs are the DMS programs, e.g. tiers of the policy, that cover the clinic.
+	Programs []string `json:"programs,omitempty"`
+	// License is clinic's medical license, found in the license registry, if the dataset was enriched with it.
+	License *License `json:"license,omitempty"`
+	// Place is clinic's card in Yandex Maps organizations directory, if the dataset was enriched with it.
+	Place *Place `json:"place,omitempty"`
+	// Firm is clinic's card in 2GIS directory, if the dataset was enriched with it.
+	Firm *Firm `json:"firm,omitempty"`
+	// Travel is the travel time to the clinic, if the dataset was enriched with it.
+	Travel *Travel `json:"travel,omitempty"`
+}
+
+// License is a medical license of the clinic's legal entity.
+type License struct {
+	Number string `json:"number"`
+	// Status is the status of the license as written in the registry, e.g. "действующая".
+	Status string `json:"status"`
+	// Active is false, if the license was terminated or suspended.
+	Active bool   `json:"active"`
+	INN    string `json:"inn,omitempty"`
+}
+
+// Place is clinic's card in an organizations directory.
+type Place struct {
+	ID      string  `json:"id"`
+	Rating  float64 `json:"rating,omitempty"`
+	Reviews int     `json:"reviews,omitempty"`
+	// Hours are the opening hours as written in the card, e.g. "пн-пт 8:00–20:00; сб 9:00–15:00".
+	Hours string `json:"hours,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// Firm is clinic's card in 2GIS directory, that locates the clinic within its building.
+type Firm struct {
+	ID       string `json:"id"`
+	Entrance *Point `json:"entrance,omitempty"`
+	// Floor is the location within the building as written in the card, e.g. "3 этаж".
+	Floor     string `json:"floor,omitempty"`
+	PhotosURL string `json:"photos_url,omitempty"`
+}
+
+// Travel is the travel time to the clinic by routes, rather than straight-line distance.
+type Travel struct {
+	// Metro is the name of the metro station, nearest to the clinic.
+	Metro string `json:"metro,omitempty"`
+	// WalkMinutes is the walking time from Metro.
+	WalkMinutes int `json:"walk_minutes,omitempty"`
+	// DriveMinutes is the driving time from the reference point, e.g. the office.
+	DriveMinutes int `json:"drive_minutes,omitempty"`
+}
+
+// Point is a point in degrees.
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// LatLon returns clinic's coordinates, if clinic was geocoded.
+func (cc *Clinic) LatLon() (lat, lon float64, ok bool) {
+	if len(cc.Points) != 2 {
+		return 0, 0, false
+	}
+	return cc.Points[0], cc.Points[1], true
+}
+
+// ClinicID returns a stable identifier of a clinic, derived from its name and raw address.
+func ClinicID(cc *Clinic) string {
+	h := sha1.Sum([]byte(cc.Name + "\n" + cc.RawAddress))
+	return hex.EncodeToString(h[:6])
+}
+
+// SplitPhones splits raw phone string into separate phone numbers.
+func SplitPhones(s string) []string {
+	var phones []string
+	for _, p := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		if p = strings.TrimSpace(p); p != "" {
+			phones = append(phones, p)
+		}
+	}
+	return phones
+}
+
+// clinicJSON is Clinic without json methods, to not recurse in MarshalJSON.
+type clinicJSON Clinic
+
+// MarshalJSON encodes clinic with points in lat, lon order and with explicit lat and lon fields.
+func (cc *Clinic) MarshalJSON() ([]byte, error) {
+	return cc.marshalJSON(false)
+}
+
+// LonLat wraps clinic, so it's encoded to json with points in lon, lat order.
+type LonLat struct {
+	*Clinic
+}
+
+func (c LonLat) MarshalJSON() ([]byte, error) {
+	return c.Clinic.marshalJSON(true)
+}
+
+func (cc *Clinic) marshalJSON(lonlat bool) ([]byte, error) {
+	v := struct {
+		*clinicJSON
+		Points []float64 `json:"points"`
+		Lat    *float64  `json:"lat,omitempty"`
+		Lon    *float64  `json:"lon,omitempty"`
+	}{
+		clinicJSON: (*clinicJSON)(cc),
+	}
+	if lat, lon, ok := cc.LatLon(); ok {
+		if lonlat {
+			v.Points = []float64{lon, lat}
+		} else {
+			v.Points = []float64{lat, lon}
+		}
+		v.Lat, v.Lon = &lat, &lon
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes clinic. If lat and lon fields are present, they take precedence over points,
+// as the order of points depends on how the dataset was generated.
+func (cc *Clinic) UnmarshalJSON(data []byte) error {
+	v := struct {
+		*clinicJSON
+		Lat *float64 `json:"lat"`
+		Lon *float64 `json:"lon"`
+	}{
+		clinicJSON: (*clinicJSON)(cc),
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Lat != nil && v.Lon != nil {
+		cc.Points = []float64{*v.Lat, *v.Lon}
+	}
+	return nil
+}
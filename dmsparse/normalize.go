@@ -0,0 +1,39 @@
+package dmsparse
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Normalize collapses repeated whitespace in clinic's text fields, joins its phones with ", ",
+// and fills in the ID, if it's missing. IDs, that are already set, are kept, so references to
+// the clinic stay valid.
+func Normalize(cc *Clinic) {
+	cc.Name = collapseSpaces(cc.Name)
+	cc.RawAddress = collapseSpaces(cc.RawAddress)
+	cc.Address = collapseSpaces(cc.Address)
+	cc.City = collapseSpaces(cc.City)
+
+	phones := SplitPhones(cc.Phone)
+	for i, p := range phones {
+		phones[i] = collapseSpaces(p)
+	}
+	cc.Phone = strings.Join(phones, ", ")
+
+	if cc.ID == "" {
+		cc.ID = ClinicID(cc)
+	}
+}
+
+// AddressKey normalizes address for comparison regardless of case and punctuation, e.g.
+// "г.Москва, ул. Тверская" and "г. Москва ул Тверская" have the same key.
+func AddressKey(address string) string {
+	words := strings.FieldsFunc(strings.ToLower(address), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.ReplaceAll(strings.Join(words, " "), "ё", "е")
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
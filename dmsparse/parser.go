@@ -0,0 +1,120 @@
+// Package dmsparse parses the list of clinics from VTB DMS programme text document.
+package dmsparse
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	_MODE_NONE = iota
+	_MODE_SECTION
+	_MODE_NAME
+	_MODE_ADDRESS
+	_MODE_PHONE
+)
+
+type parser struct {
+	nextMode int
+	// program is the title of the current section, i.e. the DMS program, that covers its clinics.
+	program string
+	emit    func(cc *Clinic) error
+}
+
+// Parse reads clinics from the text document. Clinics are separated with blank lines, each
+// clinic is a name, an address and a phone line. Clinics are grouped into numbered sections by DMS
+// program, e.g. "1. "Поликлиника" со стоматологией", and a clinic, that is listed in several sections,
+// is returned once with all of its programs.
+func Parse(f io.Reader) ([]*Clinic, error) {
+	var (
+		clinics []*Clinic
+		seen    = make(map[string]*Clinic)
+	)
+	err := ParseFunc(f, func(cc *Clinic) error {
+		if first, ok := seen[cc.ID]; ok {
+			MergePrograms(first, cc)
+			return nil
+		}
+		seen[cc.ID] = cc
+		clinics = append(clinics, cc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return clinics, nil
+}
+
+// MergePrograms adds the programs of the other listing of the clinic to cc.
+func MergePrograms(cc, other *Clinic) {
+	for _, p := range other.Programs {
+		if !slices.Contains(cc.Programs, p) {
+			cc.Programs = append(cc.Programs, p)
+		}
+	}
+}
+
+// ParseFunc reads clinics from the text document as Parse does, and calls fn for each clinic as soon
+// as it's read, so the document doesn't have to fit in memory. It stops at the first error of fn.
+// Unlike Parse, it calls fn for each listing of a clinic in several sections.
+func ParseFunc(f io.Reader, fn func(cc *Clinic) error) error {
+	p := parser{emit: fn}
+	return p.Parse(f)
+}
+
+func (p *parser) Parse(f io.Reader) error {
+	var cc Clinic
+	r := bufio.NewScanner(f)
+	for r.Scan() {
+		line := r.Text()
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			c := cc
+			c.ID = ClinicID(&c)
+			if p.program != "" {
+				c.Programs = []string{p.program}
+			}
+			cc = Clinic{}
+			if err := p.emit(&c); err != nil {
+				return err
+			}
+			p.nextMode = _MODE_NAME
+			continue
+		} else if isSection(line) {
+			// section
+			_, p.program, _ = strings.Cut(line, ".")
+			p.program = strings.TrimSpace(p.program)
+			p.nextMode = _MODE_NAME
+			continue
+		}
+
+		switch p.nextMode {
+		case _MODE_NAME:
+			cc.Name = line
+			p.nextMode = _MODE_ADDRESS
+		case _MODE_ADDRESS:
+			cc.RawAddress = line
+			p.nextMode = _MODE_PHONE
+		case _MODE_PHONE:
+			cc.Phone = line
+		}
+	}
+	return r.Err()
+}
+
+func isSection(line string) bool {
+	if len(line) < 3 {
+		return false
+	}
+	a, b, c := line[0], line[1], line[2]
+	if unicode.IsNumber(rune(a)) {
+		if b == '.' || (unicode.IsNumber(rune(b)) && c == '.') {
+			return true
+		}
+	}
+	return false
+}
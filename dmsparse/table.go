@@ -0,0 +1,227 @@
+package dmsparse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// TableFields are the fields of a clinic, that are read from the columns of a tabular input.
+var TableFields = []string{"id", "name", "address", "phone"}
+
+// defaultColumns are the names of the columns of a tabular input, in English or in Russian, that are
+// used, if a field isn't mapped explicitly.
+var defaultColumns = map[string][]string{
+	"id":      {"id"},
+	"name":    {"name", "наименование", "название", "клиника", "медицинское учреждение", "лпу"},
+	"address": {"address", "raw_address", "адрес", "адрес клиники"},
+	"phone":   {"phone", "телефон", "телефоны"},
+}
+
+// ColumnMap maps fields of a clinic to the columns of a tabular input, that are named in its header.
+// A field may be mapped to several columns, e.g. an address, split into the city, the street and
+// the house columns, whose values are joined with commas.
+type ColumnMap map[string][]string
+
+// ParseColumnMap parses column map from "field=column,..." string, e.g. "name=Клиника,address=Город+Адрес",
+// where several columns of a field are joined with plus.
+func ParseColumnMap(s string) (ColumnMap, error) {
+	m := make(ColumnMap)
+	for _, kv := range strings.Split(s, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		field, cols, ok := strings.Cut(kv, "=")
+		field = strings.TrimSpace(field)
+		if !ok || !slices.Contains(TableFields, field) {
+			return nil, fmt.Errorf("invalid column map %q, expected field=column, fields: %s", kv, strings.Join(TableFields, ", "))
+		}
+		for _, col := range strings.Split(cols, "+") {
+			if col = strings.TrimSpace(col); col == "" {
+				return nil, fmt.Errorf("invalid column map %q: empty column name", kv)
+			}
+			m[field] = append(m[field], col)
+		}
+	}
+	return m, nil
+}
+
+// ReadCSV reads clinics from CSV table, see ReadTable. The delimiter is either comma or semicolon.
+func ReadCSV(r io.Reader, m ColumnMap) ([]*Clinic, error) {
+	br := bufio.NewReader(r)
+	if bom, _ := br.Peek(3); string(bom) == "\ufeff" {
+		br.Discard(3)
+	}
+	cr := csv.NewReader(br)
+	if first, _ := br.Peek(4096); isSemicolonSeparated(first) {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return ReadTable(rows, m)
+}
+
+// isSemicolonSeparated reports whether the first line of CSV has more semicolons than commas, as
+// spreadsheets, exported with Russian locale, use semicolons.
+func isSemicolonSeparated(data []byte) bool {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	return bytes.Count(line, []byte(";")) > bytes.Count(line, []byte(","))
+}
+
+// ReadTable reads clinics from the rows of a table. The header is the first row, that names the columns
+// of the name and the address; rows above it, e.g. the title of the spreadsheet, are skipped. Fields,
+// which aren't in m, are read from the columns with default names. A header cell, merged over several
+// columns, names all of them, so the cells of the following columns with empty header are a part
+// of the field, too; the columns may be named in the second row of the header, below the merged cell.
+// A row without name continues the clinic above, as a name, merged over the rows of the clinic's
+// addresses, is exported only into the first of them. Empty rows are skipped.
+func ReadTable(rows [][]string, m ColumnMap) ([]*Clinic, error) {
+	start, cols, err := tableColumns(rows, m)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		clinics []*Clinic
+		prev    *Clinic
+	)
+	for _, row := range rows[start:] {
+		field := func(name string) string {
+			var parts []string
+			for _, i := range cols[name] {
+				if i < len(row) {
+					if v := collapseSpaces(row[i]); v != "" && !slices.Contains(parts, v) {
+						parts = append(parts, v)
+					}
+				}
+			}
+			return strings.Join(parts, ", ")
+		}
+		cc := &Clinic{
+			ID:         field("id"),
+			Name:       field("name"),
+			RawAddress: field("address"),
+			Phone:      field("phone"),
+		}
+		if cc.Name == "" && cc.RawAddress == "" {
+			continue
+		}
+		if cc.Name == "" && prev != nil {
+			cc.Name = prev.Name
+			if cc.Phone == "" {
+				cc.Phone = prev.Phone
+			}
+		}
+		prev = cc
+		if cc.ID == "" {
+			cc.ID = ClinicID(cc)
+		}
+		clinics = append(clinics, cc)
+	}
+	return clinics, nil
+}
+
+// tableColumns finds the header and returns the index of the first row after it along with the indices
+// of the columns of each field.
+func tableColumns(rows [][]string, m ColumnMap) (int, map[string][]int, error) {
+	labels := func(row []string) []string {
+		l := make([]string, len(row))
+		for i, h := range row {
+			l[i] = strings.ToLower(collapseSpaces(h))
+		}
+		return l
+	}
+	// columns are looked up in the second row of the header only, if the first row doesn't name them,
+	// as the title of the spreadsheet above the header looks like a header, merged over all columns
+	for _, useSub := range []bool{false, true} {
+		for n, row := range rows {
+			var (
+				header = labels(row)
+				sub    []string
+				// spanned are the columns under header cells, merged over several columns
+				spanned = make(map[int]bool)
+			)
+			for i := range header {
+				if header[i] != "" && i+1 < len(header) && header[i+1] == "" {
+					for j := i; j < len(header) && (j == i || header[j] == ""); j++ {
+						spanned[j] = true
+					}
+				}
+			}
+			// the next row is the second row of the header, if it only names the spanned columns
+			if n+1 < len(rows) {
+				sub = labels(rows[n+1])
+				for i, h := range sub {
+					if h != "" && !spanned[i] {
+						sub = nil
+						break
+					}
+				}
+			}
+			// indices of the columns, whose header is h, including the columns with empty header after it
+			columns := func(h string) []int {
+				var idx []int
+				for i := range header {
+					if header[i] != h {
+						continue
+					}
+					idx = append(idx, i)
+					for j := i + 1; j < len(header) && header[j] == ""; j++ {
+						idx = append(idx, j)
+					}
+				}
+				for i := range sub {
+					if useSub && len(idx) == 0 && sub[i] == h {
+						idx = append(idx, i)
+					}
+				}
+				return idx
+			}
+
+			cols := make(map[string][]int)
+			var missing []string
+			for _, field := range TableFields {
+				if names, ok := m[field]; ok {
+					for _, name := range names {
+						idx := columns(strings.ToLower(collapseSpaces(name)))
+						if len(idx) == 0 {
+							missing = append(missing, name)
+						}
+						cols[field] = append(cols[field], idx...)
+					}
+					continue
+				}
+				for _, name := range defaultColumns[field] {
+					if idx := columns(name); len(idx) > 0 {
+						cols[field] = idx
+						break
+					}
+				}
+			}
+			if len(missing) == 0 && len(cols["name"]) > 0 && len(cols["address"]) > 0 {
+				if slices.ContainsFunc(sub, func(h string) bool { return h != "" }) {
+					return n + 2, cols, nil
+				}
+				return n + 1, cols, nil
+			}
+		}
+	}
+
+	var want []string
+	for _, field := range []string{"name", "address"} {
+		if names, ok := m[field]; ok {
+			want = append(want, strings.Join(names, "+"))
+		} else {
+			want = append(want, field)
+		}
+	}
+	return 0, nil, fmt.Errorf("no header row with %s columns; map the columns with field=column, e.g. name=Клиника,address=Адрес", strings.Join(want, " and "))
+}
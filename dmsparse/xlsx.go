@@ -0,0 +1,198 @@
+package dmsparse
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ReadXLSX reads clinics from the first sheet of Excel workbook, see ReadTable. The cells of a merged
+// range all have the value of its top-left cell, so e.g. the name of a clinic, merged over the rows
+// of its addresses, is read for each of them.
+func ReadXLSX(r io.Reader, m ColumnMap) ([]*Clinic, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an xlsx workbook: %v", err)
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheet, err := xlsxFirstSheet(files)
+	if err != nil {
+		return nil, err
+	}
+	var strs []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := xlsxDecode(f, &sst); err != nil {
+			return nil, err
+		}
+		for _, it := range sst.Items {
+			strs = append(strs, it.String())
+		}
+	}
+	f, ok := files[sheet]
+	if !ok {
+		return nil, fmt.Errorf("no sheet %s in workbook", sheet)
+	}
+	var ws struct {
+		Rows []struct {
+			Ref   int `xml:"r,attr"`
+			Cells []struct {
+				Ref    string   `xml:"r,attr"`
+				Type   string   `xml:"t,attr"`
+				Value  string   `xml:"v"`
+				Inline xlsxText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+		Merges []struct {
+			Ref string `xml:"ref,attr"`
+		} `xml:"mergeCells>mergeCell"`
+	}
+	if err := xlsxDecode(f, &ws); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	set := func(row, col int, v string) {
+		for len(rows) <= row {
+			rows = append(rows, nil)
+		}
+		for len(rows[row]) <= col {
+			rows[row] = append(rows[row], "")
+		}
+		rows[row][col] = v
+	}
+	// references of rows and cells are optional, a row or a cell without one follows the previous one
+	row := -1
+	for _, wr := range ws.Rows {
+		if row++; wr.Ref > 0 {
+			row = wr.Ref - 1
+		}
+		col := -1
+		for _, c := range wr.Cells {
+			if col++; c.Ref != "" {
+				var ok bool
+				if row, col, ok = xlsxCellRef(c.Ref); !ok {
+					return nil, fmt.Errorf("invalid cell reference %q", c.Ref)
+				}
+			}
+			v := c.Value
+			switch c.Type {
+			case "s":
+				i, err := strconv.Atoi(v)
+				if err != nil || i < 0 || i >= len(strs) {
+					return nil, fmt.Errorf("cell %s: invalid shared string %q", c.Ref, v)
+				}
+				v = strs[i]
+			case "inlineStr":
+				v = c.Inline.String()
+			}
+			set(row, col, v)
+		}
+	}
+	for _, mc := range ws.Merges {
+		from, to, _ := strings.Cut(mc.Ref, ":")
+		r1, c1, ok1 := xlsxCellRef(from)
+		r2, c2, ok2 := xlsxCellRef(to)
+		if !ok1 || !ok2 || r1 >= len(rows) || c1 >= len(rows[r1]) {
+			continue
+		}
+		v := rows[r1][c1]
+		// a range may span the whole sheet, but only the rows with data matter
+		for row := r1; row <= min(r2, len(rows)-1); row++ {
+			for col := c1; col <= c2; col++ {
+				set(row, col, v)
+			}
+		}
+	}
+	return ReadTable(rows, m)
+}
+
+// xlsxText is a string, that is either plain, or rich text of several runs.
+type xlsxText struct {
+	Text string   `xml:"t"`
+	Runs []string `xml:"r>t"`
+}
+
+func (t xlsxText) String() string {
+	return t.Text + strings.Join(t.Runs, "")
+}
+
+// xlsxFirstSheet returns the path of the first sheet of the workbook within the archive.
+func xlsxFirstSheet(files map[string]*zip.File) (string, error) {
+	var wb struct {
+		Sheets []struct {
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	wbFile, ok1 := files["xl/workbook.xml"]
+	relsFile, ok2 := files["xl/_rels/workbook.xml.rels"]
+	if !ok1 || !ok2 {
+		return "", fmt.Errorf("not an xlsx workbook: no workbook.xml")
+	}
+	if err := xlsxDecode(wbFile, &wb); err != nil {
+		return "", err
+	}
+	if err := xlsxDecode(relsFile, &rels); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", fmt.Errorf("no sheets in workbook")
+	}
+	for _, rel := range rels.Rels {
+		if rel.ID != wb.Sheets[0].RelID {
+			continue
+		}
+		// targets are relative to xl/, or absolute within the archive
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", fmt.Errorf("no relationship %s of the first sheet", wb.Sheets[0].RelID)
+}
+
+func xlsxDecode(f *zip.File, v interface{}) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("%s: %v", f.Name, err)
+	}
+	return nil
+}
+
+// xlsxCellRef parses cell reference, e.g. "B12", into zero-based row and column.
+func xlsxCellRef(ref string) (row, col int, ok bool) {
+	i := 0
+	for ; i < len(ref) && 'A' <= ref[i] && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A'+1)
+	}
+	n, err := strconv.Atoi(ref[i:])
+	if i == 0 || err != nil || n < 1 {
+		return 0, 0, false
+	}
+	return n - 1, col - 1, true
+}
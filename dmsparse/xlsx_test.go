@@ -0,0 +1,115 @@
+package dmsparse
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// testWorkbook builds xlsx workbook of the files, that ReadXLSX reads.
+func testWorkbook(t *testing.T, sheet string) []byte {
+	t.Helper()
+	files := []struct{ name, body string }{
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Клиники" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings" Target="sharedStrings.xml"/>
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`},
+		{"xl/sharedStrings.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>Список клиник</t></si>
+<si><t>Клиника</t></si>
+<si><r><t>Ад</t></r><r><rPr><b/></rPr><t>рес</t></r></si>
+<si><t>Клиника «Доктор»</t></si>
+</sst>`},
+		{"xl/worksheets/sheet1.xml", sheet},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f.body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	// the title above the header, the name and the phone merged over the rows of two addresses,
+	// rich and inline strings, and a row and cells without references
+	const sheet = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c></row>
+<row r="2"><c r="A2" t="s"><v>1</v></c><c r="B2" t="s"><v>2</v></c><c r="C2" t="inlineStr"><is><t>Телефон</t></is></c></row>
+<row r="3"><c r="A3" t="s"><v>3</v></c><c r="B3" t="inlineStr"><is><t>г. Москва, ул. Новая, д. 1</t></is></c><c r="C3" t="inlineStr"><is><t>+7 495 000-00-00</t></is></c></row>
+<row r="4"><c r="B4" t="inlineStr"><is><t>г. Москва, ул. Старая, д. 2</t></is></c></row>
+<row><c t="inlineStr"><is><t>Поликлиника 2</t></is></c><c t="inlineStr"><is><t>г. Казань</t></is></c><c><v>88432000000</v></c></row>
+</sheetData>
+<mergeCells count="3"><mergeCell ref="A1:C1"/><mergeCell ref="A3:A4"/><mergeCell ref="C3:C4"/></mergeCells>
+</worksheet>`
+
+	clinics, err := ReadXLSX(bytes.NewReader(testWorkbook(t, sheet)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Clinic{
+		{Name: "Клиника «Доктор»", RawAddress: "г. Москва, ул. Новая, д. 1", Phone: "+7 495 000-00-00"},
+		{Name: "Клиника «Доктор»", RawAddress: "г. Москва, ул. Старая, д. 2", Phone: "+7 495 000-00-00"},
+		{Name: "Поликлиника 2", RawAddress: "г. Казань", Phone: "88432000000"},
+	}
+	for _, cc := range want {
+		cc.ID = ClinicID(cc)
+	}
+	if !reflect.DeepEqual(clinics, want) {
+		t.Errorf("ReadXLSX():\n got %+v\nwant %+v", clinics, want)
+	}
+}
+
+func TestReadXLSXErrors(t *testing.T) {
+	if _, err := ReadXLSX(bytes.NewReader([]byte("name,address\n")), nil); err == nil {
+		t.Errorf("ReadXLSX() of CSV: want error")
+	}
+
+	const sheet = `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>1</v></c><c r="B1" t="s"><v>2</v></c></row>
+<row r="2"><c r="A2" t="s"><v>42</v></c></row>
+</sheetData></worksheet>`
+	if _, err := ReadXLSX(bytes.NewReader(testWorkbook(t, sheet)), nil); err == nil {
+		t.Errorf("ReadXLSX() with invalid shared string: want error")
+	}
+}
+
+func TestXLSXCellRef(t *testing.T) {
+	tests := []struct {
+		ref      string
+		row, col int
+		ok       bool
+	}{
+		{"A1", 0, 0, true},
+		{"B12", 11, 1, true},
+		{"Z3", 2, 25, true},
+		{"AA10", 9, 26, true},
+		{"XFD1048576", 1048575, 16383, true},
+		{"12", 0, 0, false},
+		{"A0", 0, 0, false},
+		{"a1", 0, 0, false},
+	}
+	for _, tt := range tests {
+		row, col, ok := xlsxCellRef(tt.ref)
+		if row != tt.row || col != tt.col || ok != tt.ok {
+			t.Errorf("xlsxCellRef(%q) = %d, %d, %v, want %d, %d, %v", tt.ref, row, col, ok, tt.row, tt.col, tt.ok)
+		}
+	}
+}
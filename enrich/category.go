@@ -0,0 +1,107 @@
+package enrich
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// DefaultCategoryRules are the rules of categories, in the format of ReadRules.
+const DefaultCategoryRules = `
+dental: стоматолог, дентал, dental, зуб, ортодонт, имплант
+pediatric: детск, педиатр, ребен, дети, kids
+lab: лаборатор, анализ, инвитро, гемотест, хеликс, kdl, lab
+hospital: больниц, стационар, госпитал, hospital, роддом, родильн, перинатальн
+polyclinic: поликлиник, амбулатор, медицинский центр, медцентр, медицинская клиника, многопрофильн, семейн
+`
+
+// Categories tags clinics with categories, e.g. dental or pediatric, by keywords in their names, so
+// the map can filter clinics by category. A clinic may be in several categories, or in none.
+type Categories struct {
+	rules []categoryRule
+}
+
+type categoryRule struct {
+	category string
+	keywords []string
+}
+
+// NewCategories returns Categories with DefaultCategoryRules.
+func NewCategories() *Categories {
+	c := &Categories{}
+	if err := c.ReadRules(strings.NewReader(DefaultCategoryRules)); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// ReadRules reads rules, one per line as "category: keyword, keyword", and adds them to the rules:
+// keywords of a known category extend it, and a new category is added after the known ones. A keyword
+// matches the beginning of a word of clinic's name in any case, e.g. "стоматолог" matches
+// "Стоматологическая клиника", and may be several words. Empty lines and lines starting with # are skipped.
+func (c *Categories) ReadRules(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		category, keywords, ok := strings.Cut(line, ":")
+		category = strings.TrimSpace(category)
+		if !ok || category == "" {
+			return fmt.Errorf("line %d: expected category: keyword, keyword", lineno)
+		}
+		var rule *categoryRule
+		for i := range c.rules {
+			if c.rules[i].category == category {
+				rule = &c.rules[i]
+			}
+		}
+		if rule == nil {
+			c.rules = append(c.rules, categoryRule{category: category})
+			rule = &c.rules[len(c.rules)-1]
+		}
+		for _, kw := range strings.Split(keywords, ",") {
+			if kw = categoryText(kw); kw != "" {
+				rule.keywords = append(rule.keywords, kw)
+			}
+		}
+	}
+	return s.Err()
+}
+
+// Names returns the names of the categories in the order of the rules.
+func (c *Categories) Names() []string {
+	names := make([]string, len(c.rules))
+	for i, rule := range c.rules {
+		names[i] = rule.category
+	}
+	return names
+}
+
+func (c *Categories) Enrich(cc *dmsparse.Clinic) (bool, error) {
+	// spaces around the name make keywords match the beginnings of words only
+	name := " " + categoryText(cc.Name)
+	cc.Categories = nil
+	for _, rule := range c.rules {
+		for _, kw := range rule.keywords {
+			if strings.Contains(name, " "+kw) {
+				cc.Categories = append(cc.Categories, rule.category)
+				break
+			}
+		}
+	}
+	return len(cc.Categories) > 0, nil
+}
+
+// categoryText lowercases s, replaces ё with е and punctuation with spaces, and collapses spaces.
+func categoryText(s string) string {
+	s = strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
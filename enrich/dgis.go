@@ -0,0 +1,112 @@
+package enrich
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+const dgisAPI = "https://catalog.api.2gis.com/3.0/items"
+
+// DGIS attaches the firm card of the clinic in 2GIS directory, found with the Catalog API: the point
+// of the entrance, the floor and the link to the photos, as the building's point isn't enough to find
+// a clinic inside a large complex. Only geocoded clinics are looked up, the same way as with YandexPlaces.
+type DGIS struct {
+	// APIKey is the key of the Catalog API.
+	APIKey string
+	// Endpoint is the URL of the items method of the API. If empty, the 2GIS API is used.
+	Endpoint string
+	// Client makes requests to the API. If nil, the client with geocode.DefaultHTTPOptions is used.
+	Client *http.Client
+}
+
+func (d *DGIS) Enrich(cc *dmsparse.Clinic) (bool, error) {
+	lat, lon, ok := cc.LatLon()
+	if !ok {
+		return false, nil
+	}
+	vals := make(url.Values)
+	vals.Set("q", nameKey(cc.Name))
+	vals.Set("point", fmt.Sprintf("%f,%f", lon, lat))
+	vals.Set("radius", fmt.Sprint(maxCardDistance))
+	vals.Set("type", "branch")
+	vals.Set("fields", "items.point,items.links,items.address_comment")
+	vals.Set("key", d.APIKey)
+	slog.Debug("searching firm", "text", vals.Get("q"), "lat", lat, "lon", lon)
+
+	endpoint := d.Endpoint
+	if endpoint == "" {
+		endpoint = dgisAPI
+	}
+	var resp dgisResponse
+	if err := getJSON(d.Client, endpoint+"?"+vals.Encode(), &resp); err != nil {
+		return false, err
+	}
+	switch resp.Meta.Code {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// the API reports no results as 404
+		return false, nil
+	default:
+		return false, fmt.Errorf("bad response code: %d, %s", resp.Meta.Code, resp.Meta.Error.Message)
+	}
+
+	items := resp.Result.Items
+	best := nearestNamesake(cc.Name, lat, lon, len(items), func(i int) (string, float64, float64, bool) {
+		it := items[i]
+		return it.Name, it.Point.Lat, it.Point.Lon, it.Point.Lat != 0 || it.Point.Lon != 0
+	})
+	if best < 0 {
+		return false, nil
+	}
+
+	it := items[best]
+	cc.Firm = &dmsparse.Firm{
+		ID:        it.ID,
+		Floor:     it.AddressComment,
+		PhotosURL: "https://2gis.ru/firm/" + url.PathEscape(it.ID) + "/tab/photos",
+	}
+	for _, e := range it.Links.Entrances {
+		if len(e.Geometry.Points) == 0 {
+			continue
+		}
+		var p dmsparse.Point
+		if _, err := fmt.Sscanf(e.Geometry.Points[0], "POINT(%g %g)", &p.Lon, &p.Lat); err == nil {
+			cc.Firm.Entrance = &p
+			break
+		}
+	}
+	return true, nil
+}
+
+type dgisResponse struct {
+	Meta struct {
+		Code  int `json:"code"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"meta"`
+	Result struct {
+		Items []struct {
+			ID    string `json:"id"`
+			Name  string `json:"name"`
+			Point struct {
+				Lat float64 `json:"lat"`
+				Lon float64 `json:"lon"`
+			} `json:"point"`
+			// AddressComment is the location within the building, e.g. "3 этаж".
+			AddressComment string `json:"address_comment"`
+			Links          struct {
+				Entrances []struct {
+					Geometry struct {
+						// Points are WKT points, e.g. "POINT(37.61 55.75)".
+						Points []string `json:"points"`
+					} `json:"geometry"`
+				} `json:"entrances"`
+			} `json:"links"`
+		} `json:"items"`
+	} `json:"result"`
+}
@@ -0,0 +1,134 @@
+// Package enrich attaches data from external registries and directories to clinics of a dataset.
+package enrich
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/geocode"
+)
+
+// Enricher attaches data of a source to a clinic.
+type Enricher interface {
+	// Enrich looks the clinic up in the source and attaches the found data to it. It returns false,
+	// if the source has no data for the clinic.
+	Enrich(cc *dmsparse.Clinic) (bool, error)
+}
+
+// Result is the number of clinics, that an Enricher matched, or failed to look up.
+type Result struct {
+	Matched int
+	Failed  int
+}
+
+// Run enriches clinics with e, running up to concurrency lookups at once. Failed lookups are logged
+// and don't stop the run, as the enriched data is optional.
+func Run(e Enricher, clinics []*dmsparse.Clinic, concurrency int) Result {
+	var (
+		res  Result
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		jobs = make(chan *dmsparse.Clinic)
+	)
+	for i := 0; i < max(1, concurrency); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for cc := range jobs {
+				ok, err := e.Enrich(cc)
+				if err != nil {
+					slog.Warn("could not enrich clinic", "id", cc.ID, "name", cc.Name, "err", err)
+				}
+				mu.Lock()
+				switch {
+				case err != nil:
+					res.Failed++
+				case ok:
+					res.Matched++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, cc := range clinics {
+		jobs <- cc
+	}
+	close(jobs)
+	wg.Wait()
+	return res
+}
+
+// maxCardDistance is the distance in meters from the clinic's point, within which its card in a directory is looked for.
+const maxCardDistance = 150
+
+var defaultClient = geocode.NewHTTPClient(geocode.DefaultHTTPOptions)
+
+// getJSON requests url with client, or with defaultClient if it's nil, and decodes json response into v.
+// Request errors don't have the query of url, which has the API key.
+func getJSON(client *http.Client, url string, v interface{}) error {
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return geocode.RedactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("%w: %s", geocode.ErrThrottled, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		r, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("bad response status: %s, %s", resp.Status, r)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// nearestNamesake returns the index of the directory card, that matches the clinic at lat, lon best,
+// or -1: the card must be within maxCardDistance of the clinic and share a word of its name. Of such
+// cards, the one sharing the most words wins, then the nearest one. The n cards are given by card.
+func nearestNamesake(name string, lat, lon float64, n int, card func(i int) (name string, lat, lon float64, ok bool)) int {
+	var (
+		best      = -1
+		bestScore float64
+		bestDist  float64
+		words     = strings.Fields(nameKey(name))
+	)
+	for i := 0; i < n; i++ {
+		cardName, cardLat, cardLon, ok := card(i)
+		if !ok {
+			continue
+		}
+		dist := geocode.Distance(lat, lon, cardLat, cardLon)
+		score := wordOverlap(words, strings.Fields(nameKey(cardName)))
+		if dist > maxCardDistance || score == 0 {
+			continue
+		}
+		if best < 0 || score > bestScore || score == bestScore && dist < bestDist {
+			best, bestScore, bestDist = i, score, dist
+		}
+	}
+	return best
+}
+
+// wordOverlap returns the share of words of a, that are in b, too.
+func wordOverlap(a, b []string) float64 {
+	if len(a) == 0 {
+		return 0
+	}
+	var n int
+	for _, w := range a {
+		if slices.Contains(b, w) {
+			n++
+		}
+	}
+	return float64(n) / float64(len(a))
+}
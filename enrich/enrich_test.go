@@ -0,0 +1,37 @@
+package enrich
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+var errTransport = errors.New("connection refused")
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errTransport
+}
+
+func TestEnrichErrorsDontLeakAPIKey(t *testing.T) {
+	client := &http.Client{Transport: failingTransport{}}
+	cc := &dmsparse.Clinic{ID: "abc", Name: "Клиника", Points: []float64{55.75, 37.61}}
+	enrichers := map[string]Enricher{
+		"places":     &YandexPlaces{APIKey: "SECRET123", Client: client},
+		"static-map": &StaticMap{Provider: "yandex", APIKey: "SECRET123", Dir: t.TempDir(), Width: 600, Height: 400, Zoom: 16, Client: client},
+	}
+	for name, e := range enrichers {
+		_, err := e.Enrich(cc)
+		if err == nil {
+			t.Errorf("%s: want error", name)
+			continue
+		}
+		if strings.Contains(err.Error(), "SECRET123") {
+			t.Errorf("%s: error leaks API key: %v", name, err)
+		}
+	}
+}
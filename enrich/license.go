@@ -0,0 +1,169 @@
+package enrich
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// licenseColumns are the names of the columns of the license registry, in English or as in
+// Rosdravnadzor's open data export.
+var licenseColumns = map[string][]string{
+	"number":  {"number", "номер лицензии", "номер"},
+	"status":  {"status", "статус", "статус лицензии"},
+	"inn":     {"inn", "инн"},
+	"name":    {"name", "наименование", "полное наименование", "наименование организации"},
+	"address": {"address", "адрес", "адрес места осуществления деятельности"},
+}
+
+// activeStatuses are the statuses of licenses, that are in force.
+var activeStatuses = []string{"действует", "действующая", "active"}
+
+// Licenses is the registry of medical licenses, e.g. Rosdravnadzor's open registry, exported to CSV.
+// A license lists the places of the licensed activity, so a clinic is matched by the address of
+// the activity: by its name and address, or, if it's the only license at the address, by the
+// address alone, or, if it's the only license of the organization, by the name alone.
+type Licenses struct {
+	byNameAddress map[string][]*dmsparse.License
+	byAddress     map[string][]*dmsparse.License
+	byName        map[string][]*dmsparse.License
+}
+
+// ReadLicenses reads the license registry in CSV format with a header row, naming number, status,
+// inn, name and address columns. The delimiter is either comma or semicolon.
+func ReadLicenses(r io.Reader) (*Licenses, error) {
+	br := bufio.NewReader(r)
+	if bom, _ := br.Peek(3); string(bom) == "\ufeff" {
+		br.Discard(3)
+	}
+	cr := csv.NewReader(br)
+	if first, _ := br.Peek(4096); isSemicolonSeparated(first) {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read license registry header: %v", err)
+	}
+	cols := make(map[string]int)
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		for col, names := range licenseColumns {
+			if _, ok := cols[col]; !ok && slices.Contains(names, h) {
+				cols[col] = i
+			}
+		}
+	}
+	for _, col := range []string{"number", "status", "name", "address"} {
+		if _, ok := cols[col]; !ok {
+			return nil, fmt.Errorf("license registry has no %s column", col)
+		}
+	}
+
+	l := &Licenses{
+		byNameAddress: make(map[string][]*dmsparse.License),
+		byAddress:     make(map[string][]*dmsparse.License),
+		byName:        make(map[string][]*dmsparse.License),
+	}
+	field := func(rec []string, col string) string {
+		if i, ok := cols[col]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read license registry: %v", err)
+		}
+		lic := &dmsparse.License{
+			Number: field(rec, "number"),
+			Status: field(rec, "status"),
+			INN:    field(rec, "inn"),
+		}
+		lic.Active = slices.Contains(activeStatuses, strings.ToLower(lic.Status))
+		name, address := nameKey(field(rec, "name")), dmsparse.AddressKey(field(rec, "address"))
+		if lic.Number == "" || name == "" {
+			continue
+		}
+		l.byName[name] = append(l.byName[name], lic)
+		if address != "" {
+			l.byAddress[address] = append(l.byAddress[address], lic)
+			l.byNameAddress[name+"\n"+address] = append(l.byNameAddress[name+"\n"+address], lic)
+		}
+	}
+	return l, nil
+}
+
+// Len returns the number of licenses, indexed by name.
+func (l *Licenses) Len() (n int) {
+	for _, lics := range l.byName {
+		n += len(lics)
+	}
+	return n
+}
+
+func (l *Licenses) Enrich(cc *dmsparse.Clinic) (bool, error) {
+	name, address := nameKey(cc.Name), dmsparse.AddressKey(cc.RawAddress)
+	lic := pickLicense(l.byNameAddress[name+"\n"+address])
+	if lic == nil {
+		lic = pickLicense(l.byAddress[address])
+	}
+	if lic == nil {
+		lic = pickLicense(l.byName[name])
+	}
+	if lic == nil {
+		return false, nil
+	}
+	cc.License = lic
+	return true, nil
+}
+
+// pickLicense returns the license of a single organization: the active one, if the organization was
+// licensed several times. It returns nil, if the licenses belong to different organizations.
+func pickLicense(lics []*dmsparse.License) *dmsparse.License {
+	var pick *dmsparse.License
+	for _, lic := range lics {
+		if pick != nil && lic.INN != pick.INN {
+			return nil
+		}
+		if pick == nil || lic.Active && !pick.Active {
+			pick = lic
+		}
+	}
+	return pick
+}
+
+// legalForms are abbreviations of legal forms, that are omitted when names are compared, as the
+// price list and the registry spell them differently, if at all.
+var legalForms = []string{"ооо", "оао", "зао", "пао", "ао", "нао", "ип", "гбуз", "гуз", "фгбу", "чуз", "ано"}
+
+// nameKey normalizes organization name for comparison regardless of case, quotes and legal form,
+// e.g. `ООО "Клиника Здоровье"` and "Клиника «Здоровье»" have the same key.
+func nameKey(name string) string {
+	var words []string
+	for _, w := range strings.Fields(dmsparse.AddressKey(name)) {
+		if !slices.Contains(legalForms, w) {
+			words = append(words, w)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// isSemicolonSeparated reports whether the first line of CSV has more semicolons than commas, as
+// spreadsheets export CSV with semicolons in Russian locale.
+func isSemicolonSeparated(data []byte) bool {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	return bytes.Count(line, []byte(";")) > bytes.Count(line, []byte(","))
+}
@@ -0,0 +1,105 @@
+package enrich
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+const yandexPlacesAPI = "https://search-maps.yandex.ru/v1/"
+
+// YandexPlaces attaches the card of the clinic in Yandex Maps organizations directory, found with
+// the Places (organization search) API: rating, number of reviews and opening hours. Only geocoded
+// clinics are looked up: the card must be near the clinic's point and share a word of its name.
+type YandexPlaces struct {
+	// APIKey is the key of the Places API, which is separate from the geocoder's key.
+	APIKey string
+	// Endpoint is the URL of the API. If empty, the Yandex API is used.
+	Endpoint string
+	// Client makes requests to the API. If nil, the client with geocode.DefaultHTTPOptions is used.
+	Client *http.Client
+}
+
+func (y *YandexPlaces) Enrich(cc *dmsparse.Clinic) (bool, error) {
+	lat, lon, ok := cc.LatLon()
+	if !ok {
+		return false, nil
+	}
+	features, err := y.search(nameKey(cc.Name), lat, lon)
+	if err != nil {
+		return false, err
+	}
+
+	best := nearestNamesake(cc.Name, lat, lon, len(features), func(i int) (string, float64, float64, bool) {
+		f := features[i]
+		if len(f.Geometry.Coordinates) != 2 {
+			return "", 0, 0, false
+		}
+		// the API responds with lon, lat pairs
+		return f.Properties.CompanyMetaData.Name, f.Geometry.Coordinates[1], f.Geometry.Coordinates[0], true
+	})
+	if best < 0 {
+		return false, nil
+	}
+
+	meta := features[best].Properties.CompanyMetaData
+	cc.Place = &dmsparse.Place{
+		ID:    meta.ID,
+		Hours: meta.Hours.Text,
+		URL:   meta.URL,
+	}
+	if meta.Rating != nil {
+		cc.Place.Rating = meta.Rating.Score
+		cc.Place.Reviews = meta.Rating.Reviews
+	}
+	return true, nil
+}
+
+func (y *YandexPlaces) search(text string, lat, lon float64) ([]placeFeature, error) {
+	vals := make(url.Values)
+	vals.Set("text", text)
+	vals.Set("type", "biz")
+	vals.Set("lang", "ru_RU")
+	vals.Set("ll", fmt.Sprintf("%f,%f", lon, lat))
+	vals.Set("spn", "0.005,0.005")
+	vals.Set("rspn", "1")
+	vals.Set("results", "10")
+	vals.Set("apikey", y.APIKey)
+	slog.Debug("searching place", "text", text, "lat", lat, "lon", lon)
+
+	endpoint := y.Endpoint
+	if endpoint == "" {
+		endpoint = yandexPlacesAPI
+	}
+	var placesResp struct {
+		Features []placeFeature `json:"features"`
+	}
+	if err := getJSON(y.Client, endpoint+"?"+vals.Encode(), &placesResp); err != nil {
+		return nil, err
+	}
+	return placesResp.Features, nil
+}
+
+type placeFeature struct {
+	Geometry struct {
+		Coordinates []float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Properties struct {
+		CompanyMetaData struct {
+			ID    string `json:"id"`
+			Name  string `json:"name"`
+			URL   string `json:"url"`
+			Hours struct {
+				Text string `json:"text"`
+			} `json:"Hours"`
+			// Rating isn't in every response, e.g. of a clinic without reviews.
+			Rating *struct {
+				Score   float64 `json:"score"`
+				Reviews int     `json:"reviews"`
+			} `json:"Rating"`
+		} `json:"CompanyMetaData"`
+	} `json:"properties"`
+}
@@ -0,0 +1,128 @@
+package enrich
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/geocode"
+)
+
+// StaticMapProviders are the static map APIs, StaticMap fetches images from.
+var StaticMapProviders = []string{"osm", "yandex"}
+
+const (
+	osmStaticMapAPI    = "https://staticmap.openstreetmap.de/staticmap.php"
+	yandexStaticMapAPI = "https://static-maps.yandex.ru/v1"
+)
+
+// StaticMap fetches a static map image, centered on the clinic's point with a marker on it, and stores it
+// into Dir as <id>.png, for emails and printed materials, where an interactive map isn't possible.
+// Only geocoded clinics are fetched; the clinic itself isn't changed.
+type StaticMap struct {
+	// Provider is the static map API: "osm" (the default) or "yandex", which requires APIKey.
+	Provider string
+	APIKey   string
+	// Endpoint is the URL of the API. If empty, the provider's public API is used.
+	Endpoint string
+	Dir      string
+	// Width and Height are the size of the image in pixels; Yandex limits it to 650x450.
+	Width, Height int
+	Zoom          int
+	// Client makes requests to the API. If nil, the client with geocode.DefaultHTTPOptions is used.
+	Client *http.Client
+}
+
+func (m *StaticMap) Enrich(cc *dmsparse.Clinic) (bool, error) {
+	lat, lon, ok := cc.LatLon()
+	if !ok {
+		return false, nil
+	}
+	u, err := m.url(lat, lon)
+	if err != nil {
+		return false, err
+	}
+	slog.Debug("fetching static map", "id", cc.ID, "lat", lat, "lon", lon)
+
+	client := m.Client
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Get(u)
+	if err != nil {
+		// the URL has the API key
+		return false, geocode.RedactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return false, fmt.Errorf("%w: %s", geocode.ErrThrottled, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		r, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, fmt.Errorf("bad response status: %s, %s", resp.Status, r)
+	}
+	// an error page must not be stored as the map
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/") {
+		return false, fmt.Errorf("bad response content type: %q", ct)
+	}
+	if err := writeFileAtomic(filepath.Join(m.Dir, cc.ID+".png"), resp.Body); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *StaticMap) url(lat, lon float64) (string, error) {
+	var (
+		vals     = make(url.Values)
+		endpoint string
+		slat     = strconv.FormatFloat(lat, 'f', -1, 64)
+		slon     = strconv.FormatFloat(lon, 'f', -1, 64)
+	)
+	switch m.Provider {
+	case "", "osm":
+		endpoint = osmStaticMapAPI
+		vals.Set("center", slat+","+slon)
+		vals.Set("zoom", strconv.Itoa(m.Zoom))
+		vals.Set("size", fmt.Sprintf("%dx%d", m.Width, m.Height))
+		vals.Set("markers", slat+","+slon+",red-pushpin")
+	case "yandex":
+		endpoint = yandexStaticMapAPI
+		vals.Set("ll", slon+","+slat)
+		vals.Set("z", strconv.Itoa(m.Zoom))
+		vals.Set("size", fmt.Sprintf("%d,%d", m.Width, m.Height))
+		vals.Set("pt", slon+","+slat+",pm2rdm")
+		vals.Set("lang", "ru_RU")
+		vals.Set("apikey", m.APIKey)
+	default:
+		return "", fmt.Errorf("unknown static map provider: %q", m.Provider)
+	}
+	if m.Endpoint != "" {
+		endpoint = m.Endpoint
+	}
+	return endpoint + "?" + vals.Encode(), nil
+}
+
+// writeFileAtomic writes r to the file at path, replacing it only if r was read completely.
+func writeFileAtomic(path string, r io.Reader) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,192 @@
+package enrich
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/geocode"
+)
+
+// Default routers are public OSRM instances; they are rate limited, so heavy runs should use their own.
+const (
+	DefaultWalkRouter  = "https://routing.openstreetmap.de/routed-foot"
+	DefaultDriveRouter = "https://router.project-osrm.org"
+)
+
+// maxMetroDistance is the distance in meters from the clinic, within which the metro station must be,
+// so clinics outside of the metro's reach don't get the walking time from a distant station.
+const maxMetroDistance = 3000
+
+// Station is a metro station.
+type Station struct {
+	Name string
+	Lat  float64
+	Lon  float64
+}
+
+// ReadStations reads metro stations in the format of HeadHunter metro API, e.g. https://api.hh.ru/metro/1
+// for a single city, or https://api.hh.ru/metro for all of them.
+func ReadStations(r io.Reader) ([]Station, error) {
+	type city struct {
+		Lines []struct {
+			Stations []struct {
+				Name string   `json:"name"`
+				Lat  *float64 `json:"lat"`
+				Lng  *float64 `json:"lng"`
+			} `json:"stations"`
+		} `json:"lines"`
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var cities []city
+	if err := json.Unmarshal(data, &cities); err != nil {
+		var c city
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, err
+		}
+		cities = []city{c}
+	}
+
+	var stations []Station
+	for _, c := range cities {
+		for _, l := range c.Lines {
+			for _, st := range l.Stations {
+				if st.Lat == nil || st.Lng == nil {
+					continue
+				}
+				stations = append(stations, Station{Name: st.Name, Lat: *st.Lat, Lon: *st.Lng})
+			}
+		}
+	}
+	if len(stations) == 0 {
+		return nil, fmt.Errorf("no stations")
+	}
+	return stations, nil
+}
+
+// Travel attaches the travel time to the clinic, routed with OSRM: the walking time from the nearest
+// metro station and the driving time from the reference point, as straight-line distance misleads,
+// e.g. across a river. Only geocoded clinics are routed.
+type Travel struct {
+	// Stations are metro stations. If empty, the walking time isn't attached.
+	Stations []Station
+	// DriveFrom is the reference point. If nil, the driving time isn't attached.
+	DriveFrom *dmsparse.Point
+	// WalkRouter and DriveRouter are the URLs of OSRM instances with foot and car profiles.
+	WalkRouter  string
+	DriveRouter string
+	// Client makes requests to the routers. If nil, the client with geocode.DefaultHTTPOptions is used.
+	Client *http.Client
+}
+
+func (t *Travel) Enrich(cc *dmsparse.Clinic) (bool, error) {
+	lat, lon, ok := cc.LatLon()
+	if !ok {
+		return false, nil
+	}
+	var travel dmsparse.Travel
+	if st := nearestStation(t.Stations, cc.RawAddress, lat, lon); st != nil {
+		minutes, err := t.route(t.WalkRouter, st.Lat, st.Lon, lat, lon)
+		if err != nil {
+			return false, fmt.Errorf("walk from %s: %v", st.Name, err)
+		}
+		travel.Metro, travel.WalkMinutes = st.Name, minutes
+	}
+	if t.DriveFrom != nil {
+		minutes, err := t.route(t.DriveRouter, t.DriveFrom.Lat, t.DriveFrom.Lon, lat, lon)
+		if err != nil {
+			return false, fmt.Errorf("drive: %v", err)
+		}
+		travel.DriveMinutes = minutes
+	}
+	if travel == (dmsparse.Travel{}) {
+		return false, nil
+	}
+	cc.Travel = &travel
+	return true, nil
+}
+
+// metroRe matches the metro station in the raw address, e.g. "м. Парк Культуры (550 м)".
+var metroRe = regexp.MustCompile(`(?:^|[\s,])(?:м\.|метро)\s*([^,(]+)`)
+
+// nearestStation returns the station, named in the raw address, or the nearest one, if the address
+// names none, or nil, if the station is farther than maxMetroDistance.
+func nearestStation(stations []Station, rawAddress string, lat, lon float64) *Station {
+	var (
+		best     *Station
+		bestDist float64
+	)
+	if m := metroRe.FindStringSubmatch(rawAddress); m != nil {
+		name := strings.TrimSpace(m[1])
+		for i, st := range stations {
+			if !strings.EqualFold(st.Name, name) {
+				continue
+			}
+			// the name is shared by stations of different cities and lines
+			if dist := geocode.Distance(lat, lon, st.Lat, st.Lon); best == nil || dist < bestDist {
+				best, bestDist = &stations[i], dist
+			}
+		}
+	}
+	if best == nil {
+		for i, st := range stations {
+			if dist := geocode.Distance(lat, lon, st.Lat, st.Lon); best == nil || dist < bestDist {
+				best, bestDist = &stations[i], dist
+			}
+		}
+	}
+	if best == nil || bestDist > maxMetroDistance {
+		return nil
+	}
+	return best
+}
+
+// route returns the travel time in minutes between the points with OSRM route service at router.
+func (t *Travel) route(router string, fromLat, fromLon, toLat, toLon float64) (int, error) {
+	// an OSRM instance serves the single profile, it was built with, whatever the profile in the URL
+	url := fmt.Sprintf("%s/route/v1/driving/%f,%f;%f,%f?overview=false",
+		strings.TrimSuffix(router, "/"), fromLon, fromLat, toLon, toLat)
+	slog.Debug("routing", "url", url)
+
+	client := t.Client
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return 0, fmt.Errorf("%w: %s", geocode.ErrThrottled, resp.Status)
+	}
+
+	// OSRM responds to unroutable points with 400 and the reason in the code
+	var routeResp struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Routes  []struct {
+			Duration float64 `json:"duration"`
+		} `json:"routes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&routeResp); err != nil {
+		return 0, fmt.Errorf("bad response: %s, %v", resp.Status, err)
+	}
+	if routeResp.Code != "Ok" {
+		return 0, fmt.Errorf("%s: %s", routeResp.Code, routeResp.Message)
+	}
+	if len(routeResp.Routes) == 0 {
+		return 0, fmt.Errorf("no routes")
+	}
+	// minutes are rounded up, so a clinic next to the station is a minute away, rather than zero
+	return max(1, int(math.Ceil(routeResp.Routes[0].Duration/60))), nil
+}
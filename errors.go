@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Exit codes of the commands.
+const (
+	exitFailure  = 1 // unclassified error
+	exitUsage    = 2 // invalid flags or configuration
+	exitInput    = 3 // input can't be read or parsed
+	exitGeocode  = 4 // more clinics failed geocoding than -max-failures allows
+	exitOutput   = 5 // output can't be written
+	exitInvalid  = 6 // the dataset violates validation rules
+	exitVanished = 7 // more clinics vanished since the previous dataset than -max-vanished allows
+	exitCorrupt  = 8 // an artifact doesn't match its checksum or signature
+)
+
+// exitError is an error, that terminates the command with the exit code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+func usageError(err error) error  { return &exitError{exitUsage, err} }
+func inputError(err error) error  { return &exitError{exitInput, err} }
+func outputError(err error) error { return &exitError{exitOutput, err} }
+
+// exitCode returns the exit code for the error returned by a command.
+func exitCode(err error) int {
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return exitFailure
+}
+
+// failurePolicy is the maximum number of clinics, that may fail, e.g. fail geocoding, either absolute,
+// e.g. "10", or relative to the number of clinics, e.g. "5%". A negative limit allows any number of failures.
+type failurePolicy struct {
+	limit   float64
+	percent bool
+}
+
+func parseFailurePolicy(s string) (failurePolicy, error) {
+	var p failurePolicy
+	v, ok := strings.CutSuffix(s, "%")
+	p.percent = ok
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return p, fmt.Errorf("invalid max failures %q", s)
+	}
+	p.limit = n
+	return p, nil
+}
+
+// Check returns an error if failed of total clinics exceed the limit.
+func (p failurePolicy) Check(failed, total int) error {
+	if p.Exceeded(failed, total) {
+		return &exitError{exitGeocode, fmt.Errorf("%d of %d clinics failed geocoding, more than max failures allow", failed, total)}
+	}
+	return nil
+}
+
+// Exceeded reports whether failed of total clinics exceed the limit.
+func (p failurePolicy) Exceeded(failed, total int) bool {
+	if p.limit < 0 || failed == 0 {
+		return false
+	}
+	limit := p.limit
+	if p.percent {
+		limit = p.limit / 100 * float64(total)
+	}
+	return float64(failed) > limit
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/geocode"
+)
+
+// geocodeEstimate is the number of requests, a run is going to make to each provider.
+type geocodeEstimate struct {
+	Clinics int
+	// Geocoded clinics already have points, e.g. from overrides or the previous run, Cached are resolved from the cache.
+	Geocoded int
+	Cached   int
+	Requests map[string]int
+}
+
+// estimate counts requests, geocodeClinics makes for clinics, without making any. Clinics with
+// the same address are resolved with a single request, if there is the cache. Verification requests
+// are counted as if all clinics are geocoded successfully, so it's an upper bound.
+func (g *geocoder) estimate(provider string, clinics []*dmsparse.Clinic) geocodeEstimate {
+	e := geocodeEstimate{
+		Clinics:  len(clinics),
+		Requests: map[string]int{provider: 0},
+	}
+	seen := make(map[string]bool)
+	for _, cc := range clinics {
+		if _, _, ok := cc.LatLon(); ok {
+			e.Geocoded++
+			continue
+		}
+		if g.verify != nil {
+			e.Requests["verify"]++
+		}
+		if g.cache == nil {
+			e.Requests[provider]++
+			continue
+		}
+		key := geocode.CacheKey(cc.RawAddress)
+		switch {
+		case g.cache.Cached(cc.RawAddress):
+			e.Cached++
+		case !seen[key]:
+			seen[key] = true
+			e.Requests[provider]++
+		}
+	}
+	return e
+}
+
+func (e geocodeEstimate) Print(w io.Writer) {
+	fmt.Fprintf(w, "clinics %d, already geocoded %d, cached %d\n", e.Clinics, e.Geocoded, e.Cached)
+	providers := make([]string, 0, len(e.Requests))
+	for p := range e.Requests {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+	fmt.Fprintln(w, "requests:")
+	for _, p := range providers {
+		fmt.Fprintf(w, "  %s: %d\n", p, e.Requests[p])
+	}
+}
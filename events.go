@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/export"
+)
+
+// eventsBatchSize is the number of events posted to a webhook or Kafka with a single request.
+const eventsBatchSize = 500
+
+// kafkaScheme prefixes URL of Kafka REST Proxy topic, e.g. "kafka+http://proxy:8082/topics/clinics".
+const kafkaScheme = "kafka+"
+
+// changeEvent is a change of a clinic since the previous dataset, so downstream caches can
+// invalidate the clinic without reloading the dataset.
+type changeEvent struct {
+	// Type is "added", "updated" or "removed".
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	// Clinic is the new version of the clinic, or the last one, if it was removed.
+	Clinic *dmsparse.Clinic `json:"clinic"`
+	// Fields are the names of the changed fields of the updated clinic.
+	Fields         []string  `json:"fields,omitempty"`
+	DatasetVersion string    `json:"dataset_version"`
+	Time           time.Time `json:"time"`
+}
+
+// changeEvents returns events of the clinics, that were added, updated or removed since prev.
+func changeEvents(prev, clinics []*dmsparse.Clinic) []changeEvent {
+	var (
+		d       = export.Diff(prev, clinics, 0)
+		version = datasetHash(clinics)
+		now     = time.Now().UTC()
+		events  []changeEvent
+	)
+	for _, cc := range d.Added {
+		events = append(events, changeEvent{Type: "added", ID: cc.ID, Clinic: cc, DatasetVersion: version, Time: now})
+	}
+	for _, ch := range d.Modified {
+		ev := changeEvent{Type: "updated", ID: ch.New.ID, Clinic: ch.New, DatasetVersion: version, Time: now}
+		for _, f := range ch.Fields {
+			ev.Fields = append(ev.Fields, f.Name)
+		}
+		if ch.Moved > 0 {
+			ev.Fields = append(ev.Fields, "points")
+		}
+		events = append(events, ev)
+	}
+	for _, cc := range d.Removed {
+		events = append(events, changeEvent{Type: "removed", ID: cc.ID, Clinic: cc, DatasetVersion: version, Time: now})
+	}
+	return events
+}
+
+// emitEvents writes events to the sink: appends them to NDJSON file, or to stdout if sink is "-",
+// posts them to the webhook at http(s) URL in {"events": [...]} batches, or produces them to Kafka
+// topic, keyed by clinic ID, through Kafka REST Proxy (API v2) at "kafka+http(s)://proxy/topics/<topic>".
+func emitEvents(sink string, events []changeEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(sink, kafkaScheme):
+		url := strings.TrimPrefix(sink, kafkaScheme)
+		return postEvents(url, "application/vnd.kafka.json.v2+json", events, func(batch []changeEvent) interface{} {
+			records := make([]interface{}, len(batch))
+			for i, ev := range batch {
+				records[i] = map[string]interface{}{"key": ev.ID, "value": ev}
+			}
+			return map[string]interface{}{"records": records}
+		})
+	case isURL(sink):
+		return postEvents(sink, "application/json", events, func(batch []changeEvent) interface{} {
+			return map[string]interface{}{"events": batch}
+		})
+	}
+
+	w := io.Writer(os.Stdout)
+	if sink != "-" {
+		f, err := os.OpenFile(sink, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// postEvents posts events to url in batches, encoded by body.
+func postEvents(url, contentType string, events []changeEvent, body func([]changeEvent) interface{}) error {
+	client := &http.Client{Timeout: notifyTimeout}
+	for start := 0; start < len(events); start += eventsBatchSize {
+		end := min(start+eventsBatchSize, len(events))
+		data, err := json.Marshal(body(events[start:end]))
+		if err != nil {
+			return err
+		}
+		resp, err := client.Post(url, contentType, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("could not post events to %s: %s, %s", redactURL(url), resp.Status, bytes.TrimSpace(msg))
+		}
+	}
+	return nil
+}
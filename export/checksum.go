@@ -0,0 +1,152 @@
+package export
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Extensions of the files, written alongside an output.
+const (
+	ChecksumExt  = ".sha256"
+	SignatureExt = ".sig"
+)
+
+// WriteChecksum writes SHA-256 checksum of the file at path into path.sha256 in sha256sum format,
+// so it can be checked with "sha256sum -c" as well. If key isn't nil, Ed25519 signature of the
+// checksum, i.e. of the raw 32-byte digest, is written into path.sig, base64 encoded.
+func WriteChecksum(path string, key ed25519.PrivateKey) error {
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%x  %s\n", sum, filepath.Base(path))
+	if err := writeFileAtomic(path+ChecksumExt, []byte(line)); err != nil {
+		return err
+	}
+	if key == nil {
+		return nil
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, sum))
+	return writeFileAtomic(path+SignatureExt, []byte(sig+"\n"))
+}
+
+// VerifyChecksum checks the file at path against its path.sha256 checksum and, if key isn't nil,
+// checks path.sig signature of the checksum.
+func VerifyChecksum(path string, key ed25519.PublicKey) error {
+	data, err := os.ReadFile(path + ChecksumExt)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return fmt.Errorf("empty checksum file %s", path+ChecksumExt)
+	}
+	want, err := hex.DecodeString(fields[0])
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("invalid checksum in %s", path+ChecksumExt)
+	}
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(sum, want) {
+		return fmt.Errorf("checksum mismatch: got %x, want %x", sum, want)
+	}
+	if key == nil {
+		return nil
+	}
+	data, err = os.ReadFile(path + SignatureExt)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("invalid signature in %s: %v", path+SignatureExt, err)
+	}
+	if !ed25519.Verify(key, sum, sig) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// ReadPrivateKey reads Ed25519 private key from PEM-encoded PKCS #8 file, e.g. generated with
+// "openssl genpkey -algorithm ed25519".
+func ReadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key %s: %v", path, err)
+	}
+	k, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s isn't Ed25519 key", path)
+	}
+	return k, nil
+}
+
+// ReadPublicKey reads Ed25519 public key from PEM-encoded PKIX file, e.g. extracted with "openssl pkey -pubout".
+func ReadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key %s: %v", path, err)
+	}
+	k, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s isn't Ed25519 key", path)
+	}
+	return k, nil
+}
+
+func readPEM(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+	return block.Bytes, nil
+}
+
+func fileSHA256(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// writeYMapsCSV writes geocoded clinics as CSV, suitable for import into Yandex Maps Constructor.
+// Clinics without points are skipped, as the Constructor can't place them.
+func writeYMapsCSV(w io.Writer, clinics []*dmsparse.Clinic) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	cw.Write([]string{"Широта", "Долгота", "Описание", "Подпись", "Номер метки"})
+	var n int
+	for _, cc := range clinics {
+		lat, lon, ok := cc.LatLon()
+		if !ok {
+			continue
+		}
+		n++
+		cw.Write([]string{
+			formatFloat(lat),
+			formatFloat(lon),
+			clinicDescription(cc),
+			cc.Name,
+			strconv.Itoa(n),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// clinicDescription returns human-readable description of a clinic, used in map markers.
+func clinicDescription(cc *dmsparse.Clinic) string {
+	addr := cc.Address
+	if addr == "" {
+		addr = cc.RawAddress
+	}
+	if cc.Phone == "" {
+		return addr
+	}
+	return addr + ", " + cc.Phone
+}
+
+// writeReviewCSV writes clinics as CSV for manual review of their points, with confidence and
+// links to the points on Yandex Maps.
+func writeReviewCSV(w io.Writer, clinics []*dmsparse.Clinic) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "name", "raw_address", "address", "city", "precision", "confidence", "lat", "lon", "map"})
+	for _, cc := range clinics {
+		var lat, lon, link string
+		if la, lo, ok := cc.LatLon(); ok {
+			lat, lon = formatFloat(la), formatFloat(lo)
+			link = "https://yandex.ru/maps/?pt=" + lon + "," + lat + "&z=17"
+		}
+		cw.Write([]string{
+			cc.ID,
+			cc.Name,
+			cc.RawAddress,
+			cc.Address,
+			cc.City,
+			cc.Precision,
+			strconv.FormatFloat(cc.Confidence, 'f', 2, 64),
+			lat,
+			lon,
+			link,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeMyMapsCSV writes geocoded clinics as CSV, following Google My Maps import conventions.
+func writeMyMapsCSV(w io.Writer, clinics []*dmsparse.Clinic) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Name", "Description", "Latitude", "Longitude"})
+	for _, cc := range clinics {
+		lat, lon, ok := cc.LatLon()
+		if !ok {
+			continue
+		}
+		cw.Write([]string{
+			cc.Name,
+			clinicDescription(cc),
+			formatFloat(lat),
+			formatFloat(lon),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,97 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// ReadDataset reads clinics from previously generated dataset file. Both plain json
+// array and envelope documents are supported, as well as yaml and gzip-compressed files.
+func ReadDataset(path string) ([]*dmsparse.Clinic, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+		path = strings.TrimSuffix(path, ".gz")
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ReadYAML(r)
+	}
+	return ReadJSON(r)
+}
+
+// ReadJSON reads clinics from json array or envelope document. Clinics are checked against
+// the schema first, and all violations are returned as SchemaError.
+func ReadJSON(r io.Reader) ([]*dmsparse.Clinic, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+
+	list := data
+	if len(data) > 0 && data[0] == '{' {
+		var env struct {
+			Clinics json.RawMessage `json:"clinics"`
+		}
+		if err := json.Unmarshal(data, &env); err != nil {
+			return nil, jsonError(data, err)
+		}
+		if env.Clinics == nil {
+			return nil, nil
+		}
+		list = env.Clinics
+	}
+
+	var records []json.RawMessage
+	if err := json.Unmarshal(list, &records); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return nil, jsonError(data, err)
+		}
+		return nil, fmt.Errorf("expected array of clinics, got %s", jsonType(list))
+	}
+	errs := &SchemaError{}
+	for i, rec := range records {
+		checkClinicRecord(errs, i+1, rec)
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	clinics := make([]*dmsparse.Clinic, len(records))
+	for i, rec := range records {
+		clinics[i] = &dmsparse.Clinic{}
+		if err := json.Unmarshal(rec, clinics[i]); err != nil {
+			return nil, fmt.Errorf("record %d: %v", i+1, err)
+		}
+	}
+	return clinics, nil
+}
+
+// jsonError annotates json syntax error with the line of data, where it occurred.
+func jsonError(data []byte, err error) error {
+	if line := syntaxErrorLine(data, err); line > 0 {
+		return fmt.Errorf("line %d: %v", line, err)
+	}
+	return err
+}
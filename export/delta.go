@@ -0,0 +1,52 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// delta is the machine-readable set of changes between previous and current datasets.
+// Changed clinics are included as full records, so clients replace them by ID.
+type delta struct {
+	Added   interface{} `json:"added"`
+	Changed interface{} `json:"changed"`
+	Removed []string    `json:"removed"`
+}
+
+// writeDelta writes clinics added, changed or removed since the previous dataset.
+func writeDelta(w io.Writer, clinics []*dmsparse.Clinic, opts *Options) error {
+	if opts.Prev == nil {
+		return fmt.Errorf("delta output requires previous dataset")
+	}
+	d := Diff(opts.Prev, clinics, 0)
+	added := make([]*dmsparse.Clinic, 0, len(d.Added))
+	added = append(added, d.Added...)
+	changed := make([]*dmsparse.Clinic, 0, len(d.Modified))
+	for _, ch := range d.Modified {
+		changed = append(changed, ch.New)
+	}
+
+	var (
+		out delta
+		err error
+	)
+	if out.Added, err = jsonClinics(added, opts.CoordOrder); err != nil {
+		return err
+	}
+	if out.Changed, err = jsonClinics(changed, opts.CoordOrder); err != nil {
+		return err
+	}
+	out.Removed = make([]string, 0, len(d.Removed))
+	for _, cc := range d.Removed {
+		out.Removed = append(out.Removed, cc.ID)
+	}
+
+	enc := json.NewEncoder(w)
+	if opts.Pretty {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(out)
+}
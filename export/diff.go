@@ -0,0 +1,122 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/geocode"
+)
+
+// DatasetDiff describes changes between two versions of the dataset.
+type DatasetDiff struct {
+	Added    []*dmsparse.Clinic
+	Removed  []*dmsparse.Clinic
+	Modified []ClinicChange
+}
+
+type ClinicChange struct {
+	Old, New *dmsparse.Clinic
+	Fields   []FieldChange
+	// Moved is the distance in meters the clinic's point moved, if it's above the threshold.
+	Moved float64
+}
+
+type FieldChange struct {
+	Name     string
+	Old, New string
+}
+
+// Diff compares two clinic lists by clinic ID. Points moves below threshold (in meters) are ignored.
+func Diff(oldClinics, newClinics []*dmsparse.Clinic, threshold float64) *DatasetDiff {
+	oldByID := make(map[string]*dmsparse.Clinic, len(oldClinics))
+	for _, cc := range oldClinics {
+		oldByID[cc.ID] = cc
+	}
+	newByID := make(map[string]*dmsparse.Clinic, len(newClinics))
+	for _, cc := range newClinics {
+		newByID[cc.ID] = cc
+	}
+
+	var d DatasetDiff
+	for _, cc := range oldClinics {
+		if _, ok := newByID[cc.ID]; !ok {
+			d.Removed = append(d.Removed, cc)
+		}
+	}
+	for _, cc := range newClinics {
+		old, ok := oldByID[cc.ID]
+		if !ok {
+			d.Added = append(d.Added, cc)
+			continue
+		}
+		if ch, ok := compareClinics(old, cc, threshold); ok {
+			d.Modified = append(d.Modified, ch)
+		}
+	}
+	return &d
+}
+
+func compareClinics(old, cc *dmsparse.Clinic, threshold float64) (ch ClinicChange, changed bool) {
+	ch = ClinicChange{Old: old, New: cc}
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"name", old.Name, cc.Name},
+		{"raw_address", old.RawAddress, cc.RawAddress},
+		{"phone", old.Phone, cc.Phone},
+		{"address", old.Address, cc.Address},
+		{"city", old.City, cc.City},
+		{"district", old.District, cc.District},
+		{"categories", strings.Join(old.Categories, ","), strings.Join(cc.Categories, ",")},
+		{"programs", strings.Join(old.Programs, "\n"), strings.Join(cc.Programs, "\n")},
+	}
+	for _, f := range fields {
+		if f.old != f.new {
+			ch.Fields = append(ch.Fields, FieldChange{f.name, f.old, f.new})
+		}
+	}
+
+	oldLat, oldLon, oldOk := old.LatLon()
+	lat, lon, ok := cc.LatLon()
+	switch {
+	case oldOk && ok:
+		if dist := geocode.Distance(oldLat, oldLon, lat, lon); dist > threshold {
+			ch.Moved = dist
+		}
+	case oldOk != ok:
+		ch.Fields = append(ch.Fields, FieldChange{"points", formatPoints(old), formatPoints(cc)})
+	}
+
+	return ch, len(ch.Fields) > 0 || ch.Moved > 0
+}
+
+func formatPoints(cc *dmsparse.Clinic) string {
+	lat, lon, ok := cc.LatLon()
+	if !ok {
+		return ""
+	}
+	return formatFloat(lat) + "," + formatFloat(lon)
+}
+
+// Print writes human-readable report of the changes.
+func (d *DatasetDiff) Print(w io.Writer) {
+	for _, cc := range d.Added {
+		fmt.Fprintf(w, "+ %s %s\n", cc.ID, cc.Name)
+	}
+	for _, cc := range d.Removed {
+		fmt.Fprintf(w, "- %s %s\n", cc.ID, cc.Name)
+	}
+	for _, ch := range d.Modified {
+		fmt.Fprintf(w, "~ %s %s\n", ch.New.ID, ch.New.Name)
+		for _, f := range ch.Fields {
+			fmt.Fprintf(w, "    %s: %q -> %q\n", f.Name, f.Old, f.New)
+		}
+		if ch.Moved > 0 {
+			fmt.Fprintf(w, "    points: %s -> %s (moved %.0f m)\n", formatPoints(ch.Old), formatPoints(ch.New), ch.Moved)
+		}
+	}
+	fmt.Fprintf(w, "added %d, removed %d, modified %d\n", len(d.Added), len(d.Removed), len(d.Modified))
+}
@@ -0,0 +1,49 @@
+package export
+
+import (
+	"time"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// EnvelopeSchemaVersion is the version of the output document. It must be bumped on
+// incompatible changes of Envelope or Clinic.
+const EnvelopeSchemaVersion = 1
+
+// Envelope wraps the clinic list with metadata about the dataset build.
+type Envelope struct {
+	SchemaVersion int                `json:"schema_version"`
+	GeneratedAt   time.Time          `json:"generated_at"`
+	SourceSHA256  string             `json:"source_sha256"`
+	Provider      string             `json:"provider"`
+	Generator     string             `json:"generator,omitempty"`
+	Counts        EnvelopeCounts     `json:"counts"`
+	Clinics       []*dmsparse.Clinic `json:"clinics"`
+}
+
+type EnvelopeCounts struct {
+	Total    int `json:"total"`
+	Geocoded int `json:"geocoded"`
+	Failed   int `json:"failed"`
+}
+
+// NewEnvelope returns envelope of clinics, stamped with dataset metadata from opts.
+func NewEnvelope(clinics []*dmsparse.Clinic, opts *Options) *Envelope {
+	env := &Envelope{
+		SchemaVersion: EnvelopeSchemaVersion,
+		GeneratedAt:   time.Now().UTC(),
+		SourceSHA256:  opts.SourceSHA256,
+		Provider:      opts.Provider,
+		Generator:     opts.Generator,
+		Clinics:       clinics,
+	}
+	env.Counts.Total = len(clinics)
+	for _, cc := range clinics {
+		if _, _, ok := cc.LatLon(); ok {
+			env.Counts.Geocoded++
+		} else {
+			env.Counts.Failed++
+		}
+	}
+	return env
+}
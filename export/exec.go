@@ -0,0 +1,39 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// writeExec pipes clinics as json envelope to the external exporter command and writes
+// the command's output to w. The command reads the envelope, the same as json output
+// with -envelope, from stdin, and writes the output in its format to stdout.
+func writeExec(w io.Writer, command []string, clinics []*dmsparse.Clinic, opts *Options) error {
+	if len(command) == 0 {
+		return errors.New("no exporter command")
+	}
+
+	var in bytes.Buffer
+	envOpts := *opts
+	envOpts.Envelope = true
+	envOpts.Pretty = false
+	if err := writeJSON(&in, clinics, &envOpts); err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = &in
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("exporter command failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,418 @@
+// Package export writes clinic lists in various output formats.
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// Options configures output formats. Zero Options produce compact json with points in lat, lon order.
+type Options struct {
+	// Pretty indents json output.
+	Pretty bool
+	// Envelope wraps json output into an envelope with dataset metadata.
+	Envelope bool
+	// CoordOrder is the order of points in json output: "latlon" (default) or "lonlat".
+	CoordOrder string
+	// Compress is output compression: "gzip" or "none". If empty, files with .gz extension are gzipped.
+	Compress string
+
+	// SourceSHA256, Provider, the geocoder of the dataset, if it was geocoded in the same run, and Generator,
+	// the name and version of the tool, are stamped into the envelope.
+	SourceSHA256 string
+	Provider     string
+	Generator    string
+
+	// JSVar is the name of the variable in js output (default "data").
+	JSVar string
+	// JSONPCallback, if set, wraps js output into the callback call.
+	JSONPCallback string
+	// Template is the path to Go template file for template output.
+	Template string
+	// GoPackage and GoVar are the package and variable names in go output (default "clinics" and "Clinics").
+	GoPackage string
+	GoVar     string
+	// MobileDropRawAddress omits raw address of geocoded clinics in mobile output.
+	MobileDropRawAddress bool
+	// Prev is the previous version of the dataset, required for delta output.
+	Prev []*dmsparse.Clinic
+
+	// ExecCommand is the external exporter command and its arguments, used by exec output.
+	ExecCommand []string
+
+	// SQLiteBin and PsqlBin are paths to binaries, used by sqlite and postgres outputs.
+	SQLiteBin string
+	PsqlBin   string
+	// CacheControl is Cache-Control header of outputs, uploaded to S3.
+	CacheControl string
+	// ESAPIKey is the API key of Elasticsearch, used by elasticsearch output.
+	ESAPIKey string
+
+	// Checksum writes SHA-256 checksum alongside each output file, and its signature, if SignKey is set.
+	Checksum bool
+	SignKey  ed25519.PrivateKey
+}
+
+// Write writes clinics to w in the given output format.
+func Write(w io.Writer, format string, clinics []*dmsparse.Clinic, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	switch format {
+	case "json":
+		return writeJSON(w, clinics, opts)
+	case "js":
+		head, tail := "", "\n"
+		if opts.JSONPCallback != "" {
+			head, tail = opts.JSONPCallback+"(", ");\n"
+		} else {
+			jsVar := opts.JSVar
+			if jsVar == "" {
+				jsVar = "data"
+			}
+			head = jsVar + " = "
+		}
+		if _, err := io.WriteString(w, head); err != nil {
+			return err
+		}
+		if err := encodeJSON(w, clinics, opts); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, tail)
+		return err
+	case "geojson":
+		return writeGeoJSON(w, clinics, opts)
+	case "vcard":
+		return writeVCard(w, clinics)
+	case "delta":
+		return writeDelta(w, clinics, opts)
+	case "mobile":
+		return writeMobile(w, clinics, opts.MobileDropRawAddress)
+	case "ndjson":
+		return writeNDJSON(w, clinics, opts)
+	case "yaml":
+		return WriteYAML(w, clinics)
+	case "xml":
+		return writeXML(w, clinics)
+	case "protobuf":
+		return writeProtobuf(w, clinics)
+	case "html":
+		return writeHTML(w, clinics)
+	case "ymaps-csv":
+		return writeYMapsCSV(w, clinics)
+	case "mymaps-csv":
+		return writeMyMapsCSV(w, clinics)
+	case "review-csv":
+		return writeReviewCSV(w, clinics)
+	case "template":
+		return writeTemplate(w, opts.Template, clinics, opts)
+	case "go":
+		return writeGoSource(w, opts.GoPackage, opts.GoVar, clinics)
+	case "exec":
+		return writeExec(w, opts.ExecCommand, clinics, opts)
+	}
+	return fmt.Errorf("unknown output format: %q", format)
+}
+
+// writeJSON writes clinics as json array, or as envelope if opts.Envelope is set, followed by a newline.
+func writeJSON(w io.Writer, clinics []*dmsparse.Clinic, opts *Options) error {
+	if err := encodeJSON(w, clinics, opts); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// encodeJSON encodes clinics as json array, or as envelope if opts.Envelope is set, clinic by clinic,
+// so the encoded dataset isn't buffered in memory.
+func encodeJSON(w io.Writer, clinics []*dmsparse.Clinic, opts *Options) error {
+	if _, err := jsonClinic(nil, opts.CoordOrder); err != nil {
+		return err
+	}
+	var head interface{}
+	if opts.Envelope {
+		env := NewEnvelope(clinics, opts)
+		env.Clinics = nil
+		head = env
+	}
+	indent := ""
+	if opts.Pretty {
+		indent = "  "
+	}
+	return writeJSONStream(w, head, indent, func(a *jsonArrayWriter) error {
+		for _, cc := range clinics {
+			v, _ := jsonClinic(cc, opts.CoordOrder)
+			if err := a.Write(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// jsonClinics returns clinics ready to be encoded to json with points in the given order.
+func jsonClinics(clinics []*dmsparse.Clinic, order string) (interface{}, error) {
+	switch order {
+	case "", "latlon":
+		return clinics, nil
+	case "lonlat":
+		v := make([]dmsparse.LonLat, len(clinics))
+		for i, cc := range clinics {
+			v[i] = dmsparse.LonLat{Clinic: cc}
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("unknown coordinates order: %q", order)
+}
+
+// WriteFile writes clinics in the given format to the file at path, or to stdout if path is empty or "-".
+// The file is replaced atomically, only if all clinics were written successfully. With opts.Checksum,
+// the checksum of the file is written alongside it, see WriteChecksum. If path is S3 URL, the file is
+// uploaded to the bucket, see writeS3.
+func WriteFile(path, format string, clinics []*dmsparse.Clinic, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if IsS3(path) {
+		return writeS3(path, format, clinics, opts)
+	}
+	if err := writeFile(path, format, clinics, opts); err != nil {
+		return err
+	}
+	if !opts.Checksum || path == "" || path == "-" {
+		return nil
+	}
+	if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+		return err
+	}
+	return WriteChecksum(path, opts.SignKey)
+}
+
+func writeFile(path, format string, clinics []*dmsparse.Clinic, opts *Options) (err error) {
+	switch format {
+	case "sqlite":
+		return WriteSQLite(path, clinics, opts)
+	}
+
+	out := os.Stdout
+	if fi, serr := os.Stat(path); serr == nil && !fi.Mode().IsRegular() {
+		// devices and pipes, e.g. /dev/null, can't be replaced with rename
+		f, ferr := os.OpenFile(path, os.O_WRONLY, 0)
+		if ferr != nil {
+			return ferr
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		out = f
+	} else if path != "" && path != "-" {
+		// the deferred funcs check the result of the write, so f's error must not shadow err
+		f, ferr := createAtomic(path)
+		if ferr != nil {
+			return ferr
+		}
+		defer func() {
+			if err != nil {
+				f.Abort()
+				return
+			}
+			err = f.Commit()
+		}()
+		out = f.File
+	}
+
+	var (
+		w  io.Writer = out
+		gz *gzip.Writer
+	)
+	switch opts.Compress {
+	case "":
+		if strings.HasSuffix(path, ".gz") {
+			gz = gzip.NewWriter(out)
+		}
+	case "gzip":
+		gz = gzip.NewWriter(out)
+	case "none":
+	default:
+		return fmt.Errorf("unknown compression: %q", opts.Compress)
+	}
+	if gz != nil {
+		w = gz
+	}
+
+	// formats encode clinic by clinic, so writes are buffered
+	bw := bufio.NewWriterSize(w, 64<<10)
+	if err := Write(bw, format, clinics, opts); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+// apiHTTPClient makes requests of the outputs, that write into remote services.
+var apiHTTPClient = &http.Client{Timeout: 5 * time.Minute}
+
+// atomicFile is a temporary file, that replaces the file at path on Commit.
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+// createAtomic creates a temporary file in the same directory as path, so it can be renamed over path.
+func createAtomic(path string) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{f, path}, nil
+}
+
+func (f *atomicFile) Commit() error {
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+func (f *atomicFile) Abort() {
+	f.File.Close()
+	os.Remove(f.Name())
+}
+
+// WriteSplit groups clinics by the given key and writes each group into its own file in dir,
+// e.g. dir/moskva.json.
+func WriteSplit(dir, by, format string, clinics []*dmsparse.Clinic, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if by != "city" {
+		return fmt.Errorf("unknown split key: %q", by)
+	}
+	if dir == "" || dir == "-" {
+		return fmt.Errorf("split output requires output directory")
+	}
+	ext := FormatExt(format)
+	if ext == "" {
+		return fmt.Errorf("output format %q can't be split", format)
+	}
+	if opts.Compress == "gzip" {
+		ext += ".gz"
+	}
+
+	var (
+		groups = make(map[string][]*dmsparse.Clinic)
+		keys   []string
+	)
+	for _, cc := range clinics {
+		key := slugify(cc.City)
+		if key == "" {
+			key = "unknown"
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], cc)
+	}
+
+	join := filepath.Join
+	if IsS3(dir) {
+		join = func(elem ...string) string { return strings.TrimSuffix(elem[0], "/") + "/" + elem[1] }
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := WriteFile(join(dir, key+ext), format, groups[key], opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatExt returns file extension for the output format, or empty string if format isn't file-based.
+func FormatExt(format string) string {
+	switch format {
+	case "json", "mobile", "delta":
+		return ".json"
+	case "geojson":
+		return ".geojson"
+	case "ndjson":
+		return ".ndjson"
+	case "js":
+		return ".js"
+	case "yaml":
+		return ".yaml"
+	case "xml":
+		return ".xml"
+	case "protobuf":
+		return ".pb"
+	case "html":
+		return ".html"
+	case "go":
+		return ".go"
+	case "vcard":
+		return ".vcf"
+	case "ymaps-csv", "mymaps-csv", "review-csv":
+		return ".csv"
+	}
+	return ""
+}
+
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+// slugify transliterates s into lower-case latin, suitable for file names, e.g. "Санкт-Петербург" -> "sankt-peterburg".
+func slugify(s string) string {
+	var (
+		b    strings.Builder
+		dash bool
+	)
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+			dash = false
+		case translit[r] != "":
+			b.WriteString(translit[r])
+			dash = false
+		case r == 'ъ' || r == 'ь':
+		default:
+			if !dash && b.Len() > 0 {
+				b.WriteByte('-')
+				dash = true
+			}
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,33 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+func TestWriteFileKeepsFileOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clinics.json")
+	if err := os.WriteFile(path, []byte("[]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	clinics := []*dmsparse.Clinic{{ID: "a1", Name: "Клиника", RawAddress: "г. Москва"}}
+	if err := WriteFile(path, "unknown", clinics, nil); err == nil {
+		t.Fatal("WriteFile() of unknown format: want error")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "[]\n" {
+		t.Errorf("failed write replaced the file with %q", data)
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".clinics.json.tmp*")); len(tmp) > 0 {
+		t.Errorf("temporary files are left: %v", tmp)
+	}
+
+	if err := WriteFile(path, "json", clinics, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) == "[]\n" {
+		t.Errorf("successful write didn't replace the file")
+	}
+}
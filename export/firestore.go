@@ -0,0 +1,246 @@
+package export
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// firestoreBatchSize is the maximum number of writes in a single batchWrite request.
+const firestoreBatchSize = 500
+
+const (
+	firestoreAPI   = "https://firestore.googleapis.com/v1"
+	firestoreScope = "https://www.googleapis.com/auth/datastore"
+)
+
+// serviceAccount is the credentials file of Google Cloud service account.
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	PrivateKey  string `json:"private_key"`
+	ClientEmail string `json:"client_email"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// WriteFirestore upserts clinics into Firestore collection as documents keyed by clinic ID, so
+// documents of the clinics, that didn't change, keep their IDs between builds. Requests are made to
+// Firestore REST API, authorized with the service account's credentials file. The project is taken
+// from the credentials, if it's empty. If FIRESTORE_EMULATOR_HOST is set, the emulator is used instead,
+// and the credentials aren't required.
+func WriteFirestore(credentials, project, collection string, clinics []*dmsparse.Clinic) error {
+	if collection == "" {
+		return fmt.Errorf("firestore output requires collection name")
+	}
+
+	api, token := firestoreAPI, ""
+	if host := os.Getenv("FIRESTORE_EMULATOR_HOST"); host != "" {
+		api, token = "http://"+host+"/v1", "owner"
+	} else {
+		if credentials == "" {
+			return fmt.Errorf("firestore output requires service account credentials file")
+		}
+		sa, err := readServiceAccount(credentials)
+		if err != nil {
+			return err
+		}
+		if project == "" {
+			project = sa.ProjectID
+		}
+		if token, err = sa.accessToken(firestoreScope); err != nil {
+			return fmt.Errorf("could not authorize service account %s: %v", sa.ClientEmail, err)
+		}
+	}
+	if project == "" {
+		return fmt.Errorf("firestore output requires project ID")
+	}
+
+	database := "projects/" + project + "/databases/(default)"
+	updated := time.Now().UTC().Format(time.RFC3339)
+	for start := 0; start < len(clinics); start += firestoreBatchSize {
+		end := min(start+firestoreBatchSize, len(clinics))
+		writes := make([]interface{}, 0, end-start)
+		for _, cc := range clinics[start:end] {
+			writes = append(writes, map[string]interface{}{
+				"update": map[string]interface{}{
+					"name":   database + "/documents/" + collection + "/" + url.PathEscape(cc.ID),
+					"fields": firestoreFields(cc, updated),
+				},
+			})
+		}
+		if err := firestoreBatchWrite(api+"/"+database+"/documents:batchWrite", token, writes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// firestoreFields returns Firestore fields of the clinic's document.
+func firestoreFields(cc *dmsparse.Clinic, updated string) map[string]interface{} {
+	str := func(s string) interface{} { return map[string]string{"stringValue": s} }
+	fields := map[string]interface{}{
+		"id":          str(cc.ID),
+		"name":        str(cc.Name),
+		"raw_address": str(cc.RawAddress),
+		"phone":       str(cc.Phone),
+		"address":     str(cc.Address),
+		"city":        str(cc.City),
+		"precision":   str(cc.Precision),
+		"confidence":  map[string]float64{"doubleValue": cc.Confidence},
+		"location":    map[string]interface{}{"nullValue": nil},
+		"updated_at":  map[string]string{"timestampValue": updated},
+	}
+	if lat, lon, ok := cc.LatLon(); ok {
+		fields["location"] = map[string]interface{}{
+			"geoPointValue": map[string]float64{"latitude": lat, "longitude": lon},
+		}
+	}
+	return fields
+}
+
+func firestoreBatchWrite(endpoint, token string, writes []interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"writes": writes})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := apiHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("batch write: %s, %s", resp.Status, truncate(data, 1024))
+	}
+
+	// writes of a batch succeed or fail independently
+	var res struct {
+		Status []struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return fmt.Errorf("could not decode batch write response: %v", err)
+	}
+	var failed []string
+	for _, st := range res.Status {
+		if st.Code != 0 {
+			failed = append(failed, st.Message)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d writes failed: %s", len(failed), failed[0])
+	}
+	return nil
+}
+
+func readServiceAccount(path string) (*serviceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sa serviceAccount
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return nil, fmt.Errorf("could not parse credentials %s: %v", path, err)
+	}
+	if sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, fmt.Errorf("credentials %s aren't service account key", path)
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &sa, nil
+}
+
+// accessToken exchanges JWT, signed with the service account's key, for OAuth2 access token.
+func (sa *serviceAccount) accessToken(scope string) (string, error) {
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("no PEM data in private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("private key isn't RSA key")
+	}
+
+	now := time.Now()
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   sa.ClientEmail,
+		"scope": scope,
+		"aud":   sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + enc.EncodeToString(sig)},
+	}
+	resp, err := apiHTTPClient.PostForm(sa.TokenURI, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s, %s", resp.Status, truncate(data, 1024))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("no access token in response: %s", truncate(data, 1024))
+	}
+	return token.AccessToken, nil
+}
+
+// truncate cuts data, e.g. an error response, to at most n bytes.
+func truncate(data []byte, n int) string {
+	s := string(data)
+	if len(s) > n {
+		s = s[:n]
+	}
+	return strings.TrimSpace(s)
+}
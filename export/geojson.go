@@ -0,0 +1,80 @@
+package export
+
+import (
+	"io"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// writeGeoJSON writes geocoded clinics as GeoJSON FeatureCollection. Per RFC 7946, coordinates
+// are always in lon, lat order, regardless of opts.CoordOrder.
+func writeGeoJSON(w io.Writer, clinics []*dmsparse.Clinic, opts *Options) error {
+	indent := ""
+	if opts.Pretty {
+		indent = "  "
+	}
+	head := geoJSONFeatureCollection{Type: "FeatureCollection"}
+	err := writeJSONStream(w, head, indent, func(a *jsonArrayWriter) error {
+		for _, cc := range clinics {
+			lat, lon, ok := cc.LatLon()
+			if !ok {
+				continue
+			}
+			err := a.Write(geoJSONFeature{
+				Type: "Feature",
+				ID:   cc.ID,
+				Geometry: geoJSONPoint{
+					Type:        "Point",
+					Coordinates: [2]float64{lon, lat},
+				},
+				Properties: geoJSONProperties{
+					Name:       cc.Name,
+					RawAddress: cc.RawAddress,
+					Phone:      cc.Phone,
+					Address:    cc.Address,
+					City:       cc.City,
+					District:   cc.District,
+					Categories: cc.Categories,
+					Programs:   cc.Programs,
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Geometry   geoJSONPoint      `json:"geometry"`
+	Properties geoJSONProperties `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type geoJSONProperties struct {
+	Name       string   `json:"name"`
+	RawAddress string   `json:"raw_address"`
+	Phone      string   `json:"phone"`
+	Address    string   `json:"address,omitempty"`
+	City       string   `json:"city,omitempty"`
+	District   string   `json:"district,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	Programs   []string `json:"programs,omitempty"`
+}
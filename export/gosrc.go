@@ -0,0 +1,62 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// writeGoSource writes Go source file, that declares clinics as package-level variable.
+// The file is meant to be produced with go:generate and compiled into the service.
+func writeGoSource(w io.Writer, pkg, name string, clinics []*dmsparse.Clinic) error {
+	if pkg == "" {
+		pkg = "clinics"
+	}
+	if name == "" {
+		name = "Clinics"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gen_points; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&buf, `type Clinic struct {
+	ID         string
+	Name       string
+	RawAddress string
+	Phone      string
+	Address    string
+	City       string
+	Points     []float64
+}
+
+`)
+	fmt.Fprintf(&buf, "var %s = []Clinic{\n", name)
+	for _, cc := range clinics {
+		fmt.Fprintf(&buf, "{\n")
+		fmt.Fprintf(&buf, "ID: %s,\n", strconv.Quote(cc.ID))
+		fmt.Fprintf(&buf, "Name: %s,\n", strconv.Quote(cc.Name))
+		fmt.Fprintf(&buf, "RawAddress: %s,\n", strconv.Quote(cc.RawAddress))
+		fmt.Fprintf(&buf, "Phone: %s,\n", strconv.Quote(cc.Phone))
+		if cc.Address != "" {
+			fmt.Fprintf(&buf, "Address: %s,\n", strconv.Quote(cc.Address))
+		}
+		if cc.City != "" {
+			fmt.Fprintf(&buf, "City: %s,\n", strconv.Quote(cc.City))
+		}
+		if lat, lon, ok := cc.LatLon(); ok {
+			fmt.Fprintf(&buf, "Points: []float64{%s, %s},\n", formatFloat(lat), formatFloat(lon))
+		}
+		fmt.Fprintf(&buf, "},\n")
+	}
+	fmt.Fprintf(&buf, "}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
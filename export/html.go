@@ -0,0 +1,62 @@
+package export
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// writeHTML writes a self-contained HTML page, that shows clinics on Yandex Maps.
+func writeHTML(w io.Writer, clinics []*dmsparse.Clinic) error {
+	return htmlTmpl.Execute(w, clinics)
+}
+
+var htmlTmpl = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <meta http-equiv="Content-Type" content="text/html; charset=utf-8" />
+    <title>Клиники ДМС</title>
+    <script src="https://api-maps.yandex.ru/2.1/?lang=ru_RU" type="text/javascript"></script>
+    <style>
+        html,
+        body,
+        #map {
+            width: 100%;
+            height: 100%;
+            margin: 0;
+            padding: 0
+        }
+    </style>
+</head>
+<body>
+    <div id="map"></div>
+    <script>
+        const data = {{.}};
+        const escape = s => String(s || '').replace(/[&<>"']/g, c => '&#' + c.charCodeAt(0) + ';');
+        ymaps.ready(() => {
+            const map = new ymaps.Map('map', {
+                center: [55.751574, 37.573856],
+                zoom: 12
+            });
+            data.forEach(clinic => {
+                if (clinic.lat === undefined) {
+                    return;
+                }
+                const marker = new ymaps.Placemark(
+                    [clinic.lat, clinic.lon],
+                    {
+                        balloonContent: ` + "`<strong>${escape(clinic.name)}</strong><br/>${escape(clinic.address)}<br/>${escape(clinic.phone)}`" + `
+                    },
+                    {
+                        preset: 'islands#circleDotIcon',
+                    }
+                );
+                map.geoObjects.add(marker);
+            });
+        });
+    </script>
+</body>
+</html>
+`))
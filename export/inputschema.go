@@ -0,0 +1,145 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxViolations is the number of violations, after which SchemaError lists no more of them.
+const maxViolations = 50
+
+// Violation is a violation of the input schema by a record of the input.
+type Violation struct {
+	// Record is the number of the record, starting from 1: the position in json array, or the line
+	// in ndjson and csv.
+	Record  int
+	Field   string
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Field == "" {
+		return fmt.Sprintf("record %d: %s", v.Record, v.Message)
+	}
+	return fmt.Sprintf("record %d: %s: %s", v.Record, v.Field, v.Message)
+}
+
+// SchemaError lists all violations of the input schema, so the input can be fixed in one go.
+type SchemaError struct {
+	Violations []Violation
+}
+
+func (e *SchemaError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "input doesn't match the schema, %d violations:", len(e.Violations))
+	for i, v := range e.Violations {
+		if i == maxViolations {
+			fmt.Fprintf(&b, "\n  ... and %d more", len(e.Violations)-i)
+			break
+		}
+		b.WriteString("\n  ")
+		b.WriteString(v.String())
+	}
+	return b.String()
+}
+
+// Add records a violation of the record.
+func (e *SchemaError) Add(record int, field, format string, args ...interface{}) {
+	e.Violations = append(e.Violations, Violation{record, field, fmt.Sprintf(format, args...)})
+}
+
+// Err returns e, if there are violations, or nil.
+func (e *SchemaError) Err() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+// checkClinicRecord checks json record of a clinic against the clinic schema of JSONSchema, except
+// only name and raw_address are required, as the rest is filled in by the pipeline.
+func checkClinicRecord(errs *SchemaError, record int, data []byte) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		errs.Add(record, "", "expected clinic object")
+		return
+	}
+	for _, name := range []string{"name", "raw_address"} {
+		if _, ok := fields[name]; !ok {
+			errs.Add(record, name, "required field is missing")
+		}
+	}
+	for _, name := range []string{"id", "name", "raw_address", "phone", "address", "city", "precision"} {
+		if v, ok := fields[name]; ok {
+			var s string
+			if json.Unmarshal(v, &s) != nil {
+				errs.Add(record, name, "expected string, got %s", jsonType(v))
+			}
+		}
+	}
+	if v, ok := fields["confidence"]; ok {
+		var f float64
+		if json.Unmarshal(v, &f) != nil {
+			errs.Add(record, "confidence", "expected number, got %s", jsonType(v))
+		} else if f < 0 || f > 1 {
+			errs.Add(record, "confidence", "%g is out of range 0..1", f)
+		}
+	}
+	if v, ok := fields["points"]; ok && jsonType(v) != "null" {
+		var points []float64
+		if json.Unmarshal(v, &points) != nil || len(points) != 2 {
+			errs.Add(record, "points", "expected null or array of 2 numbers, got %s", bytes.TrimSpace(v))
+		}
+	}
+	lat, hasLat := fields["lat"]
+	lon, hasLon := fields["lon"]
+	if hasLat != hasLon {
+		errs.Add(record, "lat", "lat and lon must be set together")
+	}
+	checkCoord(errs, record, "lat", lat, 90)
+	checkCoord(errs, record, "lon", lon, 180)
+}
+
+func checkCoord(errs *SchemaError, record int, name string, v json.RawMessage, max float64) {
+	if v == nil {
+		return
+	}
+	var f float64
+	if json.Unmarshal(v, &f) != nil {
+		errs.Add(record, name, "expected number, got %s", jsonType(v))
+	} else if f < -max || f > max {
+		errs.Add(record, name, "%g is out of range -%g..%g", f, max, max)
+	}
+}
+
+// jsonType returns the name of json type of the value.
+func jsonType(v json.RawMessage) string {
+	v = bytes.TrimSpace(v)
+	if len(v) == 0 {
+		return "nothing"
+	}
+	switch v[0] {
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	}
+	return "number"
+}
+
+// syntaxErrorLine returns the line of the json syntax error in data, or 0.
+func syntaxErrorLine(data []byte, err error) int {
+	se, ok := err.(*json.SyntaxError)
+	if !ok {
+		return 0
+	}
+	return bytes.Count(data[:se.Offset], []byte("\n")) + 1
+}
@@ -0,0 +1,95 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// jsonArrayWriter encodes json array element by element, so large arrays aren't buffered in memory.
+// The output is the same, as json.Encoder with the indent produces.
+type jsonArrayWriter struct {
+	w io.Writer
+	// prefix is the indentation of the array itself, e.g. if it's a field of an object.
+	prefix string
+	indent string
+	n      int
+	buf    bytes.Buffer
+}
+
+func (a *jsonArrayWriter) Write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	a.buf.Reset()
+	switch {
+	case a.n == 0:
+		a.buf.WriteByte('[')
+	default:
+		a.buf.WriteByte(',')
+	}
+	if a.indent != "" {
+		a.buf.WriteString("\n" + a.prefix + a.indent)
+		if err := json.Indent(&a.buf, data, a.prefix+a.indent, a.indent); err != nil {
+			return err
+		}
+	} else {
+		a.buf.Write(data)
+	}
+	a.n++
+	_, err = a.w.Write(a.buf.Bytes())
+	return err
+}
+
+func (a *jsonArrayWriter) Close() error {
+	end := "]"
+	switch {
+	case a.n == 0:
+		end = "[]"
+	case a.indent != "":
+		end = "\n" + a.prefix + "]"
+	}
+	_, err := io.WriteString(a.w, end)
+	return err
+}
+
+// writeJSONStream writes json document, whose array is written element by element by fn. If head
+// is nil, the document is the array itself. Otherwise, it's the object head, whose last field is
+// the array, which head must encode as null.
+func writeJSONStream(w io.Writer, head interface{}, indent string, fn func(a *jsonArrayWriter) error) error {
+	a := &jsonArrayWriter{w: w, indent: indent}
+	var tail string
+	if head != nil {
+		data, err := json.Marshal(head)
+		if err != nil {
+			return err
+		}
+		if indent != "" {
+			var buf bytes.Buffer
+			if err := json.Indent(&buf, data, "", indent); err != nil {
+				return err
+			}
+			data = buf.Bytes()
+			a.prefix, tail = indent, "\n}"
+		} else {
+			tail = "}"
+		}
+		n := bytes.LastIndex(data, []byte("null"))
+		if n < 0 || string(bytes.TrimSpace(data[n+len("null"):])) != "}" {
+			return fmt.Errorf("json head doesn't end with null field: %s", data)
+		}
+		if _, err := w.Write(data[:n]); err != nil {
+			return err
+		}
+	}
+	if err := fn(a); err != nil {
+		return err
+	}
+	if err := a.Close(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, tail)
+	return err
+}
@@ -0,0 +1,71 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// mobilePayload is the size-optimized dataset for mobile clients. Phones are shared
+// between clinics through the Phones table, clinics refer to them by index.
+type mobilePayload struct {
+	Phones  []string       `json:"p"`
+	Clinics []mobileClinic `json:"c"`
+}
+
+type mobileClinic struct {
+	ID         string      `json:"i"`
+	Name       string      `json:"n"`
+	Address    string      `json:"a,omitempty"`
+	RawAddress string      `json:"r,omitempty"`
+	City       string      `json:"c,omitempty"`
+	Phones     []int       `json:"p,omitempty"`
+	Point      *[2]float64 `json:"ll,omitempty"`
+	Categories []string    `json:"k,omitempty"`
+}
+
+// writeMobile writes clinics as compact json: short keys, coordinates rounded to 5 decimals (about 1 m),
+// deduplicated phones and, optionally, without raw addresses.
+func writeMobile(w io.Writer, clinics []*dmsparse.Clinic, dropRawAddress bool) error {
+	payload := mobilePayload{
+		Phones:  []string{},
+		Clinics: make([]mobileClinic, 0, len(clinics)),
+	}
+	phoneIdx := make(map[string]int)
+	for _, cc := range clinics {
+		mc := mobileClinic{
+			ID:         cc.ID,
+			Name:       cc.Name,
+			Address:    cc.Address,
+			City:       cc.City,
+			Categories: cc.Categories,
+		}
+		if !dropRawAddress || cc.Address == "" {
+			mc.RawAddress = cc.RawAddress
+		}
+		seen := make(map[int]bool)
+		for _, phone := range dmsparse.SplitPhones(cc.Phone) {
+			idx, ok := phoneIdx[phone]
+			if !ok {
+				idx = len(payload.Phones)
+				phoneIdx[phone] = idx
+				payload.Phones = append(payload.Phones, phone)
+			}
+			if !seen[idx] {
+				seen[idx] = true
+				mc.Phones = append(mc.Phones, idx)
+			}
+		}
+		if lat, lon, ok := cc.LatLon(); ok {
+			mc.Point = &[2]float64{round5(lat), round5(lon)}
+		}
+		payload.Clinics = append(payload.Clinics, mc)
+	}
+	return json.NewEncoder(w).Encode(payload)
+}
+
+func round5(f float64) float64 {
+	return math.Round(f*1e5) / 1e5
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+const postgresSchema = `
+CREATE EXTENSION IF NOT EXISTS postgis;
+CREATE TABLE IF NOT EXISTS %[1]s (
+	id          TEXT PRIMARY KEY,
+	name        TEXT NOT NULL,
+	raw_address TEXT NOT NULL,
+	phone       TEXT NOT NULL,
+	address     TEXT,
+	city        TEXT,
+	geom        geometry(Point, 4326),
+	updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS %[2]s ON %[1]s USING GIST (geom);
+CREATE INDEX IF NOT EXISTS %[3]s ON %[1]s (city);
+`
+
+const postgresUpsert = `INSERT INTO %s (id, name, raw_address, phone, address, city, geom)
+VALUES (%s, %s, %s, %s, %s, %s, %s)
+ON CONFLICT (id) DO UPDATE SET
+	name = EXCLUDED.name,
+	raw_address = EXCLUDED.raw_address,
+	phone = EXCLUDED.phone,
+	address = EXCLUDED.address,
+	city = EXCLUDED.city,
+	geom = EXCLUDED.geom,
+	updated_at = now();
+`
+
+// WritePostgres upserts clinics into PostgreSQL table with PostGIS geometry column. The SQL
+// script is piped into psql binary (opts.PsqlBin), so no database driver is required.
+func WritePostgres(conn, table string, clinics []*dmsparse.Clinic, opts *Options) error {
+	if conn == "" {
+		return fmt.Errorf("postgres output requires connection string")
+	}
+	if table == "" {
+		return fmt.Errorf("postgres output requires table name")
+	}
+
+	qtable := pgQuoteIdent(table)
+
+	var buf bytes.Buffer
+	buf.WriteString("BEGIN;\n")
+	fmt.Fprintf(&buf, postgresSchema, qtable, pgQuoteIdent(table+"_geom_idx"), pgQuoteIdent(table+"_city_idx"))
+	for _, cc := range clinics {
+		geom := "NULL"
+		if lat, lon, ok := cc.LatLon(); ok {
+			geom = fmt.Sprintf("ST_SetSRID(ST_MakePoint(%s, %s), 4326)",
+				strconv.FormatFloat(lon, 'f', -1, 64),
+				strconv.FormatFloat(lat, 'f', -1, 64),
+			)
+		}
+		fmt.Fprintf(&buf, postgresUpsert,
+			qtable,
+			sqlQuote(cc.ID),
+			sqlQuote(cc.Name),
+			sqlQuote(cc.RawAddress),
+			sqlQuote(cc.Phone),
+			sqlNullString(cc.Address),
+			sqlNullString(cc.City),
+			geom,
+		)
+	}
+	buf.WriteString("COMMIT;\n")
+
+	bin := opts.PsqlBin
+	if bin == "" {
+		bin = "psql"
+	}
+	cmd := exec.Command(bin, "--no-psqlrc", "--quiet", "--set", "ON_ERROR_STOP=1", "--dbname", conn)
+	cmd.Stdin = &buf
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("could not run %s: %v", bin, err)
+	}
+	return nil
+}
+
+func pgQuoteIdent(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
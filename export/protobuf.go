@@ -0,0 +1,191 @@
+package export
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// writeProtobuf writes clinics as binary protobuf ClinicList message, see proto/clinics.proto.
+// The wire format is encoded by hand, to not depend on protobuf runtime.
+func writeProtobuf(w io.Writer, clinics []*dmsparse.Clinic) error {
+	_, err := w.Write(MarshalProto(clinics))
+	return err
+}
+
+// MarshalProto encodes clinics as protobuf ClinicList message.
+func MarshalProto(clinics []*dmsparse.Clinic) []byte {
+	var list []byte
+	for _, cc := range clinics {
+		list = PbAppendBytes(list, 1, MarshalClinicProto(cc))
+	}
+	return list
+}
+
+// UnmarshalProto decodes clinics from protobuf ClinicList message.
+func UnmarshalProto(b []byte) ([]*dmsparse.Clinic, error) {
+	var clinics []*dmsparse.Clinic
+	err := PbRange(b, func(field int, v []byte, _ uint64) error {
+		if field != 1 {
+			return nil
+		}
+		cc, err := UnmarshalClinicProto(v)
+		if err != nil {
+			return err
+		}
+		clinics = append(clinics, cc)
+		return nil
+	})
+	return clinics, err
+}
+
+// MarshalClinicProto encodes clinic as protobuf Clinic message.
+func MarshalClinicProto(cc *dmsparse.Clinic) []byte {
+	var b []byte
+	b = PbAppendString(b, 1, cc.ID)
+	b = PbAppendString(b, 2, cc.Name)
+	b = PbAppendString(b, 3, cc.RawAddress)
+	b = PbAppendString(b, 4, cc.Phone)
+	b = PbAppendString(b, 5, cc.Address)
+	b = PbAppendString(b, 6, cc.City)
+	if lat, lon, ok := cc.LatLon(); ok {
+		var p []byte
+		p = pbAppendDouble(p, 1, lat)
+		p = pbAppendDouble(p, 2, lon)
+		b = PbAppendBytes(b, 7, p)
+	}
+	b = PbAppendString(b, 8, cc.Precision)
+	if cc.Confidence != 0 {
+		b = pbAppendDouble(b, 9, cc.Confidence)
+	}
+	return b
+}
+
+// UnmarshalClinicProto decodes clinic from protobuf Clinic message.
+func UnmarshalClinicProto(b []byte) (*dmsparse.Clinic, error) {
+	cc := &dmsparse.Clinic{}
+	err := PbRange(b, func(field int, v []byte, x uint64) error {
+		switch field {
+		case 1:
+			cc.ID = string(v)
+		case 2:
+			cc.Name = string(v)
+		case 3:
+			cc.RawAddress = string(v)
+		case 4:
+			cc.Phone = string(v)
+		case 5:
+			cc.Address = string(v)
+		case 6:
+			cc.City = string(v)
+		case 7:
+			var lat, lon float64
+			err := PbRange(v, func(field int, _ []byte, x uint64) error {
+				switch field {
+				case 1:
+					lat = math.Float64frombits(x)
+				case 2:
+					lon = math.Float64frombits(x)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			cc.Points = []float64{lat, lon}
+		case 8:
+			cc.Precision = string(v)
+		case 9:
+			cc.Confidence = math.Float64frombits(x)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+const (
+	pbWireVarint  = 0
+	pbWireFixed64 = 1
+	pbWireBytes   = 2
+	pbWireFixed32 = 5
+)
+
+var errBadProto = errors.New("malformed protobuf message")
+
+// PbRange calls fn for each field of protobuf message b. Bytes fields are passed as v,
+// varint and fixed-size fields as x. Unknown wire types make the message malformed.
+func PbRange(b []byte, fn func(field int, v []byte, x uint64) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errBadProto
+		}
+		b = b[n:]
+		field, wire := int(tag>>3), int(tag&7)
+
+		var (
+			v []byte
+			x uint64
+		)
+		switch wire {
+		case pbWireVarint:
+			x, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errBadProto
+			}
+			b = b[n:]
+		case pbWireFixed64:
+			if len(b) < 8 {
+				return errBadProto
+			}
+			x, b = binary.LittleEndian.Uint64(b), b[8:]
+		case pbWireFixed32:
+			if len(b) < 4 {
+				return errBadProto
+			}
+			x, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case pbWireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errBadProto
+			}
+			v, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return errBadProto
+		}
+		if err := fn(field, v, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pbAppendTag(b []byte, field, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wire))
+}
+
+// PbAppendString appends string field, omitting it if empty, as proto3 does.
+func PbAppendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return PbAppendBytes(b, field, []byte(s))
+}
+
+// PbAppendBytes appends bytes field.
+func PbAppendBytes(b []byte, field int, v []byte) []byte {
+	b = pbAppendTag(b, field, pbWireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func pbAppendDouble(b []byte, field int, f float64) []byte {
+	b = pbAppendTag(b, field, pbWireFixed64)
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+}
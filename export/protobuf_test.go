@@ -0,0 +1,93 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+func TestPbRange(t *testing.T) {
+	// the examples of the protobuf encoding guide: field 1 varint 150 and field 2 string "testing"
+	b := []byte{0x08, 0x96, 0x01, 0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g'}
+	type field struct {
+		n int
+		v string
+		x uint64
+	}
+	var got []field
+	err := PbRange(b, func(n int, v []byte, x uint64) error {
+		got = append(got, field{n, string(v), x})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []field{{1, "", 150}, {2, "testing", 0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PbRange() fields = %v, want %v", got, want)
+	}
+
+	for _, b := range [][]byte{
+		{0x08},             // missing varint
+		{0x12, 0x07, 't'},  // truncated bytes
+		{0x09, 0, 0, 0},    // truncated fixed64
+		{0x0B},             // group wire type
+		{0x80, 0x80, 0x80}, // truncated tag
+	} {
+		err := PbRange(b, func(int, []byte, uint64) error { return nil })
+		if !errors.Is(err, errBadProto) {
+			t.Errorf("PbRange(% X) error = %v, want %v", b, err, errBadProto)
+		}
+	}
+}
+
+func TestMarshalClinicProto(t *testing.T) {
+	cc := &dmsparse.Clinic{ID: "a", Name: "Б", Precision: "exact", Confidence: 0.5, Points: []float64{1.5, -2}}
+	want := []byte{
+		0x0A, 0x01, 'a', // id
+		0x12, 0x02, 0xD0, 0x91, // name
+		0x3A, 0x12, // point
+		0x09, 0, 0, 0, 0, 0, 0, 0xF8, 0x3F, // lat
+		0x11, 0, 0, 0, 0, 0, 0, 0, 0xC0, // lon
+		0x42, 0x05, 'e', 'x', 'a', 'c', 't', // precision
+		0x49, 0, 0, 0, 0, 0, 0, 0xE0, 0x3F, // confidence
+	}
+	if got := MarshalClinicProto(cc); !bytes.Equal(got, want) {
+		t.Errorf("MarshalClinicProto() =\n% X\nwant\n% X", got, want)
+	}
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	clinics := []*dmsparse.Clinic{
+		{
+			ID:         "a1",
+			Name:       "Клиника",
+			RawAddress: "г. Москва, ул. Новая, д. 1",
+			Phone:      "+7 495 000-00-00",
+			Address:    "Россия, Москва, Новая улица, 1",
+			City:       "Москва",
+			Precision:  "exact",
+			Confidence: 0.9,
+			Points:     []float64{55.755814, 37.617635},
+		},
+		// not geocoded clinic has no point
+		{ID: "b2", Name: "Клиника 2", RawAddress: "г. Казань"},
+	}
+	got, err := UnmarshalProto(MarshalProto(clinics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, clinics) {
+		t.Errorf("UnmarshalProto(MarshalProto()):\n got %+v\nwant %+v", got, clinics)
+	}
+
+	// unknown fields of newer schema are skipped
+	b := append(MarshalClinicProto(clinics[1]), 0xF8, 0x07, 0x01) // field 127 varint 1
+	cc, err := UnmarshalClinicProto(b)
+	if err != nil || !reflect.DeepEqual(cc, clinics[1]) {
+		t.Errorf("UnmarshalClinicProto() with unknown field = %+v, %v", cc, err)
+	}
+}
@@ -0,0 +1,488 @@
+package export
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// QRLinks are the kinds of links, QR codes of clinics encode.
+var QRLinks = []string{"geo", "yandex"}
+
+const (
+	// qrScale is the size of QR code module in pixels, large enough to be printed.
+	qrScale = 8
+	// qrQuietZone is the width of the light border around QR code in modules.
+	qrQuietZone = 4
+)
+
+// WriteQR writes a QR code image per geocoded clinic into the directory as <id>.png, e.g. to print
+// on leaflets. Depending on link, the code encodes geo: URI of clinic's point ("geo", the default),
+// which phones open in a maps app, or the link to the point in Yandex Maps ("yandex").
+// Clinics, that weren't geocoded, are skipped.
+func WriteQR(dir, link string, clinics []*dmsparse.Clinic) error {
+	if dir == "" || dir == "-" {
+		return fmt.Errorf("qr output requires output directory")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, cc := range clinics {
+		text, ok, err := qrLink(cc, link)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		code, err := encodeQR([]byte(text))
+		if err != nil {
+			return fmt.Errorf("clinic %s: %v", cc.ID, err)
+		}
+		if err := writeQRImage(filepath.Join(dir, cc.ID+".png"), code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// qrLink returns the link to clinic's point of the given kind, or false if clinic wasn't geocoded.
+func qrLink(cc *dmsparse.Clinic, link string) (string, bool, error) {
+	lat, lon, ok := cc.LatLon()
+	if !ok {
+		return "", false, nil
+	}
+	slat := strconv.FormatFloat(lat, 'f', -1, 64)
+	slon := strconv.FormatFloat(lon, 'f', -1, 64)
+	switch link {
+	case "", "geo":
+		return "geo:" + slat + "," + slon, true, nil
+	case "yandex":
+		return "https://yandex.ru/maps/?pt=" + slon + "," + slat + "&z=17", true, nil
+	}
+	return "", false, fmt.Errorf("unknown qr link: %q", link)
+}
+
+func writeQRImage(path string, code [][]bool) (err error) {
+	size := (len(code) + 2*qrQuietZone) * qrScale
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for y, row := range code {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			x0, y0 := (x+qrQuietZone)*qrScale, (y+qrQuietZone)*qrScale
+			for dy := 0; dy < qrScale; dy++ {
+				for dx := 0; dx < qrScale; dx++ {
+					img.SetColorIndex(x0+dx, y0+dy, 1)
+				}
+			}
+		}
+	}
+
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Abort()
+			return
+		}
+		err = f.Commit()
+	}()
+	return png.Encode(f.File, img)
+}
+
+// qrVersion is the block structure of QR code version at error correction level M,
+// which lets a leaflet's code be read, even if it's slightly smudged.
+type qrVersion struct {
+	ecc            int // ec codewords per block
+	blocks1, data1 int // blocks of the first group and their data codewords
+	blocks2, data2 int // blocks of the second group, that have one more data codeword
+	align          []int
+}
+
+// qrVersions are versions 1 to 10, which hold up to 213 bytes, enough for a link.
+var qrVersions = []qrVersion{
+	{10, 1, 16, 0, 0, nil},
+	{16, 1, 28, 0, 0, []int{6, 18}},
+	{26, 1, 44, 0, 0, []int{6, 22}},
+	{18, 2, 32, 0, 0, []int{6, 26}},
+	{24, 2, 43, 0, 0, []int{6, 30}},
+	{16, 4, 27, 0, 0, []int{6, 34}},
+	{18, 4, 31, 0, 0, []int{6, 22, 38}},
+	{22, 2, 38, 2, 39, []int{6, 24, 42}},
+	{22, 3, 36, 2, 37, []int{6, 26, 46}},
+	{26, 4, 43, 1, 44, []int{6, 28, 50}},
+}
+
+func (v qrVersion) dataCodewords() int {
+	return v.blocks1*v.data1 + v.blocks2*v.data2
+}
+
+// encodeQR encodes data in byte mode into the smallest QR code, that fits it, and returns its modules,
+// where true is dark.
+func encodeQR(data []byte) ([][]bool, error) {
+	ver := 0
+	for ; ver < len(qrVersions); ver++ {
+		if 4+qrCountBits(ver+1)+8*len(data) <= 8*qrVersions[ver].dataCodewords() {
+			break
+		}
+	}
+	if ver == len(qrVersions) {
+		return nil, fmt.Errorf("%d bytes don't fit QR code", len(data))
+	}
+	v := qrVersions[ver]
+	ver++
+
+	// mode indicator, character count and the data, followed by the terminator and padding
+	var bb qrBits
+	bb.append(0x4, 4)
+	bb.append(len(data), qrCountBits(ver))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	capacity := 8 * v.dataCodewords()
+	bb.append(0, min(4, capacity-len(bb)))
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+	codewords := make([]byte, len(bb)/8)
+	for i, bit := range bb {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	// split into blocks, each with its own error correction, and interleave them
+	var blocks, ecs [][]byte
+	div := qrDivisor(v.ecc)
+	for i, off := 0, 0; i < v.blocks1+v.blocks2; i++ {
+		n := v.data1
+		if i >= v.blocks1 {
+			n = v.data2
+		}
+		blocks = append(blocks, codewords[off:off+n])
+		ecs = append(ecs, qrRemainder(codewords[off:off+n], div))
+		off += n
+	}
+	var stream []byte
+	for i := 0; i < max(v.data1, v.data2); i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				stream = append(stream, b[i])
+			}
+		}
+	}
+	for i := 0; i < v.ecc; i++ {
+		for _, ec := range ecs {
+			stream = append(stream, ec[i])
+		}
+	}
+
+	q := newQRMatrix(ver, v.align)
+	q.placeCodewords(stream)
+
+	// choose the mask, that gives the lowest penalty
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		q.applyMask(mask) // masks are XOR, so applying it again undoes it
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+	return q.modules, nil
+}
+
+// qrCountBits returns the length of character count of byte mode in the version.
+func qrCountBits(ver int) int {
+	if ver < 10 {
+		return 8
+	}
+	return 16
+}
+
+type qrBits []bool
+
+func (bb *qrBits) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*bb = append(*bb, v>>i&1 != 0)
+	}
+}
+
+type qrMatrix struct {
+	size     int
+	modules  [][]bool
+	function [][]bool // modules of function patterns, that aren't data and aren't masked
+}
+
+func newQRMatrix(ver int, align []int) *qrMatrix {
+	size := 17 + 4*ver
+	q := &qrMatrix{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.function[i] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	q.drawFinder(3, 3)
+	q.drawFinder(size-4, 3)
+	q.drawFinder(3, size-4)
+	for i, r := range align {
+		for j, c := range align {
+			// alignment patterns don't overlap finder patterns
+			last := len(align) - 1
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(r+dy, c+dx, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	// reserve format areas, they're drawn after masking
+	q.drawFormat(0)
+	if ver >= 7 {
+		rem := ver
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ rem>>11*0x1F25
+		}
+		bits := ver<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>i&1 != 0
+			a, b := size-11+i%3, i/3
+			q.set(b, a, dark)
+			q.set(a, b, dark)
+		}
+	}
+	return q
+}
+
+func (q *qrMatrix) set(row, col int, dark bool) {
+	q.modules[row][col] = dark
+	q.function[row][col] = true
+}
+
+func (q *qrMatrix) drawFinder(row, col int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			r, c := row+dy, col+dx
+			if r < 0 || r >= q.size || c < 0 || c >= q.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			q.set(r, c, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawFormat draws both copies of format information of level M with the mask, and the dark module.
+func (q *qrMatrix) drawFormat(mask int) {
+	data := mask // level M is 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ rem>>9*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		q.set(i, 8, bit(i))
+	}
+	q.set(7, 8, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(8, 7, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(8, 14-i, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		q.set(8, q.size-1-i, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(q.size-15+i, 8, bit(i))
+	}
+	q.set(q.size-8, 8, true)
+}
+
+// placeCodewords places the bits of codewords in zigzag columns of two modules from the bottom-right
+// corner, skipping function patterns. Remainder modules are left light.
+func (q *qrMatrix) placeCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skip vertical timing pattern
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.size; vert++ {
+			row := vert
+			if upward {
+				row = q.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				col := right - j
+				if q.function[row][col] || i >= len(data)*8 {
+					continue
+				}
+				q.modules[row][col] = data[i/8]>>(7-i%8)&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+func (q *qrMatrix) applyMask(mask int) {
+	for r := 0; r < q.size; r++ {
+		for c := 0; c < q.size; c++ {
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (r+c)%2 == 0
+			case 1:
+				flip = r%2 == 0
+			case 2:
+				flip = c%3 == 0
+			case 3:
+				flip = (r+c)%3 == 0
+			case 4:
+				flip = (r/2+c/3)%2 == 0
+			case 5:
+				flip = r*c%2+r*c%3 == 0
+			case 6:
+				flip = (r*c%2+r*c%3)%2 == 0
+			case 7:
+				flip = ((r+c)%2+r*c%3)%2 == 0
+			}
+			if flip && !q.function[r][c] {
+				q.modules[r][c] = !q.modules[r][c]
+			}
+		}
+	}
+}
+
+// penalty scores the look of the masked code: long runs and blocks of the same color, patterns, that look
+// like finders, and the imbalance of dark and light modules.
+func (q *qrMatrix) penalty() int {
+	at := func(r, c int, transpose bool) bool {
+		if transpose {
+			return q.modules[c][r]
+		}
+		return q.modules[r][c]
+	}
+	finder := []bool{true, false, true, true, true, false, true}
+	p := 0
+	for _, transpose := range []bool{false, true} {
+		for r := 0; r < q.size; r++ {
+			run := 1
+			for c := 1; c <= q.size; c++ {
+				if c < q.size && at(r, c, transpose) == at(r, c-1, transpose) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += 3 + run - 5
+				}
+				run = 1
+			}
+			// finder-like pattern with 4 light modules on either side
+			for c := 0; c+7 <= q.size; c++ {
+				match := true
+				for k, dark := range finder {
+					match = match && at(r, c+k, transpose) == dark
+				}
+				if !match {
+					continue
+				}
+				before, after := c >= 4, c+11 <= q.size
+				for k := 1; k <= 4; k++ {
+					before = before && !at(r, c-k, transpose)
+					after = after && !at(r, c+6+k, transpose)
+				}
+				if before || after {
+					p += 40
+				}
+			}
+		}
+	}
+	dark := 0
+	for r := 0; r < q.size; r++ {
+		for c := 0; c < q.size; c++ {
+			if q.modules[r][c] {
+				dark++
+			}
+			if r > 0 && c > 0 {
+				v := q.modules[r][c]
+				if q.modules[r-1][c] == v && q.modules[r][c-1] == v && q.modules[r-1][c-1] == v {
+					p += 3
+				}
+			}
+		}
+	}
+	total := q.size * q.size
+	p += abs(dark*20-total*10) / total * 10
+	return p
+}
+
+// qrDivisor returns the Reed-Solomon generator polynomial of the degree, without the leading term.
+func qrDivisor(degree int) []byte {
+	div := make([]byte, degree)
+	div[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range div {
+			div[j] = qrMul(div[j], root)
+			if j+1 < degree {
+				div[j] ^= div[j+1]
+			}
+		}
+		root = qrMul(root, 2)
+	}
+	return div
+}
+
+// qrRemainder returns Reed-Solomon error correction codewords of data.
+func qrRemainder(data, div []byte) []byte {
+	rem := make([]byte, len(div))
+	for _, b := range data {
+		factor := b ^ rem[0]
+		copy(rem, rem[1:])
+		rem[len(rem)-1] = 0
+		for i := range rem {
+			rem[i] ^= qrMul(div[i], factor)
+		}
+	}
+	return rem
+}
+
+// qrMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func qrMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ z>>7*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
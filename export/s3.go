@@ -0,0 +1,257 @@
+package export
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// S3Scheme is the scheme of output paths, that are uploaded to S3-compatible storage, e.g.
+// "s3://bucket/clinics.json".
+const S3Scheme = "s3://"
+
+// IsS3 reports whether the output path is S3 URL.
+func IsS3(path string) bool {
+	return strings.HasPrefix(path, S3Scheme)
+}
+
+// contentTypes are the Content-Type headers of uploaded outputs by format.
+var contentTypes = map[string]string{
+	"json":       "application/json",
+	"js":         "text/javascript; charset=utf-8",
+	"geojson":    "application/geo+json",
+	"ndjson":     "application/x-ndjson",
+	"delta":      "application/json",
+	"mobile":     "application/json",
+	"exec":       "application/json",
+	"yaml":       "application/yaml",
+	"xml":        "application/xml",
+	"html":       "text/html; charset=utf-8",
+	"vcard":      "text/vcard; charset=utf-8",
+	"ymaps-csv":  "text/csv; charset=utf-8",
+	"mymaps-csv": "text/csv; charset=utf-8",
+	"review-csv": "text/csv; charset=utf-8",
+	"go":         "text/plain; charset=utf-8",
+	"template":   "text/plain; charset=utf-8",
+	"protobuf":   "application/x-protobuf",
+	"sqlite":     "application/vnd.sqlite3",
+}
+
+// s3Config is the configuration of S3 client, taken from the same environment variables as AWS CLI uses.
+type s3Config struct {
+	endpoint     string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+func s3ConfigFromEnv() (*s3Config, error) {
+	c := &s3Config{
+		endpoint:     os.Getenv("AWS_ENDPOINT_URL_S3"),
+		region:       os.Getenv("AWS_REGION"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.endpoint == "" {
+		c.endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if c.region == "" {
+		c.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if c.region == "" {
+		c.region = "us-east-1"
+	}
+	if c.endpoint == "" {
+		c.endpoint = "https://s3." + c.region + ".amazonaws.com"
+	}
+	if c.accessKey == "" || c.secretKey == "" {
+		return nil, fmt.Errorf("s3 output requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return c, nil
+}
+
+// writeS3 writes clinics into a temporary file, along with its checksum, and uploads them to the
+// bucket, so a failed write never replaces the object. Objects are uploaded with Content-Type of the
+// format, Content-Encoding, if the output is gzipped, and opts.CacheControl. The storage is configured
+// with AWS_ENDPOINT_URL (for S3-compatible storage), AWS_REGION, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+// and AWS_SESSION_TOKEN environment variables.
+func writeS3(path, format string, clinics []*dmsparse.Clinic, opts *Options) error {
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(path, S3Scheme), "/")
+	if !ok || bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+		return fmt.Errorf("invalid s3 URL %q, expected s3://bucket/key", path)
+	}
+	conf, err := s3ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "gen_points-s3")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	// the temporary file has the key's name, so compression is detected by it, and its checksum names it
+	local := filepath.Join(dir, filepath.Base(key))
+	if err := WriteFile(local, format, clinics, opts); err != nil {
+		return err
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", contentTypes[format])
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+	if opts.Compress == "gzip" || opts.Compress == "" && strings.HasSuffix(key, ".gz") {
+		header.Set("Content-Encoding", "gzip")
+	}
+	if opts.CacheControl != "" {
+		header.Set("Cache-Control", opts.CacheControl)
+	}
+	if err := conf.put(bucket, key, local, header); err != nil {
+		return err
+	}
+
+	for _, ext := range []string{ChecksumExt, SignatureExt} {
+		if _, err := os.Stat(local + ext); err != nil {
+			continue
+		}
+		header := make(http.Header)
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		if opts.CacheControl != "" {
+			header.Set("Cache-Control", opts.CacheControl)
+		}
+		if err := conf.put(bucket, key+ext, local+ext, header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// put uploads the file as the object with PUT request, signed with AWS Signature Version 4.
+// Buckets are addressed in path style, which S3-compatible storages support.
+func (c *s3Config) put(bucket, key, file string, header http.Header) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(c.endpoint, "/") + "/" + bucket + "/" + key)
+	if err != nil {
+		return err
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	req, err := http.NewRequest(http.MethodPut, u.String(), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	c.sign(req, hex.EncodeToString(h.Sum(nil)), time.Now())
+
+	resp, err := apiHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("could not upload s3://%s/%s: %s, %s", bucket, key, resp.Status, truncate(data, 1024))
+	}
+	return nil
+}
+
+// sign signs the request with AWS Signature Version 4. All headers of the request are signed.
+func (c *s3Config) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+// s3EscapePath escapes the path as SigV4 requires: all bytes, but unreserved characters and slashes.
+func s3EscapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
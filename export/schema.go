@@ -0,0 +1,121 @@
+package export
+
+// JSONSchema is JSON Schema of the json output document, either plain clinic list or
+// the envelope. It must be kept in sync with dmsparse.Clinic and Envelope.
+const JSONSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://github.com/narqo/vtb-dms/schema/clinics.json",
+  "title": "VTB DMS clinics",
+  "oneOf": [
+    {"$ref": "#/$defs/clinicList"},
+    {"$ref": "#/$defs/envelope"}
+  ],
+  "$defs": {
+    "clinicList": {
+      "type": "array",
+      "items": {"$ref": "#/$defs/clinic"}
+    },
+    "clinic": {
+      "type": "object",
+      "required": ["id", "name", "raw_address", "phone", "points"],
+      "properties": {
+        "id": {"type": "string", "description": "Stable clinic identifier, derived from name and raw address."},
+        "name": {"type": "string"},
+        "raw_address": {"type": "string", "description": "Address as written in the source document."},
+        "phone": {"type": "string", "description": "Comma-separated phone numbers."},
+        "address": {"type": "string", "description": "Address normalized by geocoder."},
+        "city": {"type": "string"},
+        "categories": {"type": "array", "items": {"type": "string"}, "description": "Categories by name, e.g. dental, polyclinic, hospital, lab or pediatric."},
+        "programs": {"type": "array", "items": {"type": "string"}, "description": "DMS programs, e.g. tiers of the policy, that cover the clinic, as titled in the sections of the source document."},
+        "district": {"type": "string", "description": "Administrative district of the city, e.g. an okrug of Moscow."},
+        "precision": {"type": "string", "description": "Geocoder precision, e.g. exact, number, street."},
+        "confidence": {"type": "number", "minimum": 0, "maximum": 1, "description": "How much the points can be trusted; omitted if clinic wasn't geocoded."},
+        "points": {
+          "description": "Coordinates, ordered according to CoordOrder option; null if clinic wasn't geocoded.",
+          "oneOf": [
+            {"type": "null"},
+            {"type": "array", "items": {"type": "number"}, "minItems": 2, "maxItems": 2}
+          ]
+        },
+        "lat": {"type": "number", "minimum": -90, "maximum": 90},
+        "lon": {"type": "number", "minimum": -180, "maximum": 180},
+        "license": {
+          "type": "object",
+          "description": "Medical license, found in the license registry; omitted if the dataset wasn't enriched with it, or the clinic wasn't found.",
+          "required": ["number", "status", "active"],
+          "properties": {
+            "number": {"type": "string"},
+            "status": {"type": "string", "description": "Status as written in the registry."},
+            "active": {"type": "boolean", "description": "False if the license was terminated or suspended."},
+            "inn": {"type": "string"}
+          }
+        },
+        "place": {
+          "type": "object",
+          "description": "Card in Yandex Maps organizations directory; omitted if the dataset wasn't enriched with it, or the card wasn't found.",
+          "required": ["id"],
+          "properties": {
+            "id": {"type": "string"},
+            "rating": {"type": "number"},
+            "reviews": {"type": "integer", "minimum": 0},
+            "hours": {"type": "string", "description": "Opening hours as written in the card."},
+            "url": {"type": "string"}
+          }
+        },
+        "firm": {
+          "type": "object",
+          "description": "Card in 2GIS directory; omitted if the dataset wasn't enriched with it, or the card wasn't found.",
+          "required": ["id"],
+          "properties": {
+            "id": {"type": "string"},
+            "entrance": {
+              "type": "object",
+              "required": ["lat", "lon"],
+              "properties": {
+                "lat": {"type": "number", "minimum": -90, "maximum": 90},
+                "lon": {"type": "number", "minimum": -180, "maximum": 180}
+              }
+            },
+            "floor": {"type": "string", "description": "Location within the building as written in the card."},
+            "photos_url": {"type": "string"}
+          }
+        },
+        "travel": {
+          "type": "object",
+          "description": "Travel time by routes; omitted if the dataset wasn't enriched with it.",
+          "properties": {
+            "metro": {"type": "string", "description": "Metro station, nearest to the clinic."},
+            "walk_minutes": {"type": "integer", "minimum": 1, "description": "Walking time from the metro station."},
+            "drive_minutes": {"type": "integer", "minimum": 1, "description": "Driving time from the reference point."}
+          }
+        }
+      },
+      "dependentRequired": {
+        "lat": ["lon"],
+        "lon": ["lat"]
+      }
+    },
+    "envelope": {
+      "type": "object",
+      "required": ["schema_version", "generated_at", "source_sha256", "provider", "counts", "clinics"],
+      "properties": {
+        "schema_version": {"type": "integer", "const": 1},
+        "generated_at": {"type": "string", "format": "date-time"},
+        "source_sha256": {"type": "string", "pattern": "^[0-9a-f]{64}$"},
+        "provider": {"type": "string"},
+        "generator": {"type": "string", "description": "Name and version of the tool, that built the dataset."},
+        "counts": {
+          "type": "object",
+          "required": ["total", "geocoded", "failed"],
+          "properties": {
+            "total": {"type": "integer", "minimum": 0},
+            "geocoded": {"type": "integer", "minimum": 0},
+            "failed": {"type": "integer", "minimum": 0}
+          }
+        },
+        "clinics": {"$ref": "#/$defs/clinicList"}
+      }
+    }
+  }
+}
+`
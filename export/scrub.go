@@ -0,0 +1,125 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// ScrubFields are the fields of clinics, that can be scrubbed from outputs.
+var ScrubFields = []string{"raw_address", "address", "phone", "city", "precision", "confidence"}
+
+// ScrubRule strips a field of clinics from outputs or masks it, so a public dataset doesn't expose
+// e.g. internal phone numbers.
+type ScrubRule struct {
+	Field string
+	// Mask masks the field instead of stripping it: phones keep the last 2 digits, other text
+	// fields keep the first letter of each word.
+	Mask bool
+}
+
+// ParseScrubRule parses a rule as "field" or "field:mask".
+func ParseScrubRule(s string) (ScrubRule, error) {
+	field, mode, _ := strings.Cut(s, ":")
+	r := ScrubRule{Field: field}
+	switch mode {
+	case "", "strip":
+	case "mask":
+		r.Mask = true
+	default:
+		return r, fmt.Errorf("unknown scrub mode %q of %q, expected strip or mask", mode, s)
+	}
+	for _, f := range ScrubFields {
+		if f == field {
+			if r.Mask && (field == "precision" || field == "confidence") {
+				return r, fmt.Errorf("field %q can't be masked", field)
+			}
+			return r, nil
+		}
+	}
+	return r, fmt.Errorf("unknown scrub field %q (fields: %s)", field, strings.Join(ScrubFields, ", "))
+}
+
+// Scrub returns copies of clinics with fields scrubbed according to the rules. Clinics aren't
+// modified, so the same dataset can be written both in full and scrubbed.
+func Scrub(clinics []*dmsparse.Clinic, rules []ScrubRule) []*dmsparse.Clinic {
+	if len(rules) == 0 {
+		return clinics
+	}
+	scrubbed := make([]*dmsparse.Clinic, len(clinics))
+	for i, cc := range clinics {
+		c := *cc
+		for _, r := range rules {
+			switch r.Field {
+			case "raw_address":
+				c.RawAddress = scrubText(c.RawAddress, r.Mask)
+			case "address":
+				c.Address = scrubText(c.Address, r.Mask)
+			case "city":
+				c.City = scrubText(c.City, r.Mask)
+			case "phone":
+				c.Phone = scrubPhone(c.Phone, r.Mask)
+			case "precision":
+				c.Precision = ""
+			case "confidence":
+				c.Confidence = 0
+			}
+		}
+		scrubbed[i] = &c
+	}
+	return scrubbed
+}
+
+func scrubText(s string, mask bool) string {
+	if !mask {
+		return ""
+	}
+	var (
+		b     strings.Builder
+		first = true
+	)
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if first {
+				b.WriteRune(r)
+			} else {
+				b.WriteByte('*')
+			}
+			first = false
+			continue
+		}
+		b.WriteRune(r)
+		first = true
+	}
+	return b.String()
+}
+
+// scrubPhone masks all digits of each phone, but the last 2, e.g. "8 (495) 925-88-78" -> "* (***) ***-**-78".
+func scrubPhone(s string, mask bool) string {
+	if !mask {
+		return ""
+	}
+	phones := dmsparse.SplitPhones(s)
+	for i, p := range phones {
+		var digits int
+		for _, r := range p {
+			if unicode.IsDigit(r) {
+				digits++
+			}
+		}
+		var b strings.Builder
+		for _, r := range p {
+			if unicode.IsDigit(r) {
+				digits--
+				if digits >= 2 {
+					r = '*'
+				}
+			}
+			b.WriteRune(r)
+		}
+		phones[i] = b.String()
+	}
+	return strings.Join(phones, ", ")
+}
@@ -0,0 +1,205 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// SiteCity is a city of the static site.
+type SiteCity struct {
+	Name    string
+	Slug    string
+	Clinics []*SiteClinic
+}
+
+// SiteClinic is a clinic page of the static site.
+type SiteClinic struct {
+	*dmsparse.Clinic
+	City   *SiteCity
+	Phones []string
+	// Path is the path of the page relative to the site's root.
+	Path string
+}
+
+// Lat and Lon return clinic's coordinates as strings, or empty strings if clinic wasn't geocoded.
+func (c *SiteClinic) Lat() string {
+	lat, _, ok := c.LatLon()
+	if !ok {
+		return ""
+	}
+	return formatFloat(lat)
+}
+
+func (c *SiteClinic) Lon() string {
+	_, lon, ok := c.LatLon()
+	if !ok {
+		return ""
+	}
+	return formatFloat(lon)
+}
+
+// WriteSite renders a static site into dir: index.html with the list of cities, city/index.html
+// with the list of city's clinics and city/id.html for each clinic. The pages are rendered with
+// html/template files index.html, city.html and clinic.html from templatesDir; templates, missing
+// there or if templatesDir is empty, are the built-in ones.
+func WriteSite(dir, templatesDir string, clinics []*dmsparse.Clinic) error {
+	tmpls := map[string]*template.Template{}
+	for name, text := range siteTemplates {
+		path := filepath.Join(templatesDir, name)
+		if templatesDir != "" {
+			if data, err := os.ReadFile(path); err == nil {
+				text = string(data)
+			} else if !os.IsNotExist(err) {
+				return err
+			}
+		}
+		t, err := template.New(name).Parse(text)
+		if err != nil {
+			return fmt.Errorf("site template %s: %v", name, err)
+		}
+		tmpls[name] = t
+	}
+
+	var (
+		cities = make(map[string]*SiteCity)
+		list   []*SiteCity
+	)
+	for _, cc := range clinics {
+		slug := slugify(cc.City)
+		if slug == "" {
+			slug = "unknown"
+		}
+		city, ok := cities[slug]
+		if !ok {
+			name := cc.City
+			if name == "" {
+				name = "Город не определён"
+			}
+			city = &SiteCity{Name: name, Slug: slug}
+			cities[slug] = city
+			list = append(list, city)
+		}
+		city.Clinics = append(city.Clinics, &SiteClinic{
+			Clinic: cc,
+			City:   city,
+			Phones: dmsparse.SplitPhones(cc.Phone),
+			Path:   slug + "/" + cc.ID + ".html",
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if len(list[i].Clinics) != len(list[j].Clinics) {
+			return len(list[i].Clinics) > len(list[j].Clinics)
+		}
+		return list[i].Name < list[j].Name
+	})
+
+	if err := renderPage(tmpls["index.html"], filepath.Join(dir, "index.html"), list); err != nil {
+		return err
+	}
+	for _, city := range list {
+		if err := os.MkdirAll(filepath.Join(dir, city.Slug), 0755); err != nil {
+			return err
+		}
+		if err := renderPage(tmpls["city.html"], filepath.Join(dir, city.Slug, "index.html"), city); err != nil {
+			return err
+		}
+		for _, c := range city.Clinics {
+			if err := renderPage(tmpls["clinic.html"], filepath.Join(dir, filepath.FromSlash(c.Path)), c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func renderPage(t *template.Template, path string, data interface{}) error {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// siteTemplates are the built-in templates of the static site.
+var siteTemplates = map[string]string{
+	"index.html": `<!DOCTYPE html>
+<html lang="ru">
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>Клиники ДМС по городам</title>
+</head>
+<body>
+    <h1>Клиники ДМС по городам</h1>
+    <ul>
+    {{- range .}}
+        <li><a href="{{.Slug}}/index.html">{{.Name}}</a> ({{len .Clinics}})</li>
+    {{- end}}
+    </ul>
+</body>
+</html>
+`,
+	"city.html": `<!DOCTYPE html>
+<html lang="ru">
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>Клиники ДМС: {{.Name}}</title>
+</head>
+<body>
+    <p><a href="../index.html">Все города</a></p>
+    <h1>Клиники ДМС: {{.Name}}</h1>
+    <ul>
+    {{- range .Clinics}}
+        <li><a href="{{.ID}}.html">{{.Name}}</a><br>{{if .Address}}{{.Address}}{{else}}{{.RawAddress}}{{end}}</li>
+    {{- end}}
+    </ul>
+</body>
+</html>
+`,
+	"clinic.html": `<!DOCTYPE html>
+<html lang="ru">
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>{{.Name}}, {{.City.Name}}</title>
+    <meta name="description" content="{{.Name}}: {{.RawAddress}}">
+    {{- if .Lat}}
+    <script src="https://api-maps.yandex.ru/2.1/?lang=ru_RU" type="text/javascript"></script>
+    {{- end}}
+    <style>#map { width: 100%; max-width: 800px; height: 400px; }</style>
+</head>
+<body>
+    <p><a href="../index.html">Все города</a> / <a href="index.html">{{.City.Name}}</a></p>
+    <h1>{{.Name}}</h1>
+    <p>{{.RawAddress}}</p>
+    {{- if .Phones}}
+    <ul>
+    {{- range .Phones}}
+        <li>{{.}}</li>
+    {{- end}}
+    </ul>
+    {{- end}}
+    {{- if .Lat}}
+    <div id="map"></div>
+    <script>
+        ymaps.ready(() => {
+            const point = [{{.Lat}}, {{.Lon}}];
+            const map = new ymaps.Map('map', {center: point, zoom: 16});
+            map.geoObjects.add(new ymaps.Placemark(point, {balloonContent: {{.Name}}}));
+        });
+    </script>
+    {{- end}}
+</body>
+</html>
+`,
+}
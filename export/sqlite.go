@@ -0,0 +1,91 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+const sqliteSchema = `
+DROP TABLE IF EXISTS clinics;
+CREATE TABLE clinics (
+	id          TEXT PRIMARY KEY,
+	name        TEXT NOT NULL,
+	raw_address TEXT NOT NULL,
+	phone       TEXT NOT NULL,
+	address     TEXT,
+	city        TEXT,
+	lat         REAL,
+	lon         REAL
+);
+CREATE INDEX clinics_city_idx ON clinics (city);
+CREATE INDEX clinics_coords_idx ON clinics (lat, lon);
+`
+
+// WriteSQLite writes clinics into SQLite database file at path. Rows are keyed by clinic ID, so apps
+// can match them across builds of the database. The database is built by piping SQL script into
+// sqlite3 binary (opts.SQLiteBin), so no cgo driver is required.
+func WriteSQLite(path string, clinics []*dmsparse.Clinic, opts *Options) error {
+	if path == "" || path == "-" {
+		return fmt.Errorf("sqlite output requires output file")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("BEGIN;\n")
+	buf.WriteString(sqliteSchema)
+	for _, cc := range clinics {
+		lat, lon := "NULL", "NULL"
+		if la, lo, ok := cc.LatLon(); ok {
+			lat = strconv.FormatFloat(la, 'f', -1, 64)
+			lon = strconv.FormatFloat(lo, 'f', -1, 64)
+		}
+		fmt.Fprintf(&buf,
+			"INSERT OR REPLACE INTO clinics (id, name, raw_address, phone, address, city, lat, lon) VALUES (%s, %s, %s, %s, %s, %s, %s, %s);\n",
+			sqlQuote(cc.ID),
+			sqlQuote(cc.Name),
+			sqlQuote(cc.RawAddress),
+			sqlQuote(cc.Phone),
+			sqlNullString(cc.Address),
+			sqlNullString(cc.City),
+			lat,
+			lon,
+		)
+	}
+	buf.WriteString("COMMIT;\n")
+
+	// build the database in a temporary file, so readers never see a partially written one
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+
+	bin := opts.SQLiteBin
+	if bin == "" {
+		bin = "sqlite3"
+	}
+	cmd := exec.Command(bin, "-bail", f.Name())
+	cmd.Stdin = &buf
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		f.Abort()
+		return fmt.Errorf("could not run %s: %v", bin, err)
+	}
+	return f.Commit()
+}
+
+func sqlQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func sqlNullString(s string) string {
+	if s == "" {
+		return "NULL"
+	}
+	return sqlQuote(s)
+}
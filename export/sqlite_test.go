@@ -0,0 +1,42 @@
+package export
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+func TestWriteSQLiteKeysRowsByID(t *testing.T) {
+	bin, err := exec.LookPath("sqlite3")
+	if err != nil {
+		t.Skip("sqlite3 binary not found")
+	}
+	path := filepath.Join(t.TempDir(), "clinics.db")
+	clinics := []*dmsparse.Clinic{
+		{ID: "b2", Name: "Клиника", RawAddress: "г. Москва, ул. Новая, д. 1", Phone: "+7 495 000-00-00", Points: []float64{55.75, 37.61}},
+		{ID: "a1", Name: "O'Clinic", RawAddress: "г. Казань", Phone: ""},
+	}
+	if err := WriteSQLite(path, clinics, &Options{SQLiteBin: bin}); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(bin, path, "SELECT id, name, lat IS NULL FROM clinics ORDER BY id").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a1|O'Clinic|1\nb2|Клиника|0\n"
+	if got := string(out); got != want {
+		t.Errorf("rows:\n%s\nwant:\n%s", got, want)
+	}
+
+	out, err = exec.Command(bin, path, "SELECT type FROM pragma_table_info('clinics') WHERE name = 'id'").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "TEXT" {
+		t.Errorf("id column type = %q, want TEXT", got)
+	}
+}
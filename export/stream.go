@@ -0,0 +1,204 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// writeNDJSON writes clinics as newline-delimited json, one clinic per line.
+func writeNDJSON(w io.Writer, clinics []*dmsparse.Clinic, opts *Options) error {
+	enc := json.NewEncoder(w)
+	for _, cc := range clinics {
+		v, err := jsonClinic(cc, opts.CoordOrder)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadNDJSON reads clinics from newline-delimited json, one clinic per line. Blank lines are skipped.
+// Lines are checked against the schema, and all violations are returned as SchemaError.
+func ReadNDJSON(r io.Reader) ([]*dmsparse.Clinic, error) {
+	var clinics []*dmsparse.Clinic
+	err := ReadNDJSONFunc(r, func(cc *dmsparse.Clinic) error {
+		clinics = append(clinics, cc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return clinics, nil
+}
+
+// ReadNDJSONFunc reads clinics from newline-delimited json as ReadNDJSON does, and calls fn for each
+// valid clinic as soon as it's read. Violations of the schema are returned as SchemaError after
+// the whole input is read. It stops at the first error of fn.
+func ReadNDJSONFunc(r io.Reader, fn func(cc *dmsparse.Clinic) error) error {
+	errs := &SchemaError{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 16<<20)
+	for line := 1; sc.Scan(); line++ {
+		data := bytes.TrimSpace(sc.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if !json.Valid(data) {
+			var v interface{}
+			errs.Add(line, "", "%v", json.Unmarshal(data, &v))
+			continue
+		}
+		n := len(errs.Violations)
+		checkClinicRecord(errs, line, data)
+		if len(errs.Violations) > n {
+			continue
+		}
+		var cc dmsparse.Clinic
+		if err := json.Unmarshal(data, &cc); err != nil {
+			errs.Add(line, "", "%v", err)
+			continue
+		}
+		if err := fn(&cc); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return errs.Err()
+}
+
+func jsonClinic(cc *dmsparse.Clinic, order string) (interface{}, error) {
+	switch order {
+	case "", "latlon":
+		return cc, nil
+	case "lonlat":
+		return dmsparse.LonLat{Clinic: cc}, nil
+	}
+	return nil, fmt.Errorf("unknown coordinates order: %q", order)
+}
+
+// StreamWriter writes clinics as NDJSON as soon as they are processed, so long runs produce
+// usable partial data. Unlike WriteFile, the file isn't replaced atomically. Methods of nil
+// StreamWriter are no-op.
+type StreamWriter struct {
+	mu     sync.Mutex
+	f      *os.File
+	atomic *atomicFile
+	enc    *json.Encoder
+	order  string
+	err    error
+}
+
+// NewStreamWriter creates NDJSON stream at path, or writes it to stdout if path is "-".
+func NewStreamWriter(path string, opts *Options) (*StreamWriter, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	f := os.Stdout
+	if path != "-" {
+		var err error
+		f, err = os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &StreamWriter{
+		f:     f,
+		enc:   json.NewEncoder(f),
+		order: opts.CoordOrder,
+	}, nil
+}
+
+// NewAtomicStreamWriter creates NDJSON stream, which is written into a temporary file and replaces
+// the file at path on Close, so an interrupted or failed run keeps the previous file. It writes
+// the stream to stdout if path is "-".
+func NewAtomicStreamWriter(path string, opts *Options) (*StreamWriter, error) {
+	if path == "-" {
+		return NewStreamWriter(path, opts)
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	f, err := createAtomic(path)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamWriter{
+		f:      f.File,
+		atomic: f,
+		enc:    json.NewEncoder(f),
+		order:  opts.CoordOrder,
+	}, nil
+}
+
+func (s *StreamWriter) Write(cc *dmsparse.Clinic) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	// the encoder isn't buffered, so each clinic hits the file with a single write
+	v, err := jsonClinic(cc, s.order)
+	if err != nil {
+		s.err = err
+		return
+	}
+	if err := s.enc.Encode(v); err != nil {
+		s.err = fmt.Errorf("could not write stream: %v", err)
+	}
+}
+
+// Close closes the stream and reports the first error occurred while writing it. The stream of
+// NewAtomicStreamWriter replaces the file only if there was no error.
+func (s *StreamWriter) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.atomic != nil {
+		if s.err != nil {
+			s.atomic.Abort()
+		} else {
+			s.err = s.atomic.Commit()
+		}
+		return s.err
+	}
+	if s.f != os.Stdout {
+		if err := s.f.Close(); err != nil && s.err == nil {
+			s.err = err
+		}
+	}
+	return s.err
+}
+
+// Abort closes the stream of NewAtomicStreamWriter discarding what was written, and keeps the file
+// at path as it was. Other streams are just closed.
+func (s *StreamWriter) Abort() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.atomic != nil {
+		s.atomic.Abort()
+		return
+	}
+	if s.f != os.Stdout {
+		s.f.Close()
+	}
+}
@@ -0,0 +1,50 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/template"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// templateFuncs are available to user-supplied templates in addition to text/template builtins.
+var templateFuncs = template.FuncMap{
+	"sql": sqlQuote,
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"lat": func(cc *dmsparse.Clinic) string {
+		lat, _, ok := cc.LatLon()
+		if !ok {
+			return ""
+		}
+		return formatFloat(lat)
+	},
+	"lon": func(cc *dmsparse.Clinic) string {
+		_, lon, ok := cc.LatLon()
+		if !ok {
+			return ""
+		}
+		return formatFloat(lon)
+	},
+}
+
+// writeTemplate renders clinics through the Go template from file at path. The template
+// is executed with Envelope as its data, e.g.
+//
+//	{{range .Clinics}}INSERT INTO clinics VALUES ({{sql .ID}}, {{sql .Name}}, {{lat .}}, {{lon .}});
+//	{{end}}
+func writeTemplate(w io.Writer, path string, clinics []*dmsparse.Clinic, opts *Options) error {
+	if path == "" {
+		return fmt.Errorf("template output requires template file")
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, NewEnvelope(clinics, opts))
+}
@@ -0,0 +1,68 @@
+package export
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// writeVCard writes clinics as vCard 3.0 (RFC 2426) contacts, one card per clinic.
+func writeVCard(w io.Writer, clinics []*dmsparse.Clinic) error {
+	bw := bufio.NewWriter(w)
+	for _, cc := range clinics {
+		addr := cc.Address
+		if addr == "" {
+			addr = cc.RawAddress
+		}
+		vcardLine(bw, "BEGIN:VCARD")
+		vcardLine(bw, "VERSION:3.0")
+		vcardLine(bw, "UID:"+vcardEscape(cc.ID))
+		vcardLine(bw, "FN:"+vcardEscape(cc.Name))
+		vcardLine(bw, "N:"+vcardEscape(cc.Name)+";;;;")
+		vcardLine(bw, "ORG:"+vcardEscape(cc.Name))
+		vcardLine(bw, "ADR;TYPE=WORK:;;"+vcardEscape(addr)+";"+vcardEscape(cc.City)+";;;")
+		vcardLine(bw, "LABEL;TYPE=WORK:"+vcardEscape(addr))
+		for _, phone := range dmsparse.SplitPhones(cc.Phone) {
+			vcardLine(bw, "TEL;TYPE=WORK,VOICE:"+vcardEscape(phone))
+		}
+		if lat, lon, ok := cc.LatLon(); ok {
+			vcardLine(bw, "GEO:"+formatFloat(lat)+";"+formatFloat(lon))
+		}
+		vcardLine(bw, "END:VCARD")
+	}
+	return bw.Flush()
+}
+
+var vcardEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\n", `\n`)
+
+func vcardEscape(s string) string {
+	return vcardEscaper.Replace(s)
+}
+
+// vcardLine writes content line, folding it at 75 octets without breaking UTF-8 sequences.
+func vcardLine(w *bufio.Writer, line string) {
+	const maxLen = 75
+	for first := true; ; first = false {
+		limit := maxLen
+		if !first {
+			// continuation lines start with a space
+			w.WriteByte(' ')
+			limit--
+		}
+		if len(line) <= limit {
+			w.WriteString(line)
+			w.WriteString("\r\n")
+			return
+		}
+		n := limit
+		for n > 0 && !utf8.RuneStart(line[n]) {
+			n--
+		}
+		w.WriteString(line[:n])
+		w.WriteString("\r\n")
+		line = line[n:]
+	}
+}
@@ -0,0 +1,75 @@
+package export
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// writeXML writes clinics as XML document of the following structure:
+//
+//	<?xml version="1.0" encoding="UTF-8"?>
+//	<clinics>
+//	  <clinic id="0a1b2c3d4e5f">
+//	    <name>ООО "ДИРЕКЦИЯ"</name>
+//	    <raw_address>г. Москва, ул.Новая Басманная, д.10, стр.1</raw_address>
+//	    <phone>8 (495) 925-88-78</phone>
+//	    <address>Россия, Москва, Новая Басманная улица, 10с1</address>
+//	    <city>Москва</city>
+//	    <point lat="55.768981" lon="37.656333"></point>
+//	  </clinic>
+//	</clinics>
+//
+// Elements address, city and point are omitted if clinic wasn't geocoded.
+func writeXML(w io.Writer, clinics []*dmsparse.Clinic) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	root := xml.StartElement{Name: xml.Name{Local: "clinics"}}
+	if err := enc.EncodeToken(root); err != nil {
+		return err
+	}
+	// clinics are encoded one by one, so the document isn't built in memory
+	for _, cc := range clinics {
+		xc := xmlClinic{
+			ID:         cc.ID,
+			Name:       cc.Name,
+			RawAddress: cc.RawAddress,
+			Phone:      cc.Phone,
+			Address:    cc.Address,
+			City:       cc.City,
+		}
+		if lat, lon, ok := cc.LatLon(); ok {
+			xc.Point = &xmlPoint{Lat: lat, Lon: lon}
+		}
+		if err := enc.EncodeElement(xc, xml.StartElement{Name: xml.Name{Local: "clinic"}}); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeToken(root.End()); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type xmlClinic struct {
+	ID         string    `xml:"id,attr"`
+	Name       string    `xml:"name"`
+	RawAddress string    `xml:"raw_address"`
+	Phone      string    `xml:"phone"`
+	Address    string    `xml:"address,omitempty"`
+	City       string    `xml:"city,omitempty"`
+	Point      *xmlPoint `xml:"point"`
+}
+
+type xmlPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
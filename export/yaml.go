@@ -0,0 +1,300 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// WriteYAML writes clinics as YAML sequence of mappings with the same fields as json output. Enrichment
+// fields, e.g. license, are written as JSON objects, which are YAML flow mappings. The output is meant to be
+// hand-edited and fed back with ReadYAML.
+func WriteYAML(w io.Writer, clinics []*dmsparse.Clinic) error {
+	bw := bufio.NewWriter(w)
+	for _, cc := range clinics {
+		fmt.Fprintf(bw, "- id: %s\n", yamlQuote(cc.ID))
+		fmt.Fprintf(bw, "  name: %s\n", yamlQuote(cc.Name))
+		fmt.Fprintf(bw, "  raw_address: %s\n", yamlQuote(cc.RawAddress))
+		fmt.Fprintf(bw, "  phone: %s\n", yamlQuote(cc.Phone))
+		fmt.Fprintf(bw, "  address: %s\n", yamlQuote(cc.Address))
+		fmt.Fprintf(bw, "  city: %s\n", yamlQuote(cc.City))
+		if cc.District != "" {
+			fmt.Fprintf(bw, "  district: %s\n", yamlQuote(cc.District))
+		}
+		if len(cc.Programs) > 0 {
+			fmt.Fprintf(bw, "  programs: %s\n", yamlSequence(cc.Programs))
+		}
+		if len(cc.Categories) > 0 {
+			fmt.Fprintf(bw, "  categories: %s\n", yamlSequence(cc.Categories))
+		}
+		if cc.Precision != "" {
+			fmt.Fprintf(bw, "  precision: %s\n", yamlQuote(cc.Precision))
+		}
+		if cc.Confidence != 0 {
+			fmt.Fprintf(bw, "  confidence: %s\n", formatFloat(cc.Confidence))
+		}
+		if lat, lon, ok := cc.LatLon(); ok {
+			fmt.Fprintf(bw, "  points: [%s, %s]\n", formatFloat(lat), formatFloat(lon))
+		} else {
+			fmt.Fprintf(bw, "  points: []\n")
+		}
+		for _, f := range []struct {
+			key string
+			v   interface{}
+			ok  bool
+		}{
+			{"license", cc.License, cc.License != nil},
+			{"place", cc.Place, cc.Place != nil},
+			{"firm", cc.Firm, cc.Firm != nil},
+			{"travel", cc.Travel, cc.Travel != nil},
+		} {
+			if !f.ok {
+				continue
+			}
+			data, err := json.Marshal(f.v)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(bw, "  %s: %s\n", f.key, data)
+		}
+	}
+	return bw.Flush()
+}
+
+func yamlSequence(ss []string) string {
+	quoted := make([]string, len(ss))
+	for i, s := range ss {
+		quoted[i] = yamlQuote(s)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// ReadYAML reads clinics in the format produced by WriteYAML. Only this subset of YAML
+// is supported: a sequence of flat mappings with scalar values, flow sequences of points, programs
+// and categories, and JSON objects of enrichment fields. Comments are skipped; unknown keys are
+// reported with their line numbers, so misspelled fields of hand-edited files aren't lost silently.
+func ReadYAML(r io.Reader) ([]*dmsparse.Clinic, error) {
+	var (
+		clinics []*dmsparse.Clinic
+		cc      *dmsparse.Clinic
+		lineno  int
+	)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		lineno++
+		line := strings.TrimRight(yamlStripComment(s.Text()), " \t\r")
+		if trimmed := strings.TrimSpace(line); trimmed == "" || trimmed[0] == '#' || trimmed == "---" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "- ") {
+			cc = &dmsparse.Clinic{}
+			clinics = append(clinics, cc)
+			line = line[2:]
+		} else if !strings.HasPrefix(line, "  ") || cc == nil {
+			return nil, fmt.Errorf("yaml line %d: unexpected %q", lineno, line)
+		}
+
+		key, val, err := yamlKeyValue(line)
+		if err != nil {
+			return nil, fmt.Errorf("yaml line %d: %v", lineno, err)
+		}
+
+		if key == "points" {
+			cc.Points, err = yamlFloats(val)
+			if err != nil {
+				return nil, fmt.Errorf("yaml line %d: %v", lineno, err)
+			}
+			continue
+		}
+		switch key {
+		case "programs", "categories":
+			ss, err := yamlStrings(val)
+			if err != nil {
+				return nil, fmt.Errorf("yaml line %d: %v", lineno, err)
+			}
+			if key == "programs" {
+				cc.Programs = ss
+			} else {
+				cc.Categories = ss
+			}
+			continue
+		case "license", "place", "firm", "travel":
+			if err := yamlObject(cc, key, val); err != nil {
+				return nil, fmt.Errorf("yaml line %d: invalid %s: %v", lineno, key, err)
+			}
+			continue
+		}
+
+		str, err := yamlUnquote(val)
+		if err != nil {
+			return nil, fmt.Errorf("yaml line %d: %v", lineno, err)
+		}
+		switch key {
+		case "id":
+			cc.ID = str
+		case "name":
+			cc.Name = str
+		case "raw_address":
+			cc.RawAddress = str
+		case "phone":
+			cc.Phone = str
+		case "address":
+			cc.Address = str
+		case "city":
+			cc.City = str
+		case "district":
+			cc.District = str
+		case "precision":
+			cc.Precision = str
+		case "confidence":
+			if cc.Confidence, err = strconv.ParseFloat(str, 64); err != nil {
+				return nil, fmt.Errorf("yaml line %d: invalid confidence %q", lineno, str)
+			}
+		default:
+			return nil, fmt.Errorf("yaml line %d: unknown key %q", lineno, key)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, cc := range clinics {
+		if cc.ID == "" {
+			cc.ID = dmsparse.ClinicID(cc)
+		}
+	}
+	return clinics, nil
+}
+
+// yamlStripComment cuts the comment off the line: # at the line's start or after a space,
+// which isn't within a quoted scalar. Quotes within plain scalars, e.g. д'Арк, don't start one.
+func yamlStripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t[{,:", line[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// yamlObject reads the enrichment field of the clinic from a JSON object, e.g. {"number": "ЛО-77-01"}.
+func yamlObject(cc *dmsparse.Clinic, key, val string) error {
+	if val == "" || val == "~" || val == "null" {
+		return nil
+	}
+	var v interface{}
+	switch key {
+	case "license":
+		v = &cc.License
+	case "place":
+		v = &cc.Place
+	case "firm":
+		v = &cc.Firm
+	case "travel":
+		v = &cc.Travel
+	}
+	return json.Unmarshal([]byte(val), v)
+}
+
+func yamlKeyValue(line string) (key, val string, err error) {
+	line = strings.TrimSpace(line)
+	n := strings.Index(line, ":")
+	if n < 0 {
+		return "", "", fmt.Errorf("missing ':' in %q", line)
+	}
+	return line[:n], strings.TrimSpace(line[n+1:]), nil
+}
+
+func yamlQuote(s string) string {
+	return strconv.Quote(s)
+}
+
+func yamlUnquote(s string) (string, error) {
+	if s == "" || s == "~" || s == "null" {
+		return "", nil
+	}
+	switch s[0] {
+	case '"':
+		return strconv.Unquote(s)
+	case '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return "", fmt.Errorf("bad single-quoted string %s", s)
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	return s, nil
+}
+
+func yamlFloats(s string) ([]float64, error) {
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("bad points %s", s)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	if s == "" {
+		return nil, nil
+	}
+	var ff []float64
+	for _, v := range strings.Split(s, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, err
+		}
+		ff = append(ff, f)
+	}
+	return ff, nil
+}
+
+// yamlStrings parses flow sequence of strings, that are either double-quoted, or plain without commas.
+func yamlStrings(s string) ([]string, error) {
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("bad sequence %s", s)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	var ss []string
+	for s != "" {
+		var v string
+		if s[0] == '"' {
+			q, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, err
+			}
+			v, _ = strconv.Unquote(q)
+			s = strings.TrimSpace(s[len(q):])
+		} else {
+			n := strings.IndexByte(s, ',')
+			if n < 0 {
+				n = len(s)
+			}
+			v, s = strings.TrimSpace(s[:n]), s[n:]
+		}
+		ss = append(ss, v)
+		if s == "" {
+			break
+		}
+		if s[0] != ',' {
+			return nil, fmt.Errorf("expected comma in sequence at %q", s)
+		}
+		s = strings.TrimSpace(s[1:])
+	}
+	return ss, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
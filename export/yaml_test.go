@@ -0,0 +1,99 @@
+package export
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+func TestYAMLRoundTrip(t *testing.T) {
+	clinics := []*dmsparse.Clinic{
+		{
+			ID:         "a1",
+			Name:       `ООО "Клиника: здоровье" \ филиал`,
+			RawAddress: "г. Москва, ул. Новая, д. 1,\nстр. 2",
+			Phone:      "+7 (495) 000-00-00, +7 (495) 111-11-11",
+			Address:    "Россия, Москва, Новая улица, 1с2",
+			City:       "Москва",
+			District:   "Центральный административный округ",
+			Programs:   []string{"Стандарт", "Премиум, с выездом", `"VIP"`},
+			Precision:  "exact",
+			Confidence: 0.75,
+			Points:     []float64{55.755814, 37.617635},
+			Categories: []string{"dental", "pediatric"},
+			License:    &dmsparse.License{Number: "ЛО-77-01-000001", Status: "действующая", Active: true, INN: "7700000000"},
+			Place:      &dmsparse.Place{ID: "1", Rating: 4.5, Reviews: 10, Hours: "пн-пт 8:00–20:00 # без перерыва", URL: "https://yandex.ru/maps/org/1"},
+			Firm:       &dmsparse.Firm{ID: "2", Entrance: &dmsparse.Point{Lat: 55.7557, Lon: 37.6175}, Floor: "3 этаж"},
+			Travel:     &dmsparse.Travel{Metro: "Охотный Ряд", WalkMinutes: 5},
+		},
+		{
+			ID:         "b2",
+			Name:       "# не комментарий",
+			RawAddress: "- не элемент",
+			Phone:      "",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteYAML(&buf, clinics); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadYAML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, clinics) {
+		t.Errorf("ReadYAML(WriteYAML()):\n got %+v\nwant %+v", got, clinics)
+	}
+}
+
+func TestReadYAMLHandEdited(t *testing.T) {
+	const doc = `---
+# fixed by hand
+- name: 'Клиника ''Доктор''' # renamed
+  raw_address: г. Казань, ул. Баумана, 1#2
+  phone: ~
+  programs: [Стандарт, "Премиум, с выездом"]  # the second one since 2018
+  # points: [55.8, 49.1]
+  points: [55.79, 49.1]
+  travel: {"metro": "Кремлёвская", "walk_minutes": 7}
+`
+	got, err := ReadYAML(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &dmsparse.Clinic{
+		Name:       "Клиника 'Доктор'",
+		RawAddress: "г. Казань, ул. Баумана, 1#2",
+		Programs:   []string{"Стандарт", "Премиум, с выездом"},
+		Points:     []float64{55.79, 49.1},
+		Travel:     &dmsparse.Travel{Metro: "Кремлёвская", WalkMinutes: 7},
+	}
+	// a clinic without ID gets the stable one
+	want.ID = dmsparse.ClinicID(want)
+	if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("ReadYAML() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadYAMLErrors(t *testing.T) {
+	tests := []struct {
+		doc, err string
+	}{
+		{"  name: \"Клиника\"\n", "yaml line 1: unexpected"},
+		{"- name: \"Клиника\"\n  color: red\n", `yaml line 2: unknown key "color"`},
+		{"- name \"Клиника\"\n", "yaml line 1: missing ':'"},
+		{"- points: [55.7, x]\n", "yaml line 1:"},
+		{"- confidence: high\n", `yaml line 1: invalid confidence "high"`},
+		{"- name: \"Клиника\"\n  license: {number: 1}\n", "yaml line 2: invalid license"},
+	}
+	for _, tt := range tests {
+		_, err := ReadYAML(strings.NewReader(tt.doc))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ReadYAML(%q) error = %v, want %q", tt.doc, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// filterKeys are the fields clinics can be filtered by.
+var filterKeys = []string{"id", "city", "precision", "geocoded", "category", "program"}
+
+// clinicFilter selects clinics by their fields. A clinic matches if it matches every key;
+// a key matches if the field equals any of the key's values.
+type clinicFilter map[string][]string
+
+// parseFilter parses filter in "key=value,key=value" form, e.g. "city=Москва|Химки,geocoded=true",
+// where alternative values of a key are separated by "|".
+func parseFilter(s string) (clinicFilter, error) {
+	f := make(clinicFilter)
+	if strings.TrimSpace(s) == "" {
+		return f, nil
+	}
+	for _, cond := range strings.Split(s, ",") {
+		key, val, ok := strings.Cut(cond, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid filter %q: expected key=value", cond)
+		}
+		if err := f.Add(strings.TrimSpace(key), strings.Split(val, "|")...); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Add adds the values of the key to the filter.
+func (f clinicFilter) Add(key string, vals ...string) error {
+	known := false
+	for _, k := range filterKeys {
+		known = known || k == key
+	}
+	if !known {
+		return fmt.Errorf("unknown filter key %q, expected one of %s", key, strings.Join(filterKeys, ", "))
+	}
+	for _, v := range vals {
+		v = strings.TrimSpace(v)
+		if key == "geocoded" {
+			if _, err := strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("invalid filter value %s=%q: expected true or false", key, v)
+			}
+		}
+		f[key] = append(f[key], v)
+	}
+	return nil
+}
+
+func (f clinicFilter) Match(cc *dmsparse.Clinic) bool {
+	for key, vals := range f {
+		var field string
+		switch key {
+		case "id":
+			field = cc.ID
+		case "city":
+			field = cc.City
+		case "precision":
+			field = cc.Precision
+		case "geocoded":
+			_, _, ok := cc.LatLon()
+			field = strconv.FormatBool(ok)
+		case "category", "program":
+			// a clinic may be in several categories and programs
+			list := cc.Categories
+			if key == "program" {
+				list = cc.Programs
+			}
+			in := func(v string) bool {
+				return slices.ContainsFunc(list, func(c string) bool { return strings.EqualFold(c, v) })
+			}
+			if !slices.ContainsFunc(vals, in) {
+				return false
+			}
+			continue
+		}
+		matched := false
+		for _, v := range vals {
+			if key == "geocoded" {
+				b, _ := strconv.ParseBool(v)
+				v = strconv.FormatBool(b)
+			}
+			matched = matched || strings.EqualFold(field, v)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns clinics, that match the filter.
+func (f clinicFilter) Apply(clinics []*dmsparse.Clinic) []*dmsparse.Clinic {
+	if len(f) == 0 {
+		return clinics
+	}
+	filtered := []*dmsparse.Clinic{}
+	for _, cc := range clinics {
+		if f.Match(cc) {
+			filtered = append(filtered, cc)
+		}
+	}
+	return filtered
+}
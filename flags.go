@@ -0,0 +1,676 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/enrich"
+	"github.com/narqo/vtb-dms/export"
+	"github.com/narqo/vtb-dms/geocode"
+	"github.com/narqo/vtb-dms/spatial"
+	"github.com/narqo/vtb-dms/validate"
+)
+
+// stringsFlag is a flag.Value, that collects values of a repeated flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// exportFlags are the flags of commands, that write the dataset.
+type exportFlags struct {
+	outFiles   stringsFlag
+	outFormats stringsFlag
+	scrub      stringsFlag
+	checksum   *bool
+	signKey    *string
+	cacheCtl   *string
+
+	coordOrder *string
+	pretty     *bool
+	envelope   *bool
+	compress   *string
+	prevFile   *string
+	filter     *string
+	within     *string
+	bbox       *string
+	splitBy    *string
+	collate    *string
+	sqliteBin  *string
+	psqlBin    *string
+	pgConn     *string
+	pgTable    *string
+	esAPIKey   *string
+	fsCreds    *string
+	fsProject  *string
+	fsColl     *string
+
+	jsVar         *string
+	jsonpCallback *string
+	templateFile  *string
+	mobileDropRaw *bool
+	goPackage     *string
+	goVar         *string
+	execCommand   *string
+	qrLink        *string
+}
+
+func newExportFlags(fs *flag.FlagSet) *exportFlags {
+	f := &exportFlags{}
+	fs.Var(&f.outFiles, "out", "path to output file; may be repeated together with -format")
+	fs.Var(&f.outFormats, "format", "output format (default json, ndjson for stdout, so that commands compose in pipelines); may be repeated together with -out")
+	f.coordOrder = fs.String("coord-order", "latlon", "order of points in json output: latlon or lonlat (geojson always uses lonlat)")
+	f.pretty = fs.Bool("pretty", false, "indent json output")
+	f.envelope = fs.Bool("envelope", false, "wrap json output into an envelope with dataset metadata")
+	f.compress = fs.String("compress", "", "compress output: gzip or none (default gzip if -out ends with .gz)")
+	f.prevFile = fs.String("prev", "", "path to previous dataset, used by delta output format and -events")
+	f.filter = fs.String("filter", "", `write only clinics matching the filter, e.g. "city=Москва|Химки,geocoded=true" (keys: `+strings.Join(filterKeys, ", ")+`)`)
+	f.within = fs.String("within", "", `write only clinics within the radius of the point, as "lat,lon,radius_m"`)
+	f.bbox = fs.String("bbox", "", `write only clinics within the bounding box, as "minLat,minLon,maxLat,maxLon"`)
+	fs.Var(&f.scrub, "scrub", `strip the field from outputs, or mask it as "field:mask", e.g. "phone:mask" for a public dataset; may be repeated (fields: `+strings.Join(export.ScrubFields, ", ")+`)`)
+	f.checksum = fs.Bool("checksum", false, "write SHA-256 checksum of each output file into <out>.sha256, checked by verify command")
+	f.signKey = fs.String("sign-key", "", "path to PEM Ed25519 private key to sign checksums of outputs with into <out>.sig; implies -checksum")
+	f.cacheCtl = fs.String("cache-control", "public, max-age=300", "Cache-Control header of outputs, uploaded to S3-compatible storage with -out s3://bucket/key; the storage is configured with AWS_* environment variables")
+	f.collate = fs.String("collate", "", "sort output by city, then name, with collation rules of the language, as humans expect, rather than by name in byte order (supported: "+strings.Join(collations, ", ")+")")
+	f.splitBy = fs.String("split-by", "", "split output into one file per group, written into -out directory (supported: city)")
+	f.sqliteBin = fs.String("sqlite3", "sqlite3", "path to sqlite3 binary, used by sqlite output format")
+	f.psqlBin = fs.String("psql", "psql", "path to psql binary, used by postgres output format")
+	f.pgConn = fs.String("pg-conn", os.Getenv("DATABASE_URL"), "PostgreSQL connection string, used by postgres output format (default $DATABASE_URL)")
+	f.pgTable = fs.String("pg-table", "clinics", "PostgreSQL table name, used by postgres output format")
+	f.fsCreds = fs.String("firestore-credentials", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"), "path to Google Cloud service account key file, used by firestore output format (default $GOOGLE_APPLICATION_CREDENTIALS)")
+	f.fsProject = fs.String("firestore-project", "", "Google Cloud project ID, used by firestore output format (default project of -firestore-credentials)")
+	f.fsColl = fs.String("firestore-collection", "clinics", "Firestore collection name, used by firestore output format")
+	f.esAPIKey = fs.String("es-api-key", os.Getenv("ELASTICSEARCH_API_KEY"), "Elasticsearch API key, used by elasticsearch output format, whose -out is the index URL, e.g. http://localhost:9200/clinics (default $ELASTICSEARCH_API_KEY)")
+
+	f.jsVar = fs.String("js-var", "data", "name of the variable, used by js output format (e.g. window.CLINICS)")
+	f.jsonpCallback = fs.String("jsonp", "", "wrap js output format into JSONP callback call with the given name")
+	f.templateFile = fs.String("template", "", "path to Go text/template file, used by template output format")
+	f.mobileDropRaw = fs.Bool("mobile-drop-raw-address", false, "omit raw address of geocoded clinics, used by mobile output format")
+	f.goPackage = fs.String("go-package", "clinics", "package name, used by go output format")
+	f.goVar = fs.String("go-var", "Clinics", "variable name, used by go output format")
+	f.execCommand = fs.String("exec-exporter", "", "external exporter command with space-separated arguments, used by exec output format; it reads json envelope from stdin and writes the output to stdout")
+	f.qrLink = fs.String("qr-link", "geo", "link, encoded into QR code per clinic by qr output format, whose -out is the directory of images: "+strings.Join(export.QRLinks, " or "))
+	return f
+}
+
+// options returns export options configured with flags.
+func (f *exportFlags) options() (*export.Options, error) {
+	opts := &export.Options{
+		Pretty:               *f.pretty,
+		Envelope:             *f.envelope,
+		CoordOrder:           *f.coordOrder,
+		Compress:             *f.compress,
+		Generator:            generator(),
+		JSVar:                *f.jsVar,
+		JSONPCallback:        *f.jsonpCallback,
+		Template:             *f.templateFile,
+		GoPackage:            *f.goPackage,
+		GoVar:                *f.goVar,
+		MobileDropRawAddress: *f.mobileDropRaw,
+		SQLiteBin:            *f.sqliteBin,
+		PsqlBin:              *f.psqlBin,
+		ESAPIKey:             *f.esAPIKey,
+		CacheControl:         *f.cacheCtl,
+		ExecCommand:          strings.Fields(*f.execCommand),
+		Checksum:             *f.checksum || *f.signKey != "",
+	}
+	if *f.signKey != "" {
+		var err error
+		opts.SignKey, err = export.ReadPrivateKey(*f.signKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if *f.prevFile != "" {
+		var err error
+		opts.Prev, err = export.ReadDataset(*f.prevFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+type outputTarget struct {
+	Path   string
+	Format string
+}
+
+// targets pairs repeated -out and -format flags. A single format applies to all outputs.
+func (f *exportFlags) targets() ([]outputTarget, error) {
+	files, formats := []string(f.outFiles), []string(f.outFormats)
+	if len(files) == 0 {
+		files = []string{""}
+	}
+	switch len(formats) {
+	case 0:
+		for _, file := range files {
+			formats = append(formats, defaultFormat(file))
+		}
+	case 1:
+		for len(formats) < len(files) {
+			formats = append(formats, formats[0])
+		}
+	}
+	if len(formats) != len(files) {
+		return nil, fmt.Errorf("got %d -out and %d -format flags, expected them to pair", len(files), len(formats))
+	}
+
+	targets := make([]outputTarget, len(files))
+	for i := range files {
+		targets[i] = outputTarget{Path: files[i], Format: formats[i]}
+	}
+	return targets, nil
+}
+
+// defaultFormat returns the output format of the file, if -format isn't set: ndjson for stdout,
+// which the dataset commands read from stdin by default, json otherwise.
+func defaultFormat(path string) string {
+	if path == "" || path == "-" {
+		return "ndjson"
+	}
+	return "json"
+}
+
+// write writes clinics, that match the filter, to the outputs configured with flags, with fields
+// scrubbed according to -scrub flags.
+func (f *exportFlags) write(clinics []*dmsparse.Clinic, opts *export.Options) error {
+	targets, err := f.targets()
+	if err != nil {
+		return err
+	}
+	filter, err := parseFilter(*f.filter)
+	if err != nil {
+		return err
+	}
+	rules, err := f.scrubRules()
+	if err != nil {
+		return err
+	}
+	clinics = filter.Apply(clinics)
+	if clinics, err = f.spatialFilter(clinics); err != nil {
+		return err
+	}
+	if *f.collate != "" {
+		if !slices.Contains(collations, *f.collate) {
+			return fmt.Errorf("unknown collation %q, expected one of %s", *f.collate, strings.Join(collations, ", "))
+		}
+		clinics = slices.Clone(clinics)
+		sortClinicsCollated(clinics)
+	}
+	clinics = export.Scrub(clinics, rules)
+	for _, t := range targets {
+		if err := f.writeTarget(t, clinics, opts); err != nil {
+			return fmt.Errorf("could not write %s output to %q: %v", t.Format, redactURL(t.Path), err)
+		}
+	}
+	return nil
+}
+
+// scrubRules returns the rules of -scrub flags.
+func (f *exportFlags) scrubRules() ([]export.ScrubRule, error) {
+	var rules []export.ScrubRule
+	for _, s := range f.scrub {
+		r, err := export.ParseScrubRule(s)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// spatialFilter returns clinics within the area, given by -within or -bbox flags.
+func (f *exportFlags) spatialFilter(clinics []*dmsparse.Clinic) ([]*dmsparse.Clinic, error) {
+	if *f.within == "" && *f.bbox == "" {
+		return clinics, nil
+	}
+
+	var (
+		lats, lons []float64
+		located    []*dmsparse.Clinic
+	)
+	for _, cc := range clinics {
+		if lat, lon, ok := cc.LatLon(); ok {
+			lats, lons = append(lats, lat), append(lons, lon)
+			located = append(located, cc)
+		}
+	}
+	ix := spatial.New(lats, lons)
+
+	var ids []int
+	if *f.bbox != "" {
+		bbox, err := parseBBox(*f.bbox)
+		if err != nil {
+			return nil, err
+		}
+		ids = ix.InBox(bbox[0], bbox[1], bbox[2], bbox[3])
+	} else {
+		var lat, lon, radius float64
+		if n, err := fmt.Sscanf(*f.within, "%g,%g,%g", &lat, &lon, &radius); err != nil || n != 3 {
+			return nil, fmt.Errorf("invalid -within %q, expected lat,lon,radius_m", *f.within)
+		}
+		for _, h := range ix.Within(lat, lon, radius) {
+			ids = append(ids, h.ID)
+		}
+		sort.Ints(ids)
+	}
+
+	found := make([]*dmsparse.Clinic, len(ids))
+	for i, id := range ids {
+		found[i] = located[id]
+	}
+	return found, nil
+}
+
+func (f *exportFlags) writeTarget(t outputTarget, clinics []*dmsparse.Clinic, opts *export.Options) error {
+	switch t.Format {
+	case "postgres":
+		return export.WritePostgres(*f.pgConn, *f.pgTable, clinics, opts)
+	case "elasticsearch":
+		return export.WriteElasticsearch(t.Path, clinics, opts)
+	case "firestore":
+		return export.WriteFirestore(*f.fsCreds, *f.fsProject, *f.fsColl, clinics)
+	case "qr":
+		return export.WriteQR(t.Path, *f.qrLink, clinics)
+	}
+	if *f.splitBy != "" {
+		return export.WriteSplit(t.Path, *f.splitBy, t.Format, clinics, opts)
+	}
+	return export.WriteFile(t.Path, t.Format, clinics, opts)
+}
+
+// redactURL hides the password in the output URL, e.g. of elasticsearch output.
+func redactURL(path string) string {
+	if !isURL(path) {
+		return path
+	}
+	if u, err := url.Parse(path); err == nil {
+		return u.Redacted()
+	}
+	return path
+}
+
+// geocodeFlags are the flags of commands, that geocode clinics.
+type geocodeFlags struct {
+	provider    *string
+	execCommand *string
+	apiKey      *string
+	concurrency *int
+	cache       *string
+	verifyExec  *string
+	countryBBox *string
+	httpTimeout *time.Duration
+	httpIdle    *int
+	http2       *bool
+}
+
+func newGeocodeFlags(fs *flag.FlagSet) *geocodeFlags {
+	return &geocodeFlags{
+		provider:    fs.String("provider", "yandex", "geocoder provider (supported: yandex, exec)"),
+		execCommand: fs.String("exec-geocoder", "", "external geocoder command with space-separated arguments, used by exec provider; it reads json request from stdin and writes json result to stdout"),
+		apiKey:      fs.String("api-key", "", "geocoder API key"),
+		concurrency: fs.Int("concurrency", 10, "maximum number of concurrent geocoder requests; lowered automatically, while the provider throttles requests or times out"),
+		cache:       fs.String("cache", "", "path to geocoder cache file"),
+		countryBBox: fs.String("country-bbox", "", "bounding box minLat,minLon,maxLat,maxLon, where all clinics must be, minLon may be greater than maxLon across the antimeridian (default Russia)"),
+		verifyExec:  fs.String("verify-exec-geocoder", "", "external geocoder command with space-separated arguments, whose results are compared with the provider's ones to score confidence of points"),
+		httpTimeout: fs.Duration("http-timeout", geocode.DefaultHTTPOptions.Timeout, "timeout of a single request to the geocoder API"),
+		httpIdle:    fs.Int("http-max-idle", 0, "number of keep-alive connections to the geocoder API (default -concurrency)"),
+		http2:       fs.Bool("http2", geocode.DefaultHTTPOptions.HTTP2, "allow HTTP/2 connections to the geocoder API"),
+	}
+}
+
+// geocoder is the provider, optionally wrapped with the cache.
+type geocoder struct {
+	geocode.Geocoder
+	provider interface {
+		geocode.Geocoder
+		Calls() int64
+		Ping(ctx context.Context) error
+	}
+	cache *geocode.Cache
+	// verify, if set, is the second provider, that confirms results of the first one.
+	verify geocode.Geocoder
+}
+
+func (f *geocodeFlags) geocoder() (*geocoder, error) {
+	if *f.concurrency < 1 {
+		return nil, fmt.Errorf("invalid concurrency: %d", *f.concurrency)
+	}
+	g := &geocoder{}
+	switch *f.provider {
+	case "yandex":
+		idle := *f.httpIdle
+		if idle <= 0 {
+			idle = *f.concurrency
+		}
+		g.provider = &geocode.Yandex{
+			APIKey: *f.apiKey,
+			Client: geocode.NewHTTPClient(geocode.HTTPOptions{
+				Timeout:             *f.httpTimeout,
+				MaxIdleConnsPerHost: idle,
+				HTTP2:               *f.http2,
+			}),
+		}
+	case "exec":
+		command := strings.Fields(*f.execCommand)
+		if len(command) == 0 {
+			return nil, fmt.Errorf("exec provider requires -exec-geocoder command")
+		}
+		g.provider = &geocode.Exec{
+			Command: command,
+		}
+	default:
+		return nil, fmt.Errorf("unknown geocoder provider: %q", *f.provider)
+	}
+	adaptive := geocode.NewAdaptive(instrumentedGeocoder{g.provider, *f.provider}, *f.concurrency)
+	g.Geocoder = adaptive
+	metrics.SetConcurrency(adaptive.Limit)
+	if *f.cache != "" {
+		cache, err := geocode.OpenCache(*f.cache, g.Geocoder)
+		if err != nil {
+			return nil, err
+		}
+		g.cache = cache
+		g.Geocoder = cache
+		metrics.SetCacheHits(cache.Hits)
+	}
+	if *f.countryBBox != "" {
+		bbox, err := parseCountryBBox(*f.countryBBox)
+		if err != nil {
+			return nil, err
+		}
+		validate.Country = bbox
+	}
+	if command := strings.Fields(*f.verifyExec); len(command) > 0 {
+		g.verify = instrumentedGeocoder{&geocode.Exec{Command: command}, "verify"}
+	}
+	return g, nil
+}
+
+func (g *geocoder) Geocode(address string) (*geocode.Result, error) {
+	if g.cache != nil {
+		metrics.CacheLookup()
+	}
+	return g.Geocoder.Geocode(address)
+}
+
+// Refresh geocodes address with the provider, bypassing the cache, and updates the cache with the result.
+func (g *geocoder) Refresh(address string) (*geocode.Result, error) {
+	if g.cache != nil {
+		return g.cache.Refresh(address)
+	}
+	return g.Geocoder.Geocode(address)
+}
+
+// parseCountryBBox parses bounding box in "minLat,minLon,maxLat,maxLon" form, where minLon may be
+// greater than maxLon, if the box crosses the antimeridian.
+func parseCountryBBox(s string) ([4]float64, error) {
+	var bbox [4]float64
+	if n, err := fmt.Sscanf(s, "%g,%g,%g,%g", &bbox[0], &bbox[1], &bbox[2], &bbox[3]); err != nil || n != 4 {
+		return bbox, fmt.Errorf("invalid -country-bbox %q, expected minLat,minLon,maxLat,maxLon", s)
+	}
+	if bbox[0] > bbox[2] {
+		return bbox, fmt.Errorf("invalid -country-bbox %q, minLat is greater than maxLat", s)
+	}
+	return bbox, nil
+}
+
+// Verify geocodes address with the second provider. It returns nil result, if there is no second provider.
+func (g *geocoder) Verify(address string) (*geocode.Result, error) {
+	if g.verify == nil {
+		return nil, nil
+	}
+	return g.verify.Geocode(address)
+}
+
+// Calls returns the number of requests made to the provider.
+func (g *geocoder) Calls() int64 {
+	return g.provider.Calls()
+}
+
+// CacheHits returns the number of addresses resolved from the cache.
+func (g *geocoder) CacheHits() int64 {
+	if g.cache == nil {
+		return 0
+	}
+	return g.cache.Hits()
+}
+
+// Ping checks the provider is reachable.
+func (g *geocoder) Ping(ctx context.Context) error {
+	return g.provider.Ping(ctx)
+}
+
+// PingCache checks the cache can be saved. It's nil, if there is no cache.
+func (g *geocoder) PingCache() error {
+	if g.cache == nil {
+		return nil
+	}
+	return g.cache.Ping()
+}
+
+// Close saves the cache.
+func (g *geocoder) Close() error {
+	if g.cache == nil {
+		return nil
+	}
+	return g.cache.Save()
+}
+
+// apiFlags are the flags of commands, that serve HTTP API.
+type apiFlags struct {
+	tokens      stringsFlag
+	corsOrigins stringsFlag
+	corsMethods *string
+	corsMaxAge  *time.Duration
+	debugAddr   *string
+	statsEvery  *time.Duration
+}
+
+func newAPIFlags(fs *flag.FlagSet) *apiFlags {
+	f := &apiFlags{
+		corsMethods: fs.String("cors-methods", "GET,POST", "comma-separated methods, allowed in cross-origin requests"),
+		corsMaxAge:  fs.Duration("cors-max-age", 10*time.Minute, "how long browsers may cache CORS preflight responses"),
+		debugAddr:   fs.String("debug-addr", "", `address to serve pprof endpoints on, apart from the API, e.g. "localhost:6060"`),
+		statsEvery:  fs.Duration("runtime-stats", 0, "how often to log memory, GC and goroutine stats; 0 disables"),
+	}
+	fs.Var(&f.tokens, "token", "API token as secret:scope, where scope is read or write; repeat for several tokens. Without tokens the API is open to anyone")
+	fs.Var(&f.corsOrigins, "cors-origin", `origin, allowed to call the API from a browser, e.g. "https://map.example.com", or "*" for any; repeat for several origins`)
+	return f
+}
+
+// handler wraps API handler h with authentication and CORS.
+func (f *apiFlags) handler(h http.Handler) (http.Handler, error) {
+	tokens, err := parseTokens(f.tokens)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		slog.Warn("serving without -token, the API, including changing endpoints, is open to anyone")
+	}
+	h = requireTokens(h, tokens)
+
+	if len(f.corsOrigins) > 0 {
+		cors := &corsPolicy{
+			origins: f.corsOrigins,
+			methods: splitList(*f.corsMethods),
+			maxAge:  *f.corsMaxAge,
+		}
+		h = cors.Handler(h)
+	}
+	return h, nil
+}
+
+// startDiagnostics starts pprof server and runtime stats logging, if they are enabled.
+func (f *apiFlags) startDiagnostics() {
+	if *f.debugAddr != "" {
+		serveDebug(*f.debugAddr)
+	}
+	if *f.statsEvery > 0 {
+		logRuntimeStats(*f.statsEvery)
+	}
+}
+
+// enrichFlags are the flags of commands, that enrich clinics with data of external sources.
+type enrichFlags struct {
+	licenses     *string
+	placesAPIKey *string
+	dgisAPIKey   *string
+	metro        *string
+	driveFrom    *string
+	walkRouter   *string
+	driveRouter  *string
+	categories   *string
+
+	staticMaps     *string
+	staticProvider *string
+	staticAPIKey   *string
+	staticMapSize  *string
+	staticMapZoom  *int
+}
+
+func newEnrichFlags(fs *flag.FlagSet) *enrichFlags {
+	return &enrichFlags{
+		licenses:     fs.String("licenses", "", "path or URL of Rosdravnadzor license registry as CSV, to attach clinics' license number and status"),
+		placesAPIKey: fs.String("places-api-key", "", "Yandex Places API key, to attach rating, number of reviews and opening hours of geocoded clinics from Yandex Maps"),
+		dgisAPIKey:   fs.String("2gis-api-key", "", "2GIS Catalog API key, to attach the entrance, the floor and the photos link of geocoded clinics from 2GIS"),
+		metro:        fs.String("metro-stations", "", "path or URL of metro stations in HeadHunter metro API format, e.g. https://api.hh.ru/metro/1, to attach the walking time from the nearest station"),
+		driveFrom:    fs.String("drive-from", "", `reference point as "lat,lon", e.g. the office, to attach the driving time from`),
+		walkRouter:   fs.String("walk-router", enrich.DefaultWalkRouter, "URL of OSRM instance with foot profile, used by -metro-stations"),
+		driveRouter:  fs.String("drive-router", enrich.DefaultDriveRouter, "URL of OSRM instance with car profile, used by -drive-from"),
+		categories:   fs.String("categories", "", `path to rules of clinic categories by keywords in names, one per line as "category: keyword, keyword", that extend the default ones (dental, pediatric, lab, hospital, polyclinic)`),
+
+		staticMaps:     fs.String("static-maps", "", "path to the directory to store a static map image of each geocoded clinic into, as <id>.png, e.g. for emails and printed materials"),
+		staticProvider: fs.String("static-map-provider", "osm", "static map API, used by -static-maps: "+strings.Join(enrich.StaticMapProviders, " or ")),
+		staticAPIKey:   fs.String("static-map-api-key", "", "Yandex Static API key, required by -static-map-provider yandex"),
+		staticMapSize:  fs.String("static-map-size", "600x400", `size of static map images as "widthxheight" in pixels`),
+		staticMapZoom:  fs.Int("static-map-zoom", 16, "zoom level of static map images"),
+	}
+}
+
+// enrichSource is an enabled source of enrichment.
+type enrichSource struct {
+	name string
+	enrich.Enricher
+}
+
+// sources returns the enabled sources, loading the registries.
+func (f *enrichFlags) sources() ([]enrichSource, error) {
+	var sources []enrichSource
+	if *f.licenses != "" {
+		r, _, err := openInput(*f.licenses)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		licenses, err := enrich.ReadLicenses(r)
+		if err != nil {
+			return nil, fmt.Errorf("license registry %s: %v", *f.licenses, err)
+		}
+		slog.Info("loaded license registry", "licenses", licenses.Len())
+		sources = append(sources, enrichSource{"licenses", licenses})
+	}
+	if *f.placesAPIKey != "" {
+		sources = append(sources, enrichSource{"yandex-places", &enrich.YandexPlaces{APIKey: *f.placesAPIKey}})
+	}
+	if *f.dgisAPIKey != "" {
+		sources = append(sources, enrichSource{"2gis", &enrich.DGIS{APIKey: *f.dgisAPIKey}})
+	}
+	if *f.metro != "" || *f.driveFrom != "" {
+		travel := &enrich.Travel{WalkRouter: *f.walkRouter, DriveRouter: *f.driveRouter}
+		if *f.metro != "" {
+			r, _, err := openInput(*f.metro)
+			if err != nil {
+				return nil, err
+			}
+			defer r.Close()
+			if travel.Stations, err = enrich.ReadStations(r); err != nil {
+				return nil, fmt.Errorf("metro stations %s: %v", *f.metro, err)
+			}
+			slog.Info("loaded metro stations", "stations", len(travel.Stations))
+		}
+		if *f.driveFrom != "" {
+			var p dmsparse.Point
+			if n, err := fmt.Sscanf(*f.driveFrom, "%g,%g", &p.Lat, &p.Lon); err != nil || n != 2 {
+				return nil, fmt.Errorf("invalid -drive-from %q, expected lat,lon", *f.driveFrom)
+			}
+			travel.DriveFrom = &p
+		}
+		sources = append(sources, enrichSource{"travel", travel})
+	}
+	if *f.staticMaps != "" {
+		m := &enrich.StaticMap{Provider: *f.staticProvider, APIKey: *f.staticAPIKey, Dir: *f.staticMaps, Zoom: *f.staticMapZoom}
+		if n, err := fmt.Sscanf(*f.staticMapSize, "%dx%d", &m.Width, &m.Height); err != nil || n != 2 || m.Width <= 0 || m.Height <= 0 {
+			return nil, fmt.Errorf("invalid -static-map-size %q, expected widthxheight", *f.staticMapSize)
+		}
+		if !slices.Contains(enrich.StaticMapProviders, m.Provider) {
+			return nil, fmt.Errorf("unknown -static-map-provider %q", m.Provider)
+		}
+		if m.Provider == "yandex" && m.APIKey == "" {
+			return nil, fmt.Errorf("-static-map-provider yandex requires -static-map-api-key")
+		}
+		if err := os.MkdirAll(m.Dir, 0755); err != nil {
+			return nil, err
+		}
+		sources = append(sources, enrichSource{"static-maps", m})
+	}
+	return sources, nil
+}
+
+// classifier returns the categories of clinics with the rules of -categories added.
+func (f *enrichFlags) classifier() (*enrich.Categories, error) {
+	c := enrich.NewCategories()
+	if *f.categories == "" {
+		return c, nil
+	}
+	r, _, err := openInput(*f.categories)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if err := c.ReadRules(r); err != nil {
+		return nil, fmt.Errorf("category rules %s: %v", *f.categories, err)
+	}
+	return c, nil
+}
+
+// classifyClinics tags clinics with categories.
+func classifyClinics(categories *enrich.Categories, clinics []*dmsparse.Clinic) {
+	counts := make(map[string]int)
+	for _, cc := range clinics {
+		categories.Enrich(cc)
+		for _, c := range cc.Categories {
+			counts[c]++
+		}
+	}
+	args := []interface{}{"clinics", len(clinics)}
+	for _, name := range categories.Names() {
+		args = append(args, name, counts[name])
+	}
+	slog.Info("classified clinics", args...)
+}
+
+// enrichClinics enriches clinics with each of the sources in turn.
+func enrichClinics(sources []enrichSource, clinics []*dmsparse.Clinic, concurrency int) {
+	for _, src := range sources {
+		res := enrich.Run(src, clinics, concurrency)
+		slog.Info("enriched clinics", "source", src.name, "matched", res.Matched, "failed", res.Failed, "clinics", len(clinics))
+	}
+}
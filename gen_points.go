@@ -1,232 +1,193 @@
+// Without go.mod the GOPATH build defaults to Go 1.20 behaviour, so opt in to method
+// and wildcard patterns of http.ServeMux, used by serve command, explicitly.
+//go:debug httpmuxgo121=0
+
 package main
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
-	"net/url"
+	"log/slog"
 	"os"
-	"strconv"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
-	"unicode"
-)
+	"time"
 
-var geocoderAPI *url.URL
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/export"
+	"github.com/narqo/vtb-dms/geocode"
+	"github.com/narqo/vtb-dms/validate"
+)
 
-func init() {
-	geocoderAPI, _ = url.Parse("https://geocode-maps.yandex.ru/1.x/")
+var commands = []struct {
+	name, usage string
+	run         func(args []string) error
+}{
+	{"run", "parse, geocode and export in one pass (default)", runPipeline},
+	{"parse", "parse DMS text document into dataset", runParse},
+	{"normalize", "clean up clinics of dataset", runNormalize},
+	{"geocode", "geocode clinics of dataset", runGeocode},
+	{"prewarm", "geocode addresses into cache at a low rate", runPrewarm},
+	{"export", "convert dataset into output formats", runExport},
+	{"diff", "compare two dataset versions", runDiff},
+	{"validate", "check dataset against validation rules", runValidate},
+	{"duplicates", "suggest clinics to merge by similar addresses", runDuplicates},
+	{"districts", "report clinics and coverage gaps per city district", runDistricts},
+	{"route", "order clinics of an inspection trip into a visiting route", runRoute},
+	{"search", "search clinics of dataset by name and address", runSearch},
+	{"site", "render static site with a page per clinic", runSite},
+	{"verify", "check checksums and signatures of output files", runVerify},
+	{"schema", "print JSON Schema of json output", runSchema},
+	{"serve", "serve dataset over HTTP REST API", runServe},
+	{"serve-grpc", "serve gRPC ClinicService", runServeGRPC},
+	{"daemon", "serve dataset and rebuild it on schedule", runDaemon},
 }
 
-var (
-	dataFile  = flag.String("in", "", "path to input file")
-	outFile   = flag.String("out", "", "path to output file")
-	outFormat = flag.String("format", "json", "output format")
-	debug     = flag.Bool("debug", false, "debug")
-)
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun \"%s <command> -h\" for command's flags\n", os.Args[0])
+}
 
-type Clinic struct {
-	Name       string    `json:"name"`
-	RawAddress string    `json:"raw_address"`
-	Phone      string    `json:"phone"`
-	Address    string    `json:"address,omitempty"`
-	Points     []float64 `json:"points"`
+func main() {
+	os.Exit(run(os.Args[1:]))
 }
 
-var clinics []*Clinic
+// run runs the command and returns the exit code.
+func run(args []string) int {
+	// keep the flat "gen_points -in ... -out ..." invocation working
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return exit(runPipeline(args))
+	}
 
-func main() {
-	flag.Parse()
+	name, args := args[0], args[1:]
+	if name == "help" {
+		usage()
+		return 0
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return exit(cmd.run(args))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	return exitUsage
+}
 
-	f, err := os.Open(*dataFile)
-	if err != nil {
-		panic(err)
+// exit reports the error of a command and returns its exit code.
+func exit(err error) int {
+	if err == nil {
+		return 0
 	}
-	defer f.Close()
+	fmt.Fprintf(os.Stderr, "%s: %v\n", filepath.Base(os.Args[0]), err)
+	return exitCode(err)
+}
 
-	var p parser
-	p.Parse(f)
+// newFlagSet creates command's flag set. The flag set must be parsed with parseFlags.
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s %s [flags] %s\n", os.Args[0], name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
 
+// geocodeClinics geocodes clinics, which don't have points yet, e.g. weren't loaded from curated yaml.
+// Each clinic is written to stream as soon as it's processed, and is reported to progress.
+func geocodeClinics(g *geocoder, provider string, concurrency int, clinics []*dmsparse.Clinic, stream *export.StreamWriter, progress *progress) {
 	var (
-		limiter = make(chan struct{}, 10)
+		limiter = make(chan struct{}, concurrency)
 		wg      sync.WaitGroup
 	)
 
 	for _, cc := range clinics {
+		if _, _, ok := cc.LatLon(); ok {
+			// already geocoded
+			metrics.ClinicProcessed("skipped")
+			stream.Write(cc)
+			continue
+		}
 		wg.Add(1)
 		limiter <- struct{}{}
-		go func(cc *Clinic) {
-			if err := doGeocodeClinic(cc); err != nil {
-				fmt.Fprintf(os.Stderr, "could not geocode clinic %q - %q: %v\n", cc.Name, cc.RawAddress, err)
-			}
+		go func(cc *dmsparse.Clinic) {
+			err := geocodeClinic(g, provider, cc)
+			stream.Write(cc)
+			progress.Add(err == nil)
 			<-limiter
 			wg.Done()
 		}(cc)
 	}
 
 	wg.Wait()
-
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(clinics); err != nil {
-		panic(err)
-	}
-
-	out := os.Stdout
-	if *outFile != "" && *outFile != "-" {
-		out, err = os.Create(*outFile)
-		if err != nil {
-			panic(err)
-		}
-		defer out.Close()
-	}
-	switch *outFormat {
-	case "js":
-		fmt.Fprintf(out, "data = %s", buf.String())
-	case "json":
-		io.Copy(out, &buf)
-	default:
-		fmt.Fprintf(os.Stderr, "unknown output format: %q", *outFormat)
-	}
-}
-
-const (
-	_MODE_NONE = iota
-	_MODE_SECTION
-	_MODE_NAME
-	_MODE_ADDRESS
-	_MODE_PHONE
-)
-
-type parser struct {
-	nextMode int
+	progress.Finish()
 }
 
-func (p *parser) Parse(f io.Reader) {
-	var cc Clinic
-	r := bufio.NewScanner(f)
-	for r.Scan() {
-		line := r.Text()
-		line = strings.TrimSpace(line)
-
-		if line == "" {
-			c := cc
-			cc = Clinic{}
-			clinics = append(clinics, &c)
-			p.nextMode = _MODE_NAME
-			continue
-		} else if isSection(line) {
-			// section
-			p.nextMode = _MODE_NAME
-			continue
-		}
-
-		switch p.nextMode {
-		case _MODE_NAME:
-			cc.Name = line
-			p.nextMode = _MODE_ADDRESS
-		case _MODE_ADDRESS:
-			cc.RawAddress = line
-			p.nextMode = _MODE_PHONE
-		case _MODE_PHONE:
-			cc.Phone = line
-		}
-	}
-}
-
-func isSection(line string) bool {
-	if len(line) < 3 {
-		return false
-	}
-	a, b, c := line[0], line[1], line[2]
-	if unicode.IsNumber(rune(a)) {
-		if b == '.' || (unicode.IsNumber(rune(b)) && c == '.') {
-			return true
-		}
-	}
-	return false
-}
-
-func doGeocodeClinic(cc *Clinic) error {
-	if cc.RawAddress == "" {
-		return fmt.Errorf("no raw address in clinic: %+v", cc)
-	}
-	vals := make(url.Values)
-	vals.Set("geocode", cc.RawAddress)
-	vals.Set("lang", "ru_RU")
-	vals.Set("kind", "house")
-	vals.Set("format", "json")
-	if *debug {
-		println("geocoding", cc.RawAddress)
+// geocodeClinic geocodes a clinic, which doesn't have points yet, drops outlying points, and verifies
+// the point with the second provider, if there is one.
+func geocodeClinic(g *geocoder, provider string, cc *dmsparse.Clinic) error {
+	start := time.Now()
+	err := geocode.GeocodeClinic(g, cc)
+	logger := slog.With("id", cc.ID, "address", cc.RawAddress, "provider", provider, "duration", time.Since(start))
+	if err == nil {
+		err = rejectOutlier(cc)
 	}
-
-	u := *geocoderAPI
-	u.RawQuery = vals.Encode()
-
-	resp, err := http.Get(u.String())
 	if err != nil {
+		logger.Warn("could not geocode clinic", "name", cc.Name, "err", err)
+		metrics.ClinicProcessed("failed")
 		return err
 	}
-	defer resp.Body.Close()
+	verifyClinic(g, cc, logger)
+	logger.Debug("geocoded clinic", "precision", cc.Precision, "confidence", cc.Confidence)
+	metrics.ClinicProcessed("geocoded")
+	return nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		r, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("bad response status: %s, %s", resp.Status, r)
+// rejectOutlier drops the point of the clinic, if it's outside the country or the region, claimed
+// in the address, so obviously wrong points are never published.
+func rejectOutlier(cc *dmsparse.Clinic) error {
+	msg := validate.CheckRegion(cc)
+	if msg == "" {
+		return nil
 	}
+	cc.Points, cc.Address, cc.City, cc.District, cc.Precision, cc.Confidence = nil, "", "", "", "", 0
+	return fmt.Errorf("rejected geocoder result: %s", msg)
+}
 
-	var geoResp geocodeResponse
-	err = json.NewDecoder(resp.Body).Decode(&geoResp)
+// verifyClinic geocodes clinic with the second provider, if there is one, and scores the confidence
+// of clinic's point by the agreement of the providers.
+func verifyClinic(g *geocoder, cc *dmsparse.Clinic, logger *slog.Logger) {
+	res, err := g.Verify(cc.RawAddress)
 	if err != nil {
-		return err
-	}
-
-	if len(geoResp.Response.GeoObjectCollection.FeatureMember) == 0 {
-		return fmt.Errorf("no geoobject in response: %+v", geoResp)
-	}
-	geoObj := geoResp.Response.GeoObjectCollection.FeatureMember[0].GeoObject
-
-	rawPoints := strings.SplitN(geoObj.Point.Pos, " ", 2)
-	if len(rawPoints) != 2 {
-		return fmt.Errorf("bad points in response: %s", geoObj.Point.Pos)
-	}
-	var lat, long float64
-	lat, err = strconv.ParseFloat(strings.TrimSpace(rawPoints[0]), 32)
-	if err == nil {
-		long, err = strconv.ParseFloat(strings.TrimSpace(rawPoints[1]), 32)
+		logger.Debug("could not verify clinic", "err", err)
+		return
 	}
-	if err != nil {
-		return err
+	if res != nil {
+		cc.Confidence = geocode.Confidence(cc, res)
 	}
-
-	cc.Points = []float64{long, lat}
-	cc.Address = geoObj.MetaDataProperty.GeocoderMetaData.Text
-
-	return nil
 }
 
-type geocodeResponse struct {
-	Response struct {
-		GeoObjectCollection struct {
-			FeatureMember []struct {
-				GeoObject geoObject `json:"GeoObject"`
-			} `json:"featureMember"`
+// countPending returns the number of clinics, which need geocoding.
+func countPending(clinics []*dmsparse.Clinic) (n int) {
+	for _, cc := range clinics {
+		if _, _, ok := cc.LatLon(); !ok {
+			n++
 		}
-	} `json:"response"`
+	}
+	return n
 }
 
-type geoObject struct {
-	Name             string `json:"name"`
-	Description      string `json:"description"`
-	MetaDataProperty struct {
-		GeocoderMetaData struct {
-			Text string `json:"text"`
-		} `json:"GeocoderMetaData"`
-	} `json:"metaDataProperty"`
-	Point struct {
-		Pos string `json:"pos"`
-	} `json:"Point"`
+// sortClinics sorts clinics by name and ID, so the output doesn't depend on the order clinics were processed in.
+func sortClinics(clinics []*dmsparse.Clinic) {
+	sort.SliceStable(clinics, func(i, j int) bool {
+		if clinics[i].Name != clinics[j].Name {
+			return clinics[i].Name < clinics[j].Name
+		}
+		return clinics[i].ID < clinics[j].ID
+	})
 }
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+func TestRejectOutlier(t *testing.T) {
+	cc := &dmsparse.Clinic{
+		RawAddress: "г. Москва, ул. Новая, д. 1",
+		Points:     []float64{55.03, 82.92}, // Novosibirsk
+		Address:    "Россия, Новосибирск, улица Новая, 1",
+		City:       "Новосибирск",
+		District:   "Центральный район",
+		Precision:  "exact",
+		Confidence: 0.9,
+	}
+	if err := rejectOutlier(cc); err == nil {
+		t.Fatal("rejectOutlier: want error for a point outside the claimed city")
+	}
+	if cc.Points != nil || cc.Address != "" || cc.City != "" || cc.District != "" || cc.Precision != "" || cc.Confidence != 0 {
+		t.Errorf("rejectOutlier left geocoded fields: %+v", cc)
+	}
+}
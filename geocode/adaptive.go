@@ -0,0 +1,121 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrThrottled is returned by providers, that reject requests because of the rate limit, e.g. with
+// HTTP 429 status.
+var ErrThrottled = errors.New("throttled by provider")
+
+// IsThrottled reports whether err means the provider is overloaded: it throttled the request or
+// timed out.
+func IsThrottled(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrThrottled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// Adaptive throttling parameters.
+const (
+	// throttledRetries is the number of times a throttled request is retried.
+	throttledRetries = 3
+	// throttledPause is the pause before retrying a throttled request, multiplied by the attempt.
+	// The limit isn't lowered again within the pause, as requests in flight are likely throttled too.
+	throttledPause = time.Second
+)
+
+// Adaptive is Geocoder, that limits the number of concurrent requests to another Geocoder and adapts
+// the limit to the provider, so the throughput stays near the provider's real limit: the limit is
+// halved, when the provider throttles a request, and grows by one after as many successful requests
+// in a row, as the limit is, up to Max. The limit only grows, while it's reached, so a limit, that
+// was never tried, isn't trusted. Throttled requests are retried after a pause.
+type Adaptive struct {
+	Geocoder Geocoder
+	// Max is the maximum number of concurrent requests.
+	Max int
+	// Logger logs changes of the limit. If nil, slog.Default is used.
+	Logger *slog.Logger
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	limit     int
+	active    int
+	successes int
+	lowered   time.Time
+}
+
+// NewAdaptive returns Adaptive, that starts with max concurrent requests to g.
+func NewAdaptive(g Geocoder, max int) *Adaptive {
+	a := &Adaptive{Geocoder: g, Max: max, limit: max}
+	a.cond = sync.NewCond(&a.mu)
+	return a
+}
+
+func (a *Adaptive) Geocode(address string) (*Result, error) {
+	for attempt := 1; ; attempt++ {
+		a.acquire()
+		res, err := a.Geocoder.Geocode(address)
+		a.release(err)
+		if !IsThrottled(err) || attempt > throttledRetries {
+			return res, err
+		}
+		time.Sleep(throttledPause * time.Duration(attempt))
+	}
+}
+
+// Limit returns the current limit of concurrent requests.
+func (a *Adaptive) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.limit
+}
+
+func (a *Adaptive) acquire() {
+	a.mu.Lock()
+	for a.active >= a.limit {
+		a.cond.Wait()
+	}
+	a.active++
+	a.mu.Unlock()
+}
+
+func (a *Adaptive) release(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	saturated := a.active >= a.limit
+	a.active--
+	defer a.cond.Broadcast()
+
+	logger := a.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	switch {
+	case IsThrottled(err):
+		a.successes = 0
+		if time.Since(a.lowered) < throttledPause || a.limit == 1 {
+			return
+		}
+		a.lowered = time.Now()
+		logger.Info("provider throttles requests, lowering concurrency", "from", a.limit, "to", max(1, a.limit/2), "err", err)
+		a.limit = max(1, a.limit/2)
+	case err == nil && saturated:
+		a.successes++
+		if a.successes >= a.limit && a.limit < a.Max {
+			a.successes = 0
+			a.limit++
+			logger.Debug("raising concurrency", "to", a.limit)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package geocode
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Cache is Geocoder, that memoizes results of another Geocoder by address. The results are
+// persisted to a json file, so they are reused between runs.
+type Cache struct {
+	Geocoder Geocoder
+
+	path    string
+	mu      sync.Mutex
+	entries map[string]*Result
+	hits    int64
+}
+
+// OpenCache loads the cache from file at path, if it exists. Missed addresses are resolved with g.
+func OpenCache(path string, g Geocoder) (*Cache, error) {
+	c := &Cache{
+		Geocoder: g,
+		path:     path,
+		entries:  make(map[string]*Result),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Cache) Geocode(address string) (*Result, error) {
+	key := CacheKey(address)
+
+	c.mu.Lock()
+	res, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		atomic.AddInt64(&c.hits, 1)
+		return res, nil
+	}
+
+	res, err := c.Geocoder.Geocode(address)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = res
+	c.mu.Unlock()
+
+	return res, nil
+}
+
+// Cached reports whether the result for address is in the cache.
+func (c *Cache) Cached(address string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[CacheKey(address)]
+	return ok
+}
+
+// Refresh geocodes address with the underlying Geocoder, bypassing the cache, and replaces
+// the cached result.
+func (c *Cache) Refresh(address string) (*Result, error) {
+	res, err := c.Geocoder.Geocode(address)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[CacheKey(address)] = res
+	c.mu.Unlock()
+
+	return res, nil
+}
+
+// Hits returns the number of addresses resolved from the cache.
+func (c *Cache) Hits() int64 {
+	return atomic.LoadInt64(&c.hits)
+}
+
+// Save writes the cache to its file.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	data, err := json.Marshal(c.entries)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Ping checks the cache can be saved, i.e. its directory exists and is writable.
+func (c *Cache) Ping() error {
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(c.path)+".ping*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// CacheKey normalizes address, so insignificant differences in whitespace and case don't cause cache misses.
+func CacheKey(address string) string {
+	return strings.ToLower(strings.Join(strings.Fields(address), " "))
+}
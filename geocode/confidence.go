@@ -0,0 +1,115 @@
+package geocode
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// ManualPrecision is the precision of points, that were set by a human.
+const ManualPrecision = "manual"
+
+// precisionScores are the scores of Yandex geocoder precisions, from the exact house to the city.
+var precisionScores = map[string]float64{
+	ManualPrecision: 1,
+	"exact":         1,
+	"number":        0.9,
+	"near":          0.75,
+	"range":         0.6,
+	"street":        0.4,
+	"other":         0.2,
+}
+
+// Weights of the confidence components.
+const (
+	precisionWeight = 0.5
+	matchWeight     = 0.3
+	agreementWeight = 0.2
+)
+
+// Distances, at which points of two providers agree fully and don't agree at all, in meters.
+const (
+	agreeDistance    = 50
+	disagreeDistance = 1000
+)
+
+// Confidence scores how much the point of geocoded clinic can be trusted, from 0 to 1. It combines
+// the precision, how well the address, normalized by geocoder, matches the raw address, and, if verify
+// is set, how close the point is to the one found by another provider. Points set by a human have
+// confidence 1, clinics without points have 0.
+func Confidence(cc *dmsparse.Clinic, verify *Result) float64 {
+	lat, lon, ok := cc.LatLon()
+	if !ok {
+		return 0
+	}
+	if cc.Precision == ManualPrecision {
+		return 1
+	}
+
+	score := precisionWeight*precisionScores[cc.Precision] + matchWeight*addressMatch(cc.RawAddress, cc.Address)
+	total := precisionWeight + matchWeight
+	if verify != nil {
+		d := Distance(lat, lon, verify.Lat, verify.Lon)
+		agreement := 1 - (d-agreeDistance)/(disagreeDistance-agreeDistance)
+		score += agreementWeight * math.Max(0, math.Min(1, agreement))
+		total += agreementWeight
+	}
+	return math.Round(score/total*100) / 100
+}
+
+// addressMatch returns the share of significant words of the raw address, i.e. numbers and names,
+// found in the normalized address. Words are compared by prefix, so different endings of the same
+// word still match.
+func addressMatch(raw, normalized string) float64 {
+	// the metro station, e.g. "м. Парк Культуры (550 м)", isn't a part of normalized address
+	if n := strings.Index(raw, " м."); n > 0 {
+		raw = raw[:n]
+	}
+	want := addressWords(raw)
+	if len(want) == 0 {
+		return 0
+	}
+	have := addressWords(normalized)
+	var found int
+	for _, w := range want {
+		for _, h := range have {
+			if wordPrefix(w) == wordPrefix(h) {
+				found++
+				break
+			}
+		}
+	}
+	return float64(found) / float64(len(want))
+}
+
+// addressWords returns lower-case numbers and words of at least 4 letters of the address, except
+// for postal codes and names of address parts, like "улица".
+func addressWords(address string) []string {
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(address), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		isNum := unicode.IsDigit([]rune(w)[0])
+		if isNum && len(w) == 6 || !isNum && len([]rune(w)) < 4 || addressPartWords[w] {
+			continue
+		}
+		words = append(words, strings.ReplaceAll(w, "ё", "е"))
+	}
+	return words
+}
+
+var addressPartWords = map[string]bool{
+	"улица": true, "проспект": true, "переулок": true, "проезд": true, "шоссе": true, "бульвар": true,
+	"площадь": true, "набережная": true, "строение": true, "корпус": true, "россия": true, "область": true,
+}
+
+// wordPrefix returns the first 5 letters of the word, that are usually the same in different forms of the word.
+func wordPrefix(w string) string {
+	r := []rune(w)
+	if len(r) > 5 {
+		r = r[:5]
+	}
+	return string(r)
+}
@@ -0,0 +1,15 @@
+package geocode
+
+import "math"
+
+const earthRadius = 6371000 // meters
+
+// Distance returns great-circle distance between two points in meters.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dlat := rad(lat2 - lat1)
+	dlon := rad(lon2 - lon1)
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,78 @@
+package geocode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+)
+
+// Exec is Geocoder, that runs an external command for each address, e.g. a client of an internal
+// geocoding service. The command reads the request from stdin as json:
+//
+//	{"address": "г. Москва, ул. Тверская, д. 1"}
+//
+// and writes the Result to stdout as json:
+//
+//	{"lat": 55.757, "lon": 37.613, "address": "Россия, Москва, Тверская улица, 1", "city": "Москва", "precision": "exact"}
+//
+// The request fails, if the command exits with non-zero status or writes {"error": "..."}.
+type Exec struct {
+	// Command is the command and its arguments.
+	Command []string
+
+	calls int64
+}
+
+// Calls returns the number of times the command was run.
+func (e *Exec) Calls() int64 {
+	return atomic.LoadInt64(&e.calls)
+}
+
+// Ping checks the command can be found.
+func (e *Exec) Ping(ctx context.Context) error {
+	if len(e.Command) == 0 {
+		return errors.New("no geocoder command")
+	}
+	_, err := exec.LookPath(e.Command[0])
+	return err
+}
+
+func (e *Exec) Geocode(address string) (*Result, error) {
+	if len(e.Command) == 0 {
+		return nil, errors.New("no geocoder command")
+	}
+	req, err := json.Marshal(struct {
+		Address string `json:"address"`
+	}{address})
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(e.Command[0], e.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(req)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	atomic.AddInt64(&e.calls, 1)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("geocoder command failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp struct {
+		Result
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("bad geocoder command response: %v", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return &resp.Result, nil
+}
@@ -0,0 +1,41 @@
+// Package geocode resolves clinic addresses into coordinates.
+package geocode
+
+import (
+	"fmt"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// Geocoder resolves address into a location.
+type Geocoder interface {
+	Geocode(address string) (*Result, error)
+}
+
+// Result is the location of geocoded address.
+type Result struct {
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+	Address   string  `json:"address"`
+	City      string  `json:"city"`
+	District  string  `json:"district,omitempty"`
+	Precision string  `json:"precision"`
+}
+
+// GeocodeClinic geocodes clinic's raw address and fills in its points, address, city and precision.
+func GeocodeClinic(g Geocoder, cc *dmsparse.Clinic) error {
+	if cc.RawAddress == "" {
+		return fmt.Errorf("no raw address in clinic: %+v", cc)
+	}
+	res, err := g.Geocode(cc.RawAddress)
+	if err != nil {
+		return err
+	}
+	cc.Points = []float64{res.Lat, res.Lon}
+	cc.Address = res.Address
+	cc.City = res.City
+	cc.District = res.District
+	cc.Precision = res.Precision
+	cc.Confidence = Confidence(cc, nil)
+	return nil
+}
@@ -0,0 +1,72 @@
+package geocode
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPOptions tune the HTTP client of providers.
+type HTTPOptions struct {
+	// Timeout limits the whole request, including reading the response body.
+	Timeout time.Duration
+	// MaxIdleConnsPerHost is the number of keep-alive connections to the provider, that are kept
+	// open between requests. It should be at least the number of concurrent requests.
+	MaxIdleConnsPerHost int
+	// HTTP2 allows negotiating HTTP/2 with the provider.
+	HTTP2 bool
+}
+
+// DefaultHTTPOptions are the options of the client, that providers use by default.
+var DefaultHTTPOptions = HTTPOptions{
+	Timeout:             30 * time.Second,
+	MaxIdleConnsPerHost: 10,
+	HTTP2:               true,
+}
+
+// defaultClient is the client of providers, which have no client set.
+var defaultClient = NewHTTPClient(DefaultHTTPOptions)
+
+// NewHTTPClient returns HTTP client, that is shared by all requests to a provider, so connections
+// are reused. Unlike http.DefaultClient, the client times out, so a stuck connection can't hang
+// the run.
+func NewHTTPClient(opts HTTPOptions) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	tr := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     opts.HTTP2,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: opts.Timeout,
+		ExpectContinueTimeout: time.Second,
+	}
+	if !opts.HTTP2 {
+		// a non-nil empty map disables HTTP/2 upgrade of TLS connections
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return &http.Client{
+		Transport: tr,
+		Timeout:   opts.Timeout,
+	}
+}
+
+// RedactURLError strips the query from the URL of the request error, e.g. of a transport failure, as
+// the query has the API key, and the error ends up in logs and notifications. Other errors are returned as is.
+func RedactURLError(err error) error {
+	var ue *url.Error
+	if !errors.As(err, &ue) {
+		return err
+	}
+	u, _, _ := strings.Cut(ue.URL, "?")
+	return &url.Error{Op: ue.Op, URL: u, Err: ue.Err}
+}
@@ -0,0 +1,35 @@
+package geocode
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactURLError(t *testing.T) {
+	y := &Yandex{APIKey: "SECRET123", Client: &http.Client{Transport: failingTransport{}}}
+	_, err := y.Geocode("Москва, ул. Новая, 1")
+	if err == nil {
+		t.Fatal("Geocode: want error")
+	}
+	if strings.Contains(err.Error(), "SECRET123") {
+		t.Errorf("error leaks API key: %v", err)
+	}
+	if !errors.Is(err, errTransport) {
+		t.Errorf("error doesn't wrap transport error: %v", err)
+	}
+
+	other := errors.New("other")
+	if got := RedactURLError(other); got != other {
+		t.Errorf("RedactURLError(%v) = %v, want the error as is", other, got)
+	}
+}
+
+var errTransport = errors.New("connection refused")
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errTransport
+}
@@ -0,0 +1,159 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const yandexAPI = "https://geocode-maps.yandex.ru/1.x/"
+
+// Yandex is Geocoder, that uses Yandex Maps geocoder API.
+type Yandex struct {
+	// APIKey is the key of the geocoder API. Requests without the key are subject to lower limits.
+	APIKey string
+	// Logger logs requests to the API. If nil, slog.Default is used.
+	Logger *slog.Logger
+	// Client makes requests to the API. If nil, the client with DefaultHTTPOptions is used.
+	Client *http.Client
+
+	calls int64
+}
+
+// Calls returns the number of requests made to the API.
+func (y *Yandex) Calls() int64 {
+	return atomic.LoadInt64(&y.calls)
+}
+
+// Ping checks the API is reachable. Any HTTP response, even an error status, counts, so the check
+// doesn't spend the key's quota.
+func (y *Yandex) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, yandexAPI, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := y.client().Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (y *Yandex) client() *http.Client {
+	if y.Client != nil {
+		return y.Client
+	}
+	return defaultClient
+}
+
+func (y *Yandex) Geocode(address string) (*Result, error) {
+	vals := make(url.Values)
+	vals.Set("geocode", address)
+	vals.Set("lang", "ru_RU")
+	vals.Set("kind", "house")
+	vals.Set("format", "json")
+	if y.APIKey != "" {
+		vals.Set("apikey", y.APIKey)
+	}
+	logger := y.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Debug("geocoding", "provider", "yandex", "address", address)
+
+	atomic.AddInt64(&y.calls, 1)
+	resp, err := y.client().Get(yandexAPI + "?" + vals.Encode())
+	if err != nil {
+		return nil, RedactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w: %s", ErrThrottled, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		r, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("bad response status: %s, %s", resp.Status, r)
+	}
+
+	var geoResp yandexResponse
+	err = json.NewDecoder(resp.Body).Decode(&geoResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(geoResp.Response.GeoObjectCollection.FeatureMember) == 0 {
+		return nil, fmt.Errorf("no geoobject in response: %+v", geoResp)
+	}
+	geoObj := geoResp.Response.GeoObjectCollection.FeatureMember[0].GeoObject
+
+	rawPoints := strings.SplitN(geoObj.Point.Pos, " ", 2)
+	if len(rawPoints) != 2 {
+		return nil, fmt.Errorf("bad points in response: %s", geoObj.Point.Pos)
+	}
+	// geocoder responds with "lon lat" pair
+	var lat, lon float64
+	lon, err = strconv.ParseFloat(strings.TrimSpace(rawPoints[0]), 32)
+	if err == nil {
+		lat, err = strconv.ParseFloat(strings.TrimSpace(rawPoints[1]), 32)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{
+		Lat:       lat,
+		Lon:       lon,
+		Address:   geoObj.MetaDataProperty.GeocoderMetaData.Text,
+		Precision: geoObj.MetaDataProperty.GeocoderMetaData.Precision,
+	}
+	// the first district after the locality is the largest one, e.g. an okrug of Moscow,
+	// rather than a raion within it
+	for _, comp := range geoObj.MetaDataProperty.GeocoderMetaData.Address.Components {
+		switch {
+		case comp.Kind == "locality" && res.City == "":
+			res.City = comp.Name
+		case comp.Kind == "district" && res.City != "" && res.District == "":
+			res.District = comp.Name
+		}
+	}
+
+	return res, nil
+}
+
+type yandexResponse struct {
+	Response struct {
+		GeoObjectCollection struct {
+			FeatureMember []struct {
+				GeoObject yandexGeoObject `json:"GeoObject"`
+			} `json:"featureMember"`
+		}
+	} `json:"response"`
+}
+
+type yandexGeoObject struct {
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	MetaDataProperty struct {
+		GeocoderMetaData struct {
+			Text      string `json:"text"`
+			Precision string `json:"precision"`
+			Address   struct {
+				Components []struct {
+					Kind string `json:"kind"`
+					Name string `json:"name"`
+				} `json:"Components"`
+			} `json:"Address"`
+		} `json:"GeocoderMetaData"`
+	} `json:"metaDataProperty"`
+	Point struct {
+		Pos string `json:"pos"`
+	} `json:"Point"`
+}
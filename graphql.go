@@ -0,0 +1,685 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// graphqlSchema is the schema of the GraphQL endpoint. The endpoint implements a subset of GraphQL,
+// enough for the clients to select fields and filter clinics: a single query operation, with aliases,
+// arguments and variables; fragments, directives and mutations aren't supported.
+const graphqlSchema = `type Query {
+  # bbox is [minLat, minLon, maxLat, maxLon]; services selects clinics, that provide any of the services.
+  clinics(city: String, services: [String!], bbox: [Float!]): [Clinic!]!
+  clinic(id: ID!): Clinic
+}
+
+type Clinic {
+  id: ID!
+  name: String!
+  rawAddress: String!
+  phone: String!
+  phones: [String!]!
+  # services are clinic's categories, e.g. dental or pediatric.
+  services: [String!]!
+  address: String
+  city: String
+  precision: String
+  confidence: Float
+  lat: Float
+  lon: Float
+}
+`
+
+const (
+	// graphqlMaxRequestSize is the limit of the size of POST request.
+	graphqlMaxRequestSize = 1 << 20
+	// gqlMaxDepth is the limit of the nesting of selection sets and values, so a crafted query can't
+	// exhaust the stack of the recursive parser.
+	gqlMaxDepth = 32
+)
+
+// handleGraphQL serves GraphQL queries either as GET with ?query= or as POST with json request.
+func (s *server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		if v := r.URL.Query().Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeGraphQLError(w, "invalid variables: "+err.Error())
+				return
+			}
+		}
+	} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, graphqlMaxRequestSize)).Decode(&req); err != nil {
+		writeGraphQLError(w, "invalid request: "+err.Error())
+		return
+	}
+
+	op, err := parseGraphQL(req.Query)
+	if err != nil {
+		writeGraphQLError(w, err.Error())
+		return
+	}
+	vars := op.defaults
+	for k, v := range req.Variables {
+		vars[k] = v
+	}
+	e := &gqlExecutor{data: s.data.Load(), vars: vars}
+	data, err := e.query(op.selections)
+	if err != nil {
+		writeGraphQLError(w, err.Error())
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, struct {
+		Data gqlObject `json:"data"`
+	}{data})
+}
+
+func writeGraphQLError(w http.ResponseWriter, msg string) {
+	type gqlError struct {
+		Message string `json:"message"`
+	}
+	writeJSONResponse(w, http.StatusOK, struct {
+		Errors []gqlError `json:"errors"`
+	}{[]gqlError{{msg}}})
+}
+
+// gqlExecutor resolves the query over the dataset.
+type gqlExecutor struct {
+	data *dataset
+	vars map[string]interface{}
+}
+
+func (e *gqlExecutor) query(sels []*gqlSelection) (gqlObject, error) {
+	var obj gqlObject
+	for _, sel := range sels {
+		var (
+			v   interface{}
+			err error
+		)
+		switch sel.name {
+		case "__typename":
+			v = "Query"
+		case "clinics":
+			v, err = e.clinics(sel)
+		case "clinic":
+			v, err = e.clinic(sel)
+		default:
+			err = fmt.Errorf("unknown field %q of Query", sel.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		obj = append(obj, gqlField{sel.key(), v})
+	}
+	return obj, nil
+}
+
+func (e *gqlExecutor) clinics(sel *gqlSelection) (interface{}, error) {
+	city, err := e.stringArg(sel, "city")
+	if err != nil {
+		return nil, err
+	}
+	bbox, err := e.floatsArg(sel, "bbox")
+	if err != nil {
+		return nil, err
+	}
+	if bbox != nil && len(bbox) != 4 {
+		return nil, fmt.Errorf("bbox must be [minLat, minLon, maxLat, maxLon]")
+	}
+	services, err := e.stringsArg(sel, "services")
+	if err != nil {
+		return nil, err
+	}
+	filter := make(clinicFilter)
+	if len(services) > 0 {
+		filter["category"] = services
+	}
+
+	list := []gqlObject{}
+	for _, cc := range e.data.clinics {
+		if city != "" && !strings.EqualFold(cc.City, city) || !filter.Match(cc) {
+			continue
+		}
+		if bbox != nil {
+			lat, lon, ok := cc.LatLon()
+			if !ok || lat < bbox[0] || lon < bbox[1] || lat > bbox[2] || lon > bbox[3] {
+				continue
+			}
+		}
+		obj, err := e.clinicFields(cc, sel.selections)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, obj)
+	}
+	return list, nil
+}
+
+func (e *gqlExecutor) clinic(sel *gqlSelection) (interface{}, error) {
+	id, err := e.stringArg(sel, "id")
+	if err != nil {
+		return nil, err
+	}
+	cc, ok := e.data.byID[id]
+	if !ok {
+		return nil, nil
+	}
+	return e.clinicFields(cc, sel.selections)
+}
+
+func (e *gqlExecutor) clinicFields(cc *dmsparse.Clinic, sels []*gqlSelection) (gqlObject, error) {
+	if len(sels) == 0 {
+		return nil, fmt.Errorf("field of type Clinic must have a selection of subfields")
+	}
+	lat, lon, geocoded := cc.LatLon()
+	optional := func(s string) interface{} {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+	var obj gqlObject
+	for _, sel := range sels {
+		var v interface{}
+		switch sel.name {
+		case "__typename":
+			v = "Clinic"
+		case "id":
+			v = cc.ID
+		case "name":
+			v = cc.Name
+		case "rawAddress":
+			v = cc.RawAddress
+		case "phone":
+			v = cc.Phone
+		case "phones":
+			phones := dmsparse.SplitPhones(cc.Phone)
+			if phones == nil {
+				phones = []string{}
+			}
+			v = phones
+		case "services":
+			services := cc.Categories
+			if services == nil {
+				services = []string{}
+			}
+			v = services
+		case "address":
+			v = optional(cc.Address)
+		case "city":
+			v = optional(cc.City)
+		case "precision":
+			v = optional(cc.Precision)
+		case "confidence":
+			if geocoded {
+				v = cc.Confidence
+			}
+		case "lat":
+			if geocoded {
+				v = lat
+			}
+		case "lon":
+			if geocoded {
+				v = lon
+			}
+		default:
+			return nil, fmt.Errorf("unknown field %q of Clinic", sel.name)
+		}
+		obj = append(obj, gqlField{sel.key(), v})
+	}
+	return obj, nil
+}
+
+// arg returns the value of the argument, resolving variables.
+func (e *gqlExecutor) arg(sel *gqlSelection, name string) interface{} {
+	return e.resolve(sel.args[name])
+}
+
+// resolve returns the value of the variable, if v is a reference to it, or v itself.
+func (e *gqlExecutor) resolve(v interface{}) interface{} {
+	if ref, ok := v.(gqlVariable); ok {
+		return e.vars[string(ref)]
+	}
+	return v
+}
+
+func (e *gqlExecutor) stringArg(sel *gqlSelection, name string) (string, error) {
+	switch v := e.arg(sel, name).(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("argument %q must be a string", name)
+}
+
+func (e *gqlExecutor) stringsArg(sel *gqlSelection, name string) ([]string, error) {
+	v := e.arg(sel, name)
+	if v == nil {
+		return nil, nil
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("argument %q must be a list of strings", name)
+	}
+	ss := make([]string, len(list))
+	for i, x := range list {
+		// elements of list literals may be variables, e.g. [$city, "Химки"]
+		s, ok := e.resolve(x).(string)
+		if !ok {
+			return nil, fmt.Errorf("argument %q must be a list of strings", name)
+		}
+		ss[i] = s
+	}
+	return ss, nil
+}
+
+func (e *gqlExecutor) floatsArg(sel *gqlSelection, name string) ([]float64, error) {
+	v := e.arg(sel, name)
+	if v == nil {
+		return nil, nil
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("argument %q must be a list of floats", name)
+	}
+	ff := make([]float64, len(list))
+	for i, x := range list {
+		f, ok := e.resolve(x).(float64)
+		if !ok {
+			return nil, fmt.Errorf("argument %q must be a list of floats", name)
+		}
+		ff[i] = f
+	}
+	return ff, nil
+}
+
+// gqlObject is a json object, that keeps the order of the fields as they were selected in the query.
+type gqlObject []gqlField
+
+type gqlField struct {
+	key   string
+	value interface{}
+}
+
+func (o gqlObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.key)
+		v, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// gqlOperation is the parsed query operation.
+type gqlOperation struct {
+	selections []*gqlSelection
+	// defaults are the default values of the operation's variables.
+	defaults map[string]interface{}
+}
+
+type gqlSelection struct {
+	alias, name string
+	args        map[string]interface{}
+	selections  []*gqlSelection
+}
+
+// key is the name of the field in the response.
+func (s *gqlSelection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+// gqlVariable is a reference to a variable in argument value.
+type gqlVariable string
+
+// parseGraphQL parses the query document, that consists of a single query operation.
+func parseGraphQL(query string) (*gqlOperation, error) {
+	p := &gqlParser{src: query}
+	p.next()
+
+	op := &gqlOperation{defaults: make(map[string]interface{})}
+	if p.tok == "query" {
+		p.next()
+		if p.kind == gqlName {
+			p.next()
+		}
+		if p.tok == "(" {
+			if err := p.variables(op.defaults); err != nil {
+				return nil, err
+			}
+		}
+	} else if p.kind == gqlName {
+		return nil, fmt.Errorf("unsupported operation %q", p.tok)
+	}
+
+	sels, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	if p.kind != gqlEOF {
+		return nil, p.errorf("unexpected %q after the operation", p.tok)
+	}
+	op.selections = sels
+	return op, nil
+}
+
+const (
+	gqlEOF = iota
+	gqlPunct
+	gqlName
+	gqlNumber
+	gqlString
+)
+
+type gqlParser struct {
+	src  string
+	pos  int
+	tok  string
+	kind int
+	err  error
+	// depth is the nesting of selection sets and values being parsed.
+	depth int
+}
+
+func (p *gqlParser) errorf(format string, args ...interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	return fmt.Errorf("graphql: "+format+" at offset %d", append(args, p.pos)...)
+}
+
+// next reads the next token. Commas are insignificant in GraphQL and are skipped as whitespace.
+func (p *gqlParser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != ',' {
+			break
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		p.tok, p.kind = "", gqlEOF
+		return
+	}
+
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case strings.IndexByte("!$():=@[]{}|", c) >= 0:
+		p.pos++
+		p.kind = gqlPunct
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+		p.kind = gqlName
+	case c == '-' || unicode.IsDigit(rune(c)):
+		p.pos++
+		for p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		p.kind = gqlNumber
+	case c == '"':
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] != '"' {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.src) {
+			p.err = p.errorf("unterminated string")
+			p.tok, p.kind = "", gqlEOF
+			return
+		}
+		p.pos++
+		p.kind = gqlString
+	default:
+		p.err = p.errorf("unexpected character %q", c)
+		p.tok, p.kind = "", gqlEOF
+		return
+	}
+	p.tok = p.src[start:p.pos]
+}
+
+// enter accounts for a nested selection set or value. The caller must call p.leave, when it's parsed.
+func (p *gqlParser) enter() error {
+	p.depth++
+	if p.depth > gqlMaxDepth {
+		return p.errorf("query is nested deeper than %d levels", gqlMaxDepth)
+	}
+	return nil
+}
+
+func (p *gqlParser) leave() {
+	p.depth--
+}
+
+func (p *gqlParser) expect(tok string) error {
+	if p.tok != tok {
+		return p.errorf("expected %q, got %q", tok, p.tok)
+	}
+	p.next()
+	return nil
+}
+
+// variables parses variable definitions, e.g. ($city: String = "Москва"), and collects default values.
+func (p *gqlParser) variables(defaults map[string]interface{}) error {
+	p.next()
+	for p.tok != ")" {
+		if err := p.expect("$"); err != nil {
+			return err
+		}
+		if p.kind != gqlName {
+			return p.errorf("expected variable name, got %q", p.tok)
+		}
+		name := p.tok
+		p.next()
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		// the types aren't checked, arguments are validated when the fields are resolved
+		for p.tok == "[" || p.tok == "]" || p.tok == "!" || p.kind == gqlName {
+			p.next()
+		}
+		if p.tok == "=" {
+			p.next()
+			v, err := p.value()
+			if err != nil {
+				return err
+			}
+			defaults[name] = v
+		}
+		if p.kind == gqlEOF {
+			return p.errorf("unterminated variable definitions")
+		}
+	}
+	p.next()
+	return nil
+}
+
+func (p *gqlParser) selectionSet() ([]*gqlSelection, error) {
+	defer p.leave()
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var sels []*gqlSelection
+	for p.tok != "}" {
+		if p.kind != gqlName {
+			return nil, p.errorf("expected field name, got %q", p.tok)
+		}
+		sel := &gqlSelection{name: p.tok}
+		p.next()
+		if p.tok == ":" {
+			p.next()
+			if p.kind != gqlName {
+				return nil, p.errorf("expected field name, got %q", p.tok)
+			}
+			sel.alias, sel.name = sel.name, p.tok
+			p.next()
+		}
+		if p.tok == "(" {
+			args, err := p.arguments()
+			if err != nil {
+				return nil, err
+			}
+			sel.args = args
+		}
+		if p.tok == "{" {
+			sub, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			sel.selections = sub
+		}
+		sels = append(sels, sel)
+	}
+	p.next()
+	return sels, nil
+}
+
+func (p *gqlParser) arguments() (map[string]interface{}, error) {
+	p.next()
+	args := make(map[string]interface{})
+	for p.tok != ")" {
+		if p.kind != gqlName {
+			return nil, p.errorf("expected argument name, got %q", p.tok)
+		}
+		name := p.tok
+		p.next()
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		args[name] = v
+	}
+	p.next()
+	return args, nil
+}
+
+// value parses a literal value or a variable reference. Numbers are float64, lists are []interface{},
+// and input objects are map[string]interface{}, the same as in json-decoded variables.
+func (p *gqlParser) value() (interface{}, error) {
+	defer p.leave()
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	tok, kind := p.tok, p.kind
+	switch {
+	case tok == "$":
+		p.next()
+		if p.kind != gqlName {
+			return nil, p.errorf("expected variable name, got %q", p.tok)
+		}
+		name := p.tok
+		p.next()
+		return gqlVariable(name), nil
+	case tok == "[":
+		p.next()
+		list := []interface{}{}
+		for p.tok != "]" {
+			if p.kind == gqlEOF {
+				return nil, p.errorf("unterminated list")
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		p.next()
+		return list, nil
+	case tok == "{":
+		p.next()
+		obj, err := p.objectFields()
+		if err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case kind == gqlNumber:
+		p.next()
+		f, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", tok)
+		}
+		return f, nil
+	case kind == gqlString:
+		p.next()
+		var s string
+		if err := json.Unmarshal([]byte(tok), &s); err != nil {
+			return nil, p.errorf("invalid string %s", tok)
+		}
+		return s, nil
+	case kind == gqlName:
+		p.next()
+		switch tok {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		// enum values are passed as strings
+		return tok, nil
+	}
+	return nil, p.errorf("unexpected %q in value", tok)
+}
+
+func (p *gqlParser) objectFields() (map[string]interface{}, error) {
+	obj := make(map[string]interface{})
+	for p.tok != "}" {
+		if p.kind != gqlName {
+			return nil, p.errorf("expected field name, got %q", p.tok)
+		}
+		name := p.tok
+		p.next()
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		obj[name] = v
+	}
+	p.next()
+	return obj, nil
+}
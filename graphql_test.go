@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+func TestParseGraphQL(t *testing.T) {
+	op, err := parseGraphQL(`query Clinics($city: String = "Москва") {
+		# comments and commas are insignificant
+		moscow: clinics(city: $city, bbox: [55.5, 37.3, 56, 37.9], services: ["dental"]) { id, name }
+		clinic(id: "abc") { __typename }
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := op.defaults["city"]; got != "Москва" {
+		t.Errorf("default of $city = %v, want Москва", got)
+	}
+	if len(op.selections) != 2 {
+		t.Fatalf("got %d selections, want 2", len(op.selections))
+	}
+
+	sel := op.selections[0]
+	if sel.alias != "moscow" || sel.name != "clinics" || sel.key() != "moscow" {
+		t.Errorf("alias, name = %q, %q, want moscow, clinics", sel.alias, sel.name)
+	}
+	wantArgs := map[string]interface{}{
+		"city":     gqlVariable("city"),
+		"bbox":     []interface{}{55.5, 37.3, 56.0, 37.9},
+		"services": []interface{}{"dental"},
+	}
+	if !reflect.DeepEqual(sel.args, wantArgs) {
+		t.Errorf("args = %#v, want %#v", sel.args, wantArgs)
+	}
+	if len(sel.selections) != 2 || sel.selections[0].name != "id" || sel.selections[1].name != "name" {
+		t.Errorf("unexpected subselections %+v", sel.selections)
+	}
+	if got := op.selections[1].args["id"]; got != "abc" {
+		t.Errorf("clinic id = %v, want abc", got)
+	}
+}
+
+func TestParseGraphQLErrors(t *testing.T) {
+	tests := []struct {
+		name, query, err string
+	}{
+		{"mutation", `mutation { clinics { id } }`, `unsupported operation "mutation"`},
+		{"unterminated string", `{ clinic(id: "abc) { id } }`, "unterminated string"},
+		{"unterminated list", `{ clinics(bbox: [1, 2`, "unterminated list"},
+		{"trailing tokens", `{ clinics { id } } }`, "after the operation"},
+		{"deep selections", strings.Repeat("{ a ", gqlMaxDepth+1) + strings.Repeat("}", gqlMaxDepth+1), "nested deeper"},
+		{"deep values", `{ clinics(city: ` + strings.Repeat("[", 100000) + `) { id } }`, "nested deeper"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseGraphQL(tt.query)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseGraphQL() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	// the limit leaves room for real queries
+	query := strings.Repeat("{ a ", gqlMaxDepth) + strings.Repeat("}", gqlMaxDepth)
+	if _, err := parseGraphQL(query); err != nil {
+		t.Errorf("parseGraphQL() of %d levels: %v", gqlMaxDepth, err)
+	}
+}
+
+func TestHandleGraphQL(t *testing.T) {
+	s := &server{}
+	s.data.Store(newDataset([]*dmsparse.Clinic{
+		{ID: "1", Name: "Стоматология", City: "Москва", Categories: []string{"dental"}, Points: []float64{55.75, 37.61}},
+		{ID: "2", Name: "Детская клиника", City: "Москва", Categories: []string{"pediatric"}, Points: []float64{55.7, 37.5}},
+		{ID: "3", Name: "Зубной", City: "Казань", Categories: []string{"dental"}, Points: []float64{55.79, 49.1}},
+	}, ""))
+
+	query := func(body string) string {
+		w := httptest.NewRecorder()
+		s.handleGraphQL(w, httptest.NewRequest("POST", "/graphql", strings.NewReader(body)))
+		return strings.TrimSpace(w.Body.String())
+	}
+
+	req, _ := json.Marshal(map[string]interface{}{
+		"query":     `query($s: [String!]) { clinics(city: "москва", services: $s) { id services } }`,
+		"variables": map[string]interface{}{"s": []string{"DENTAL", "surgery"}},
+	})
+	want := `{"data":{"clinics":[{"id":"1","services":["dental"]}]}}`
+	if got := query(string(req)); got != want {
+		t.Errorf("services filter:\n got %s\nwant %s", got, want)
+	}
+
+	req, _ = json.Marshal(map[string]interface{}{
+		"query":     `query($minLat: Float!, $s: String!) { clinics(bbox: [$minLat, 37.5, 56, 37.7], services: [$s, "surgery"]) { id } }`,
+		"variables": map[string]interface{}{"minLat": 55.6, "s": "pediatric"},
+	})
+	want = `{"data":{"clinics":[{"id":"2"}]}}`
+	if got := query(string(req)); got != want {
+		t.Errorf("variables in lists:\n got %s\nwant %s", got, want)
+	}
+
+	got := query(`{"query": "{ clinics(services: \"dental\") { id } }"}`)
+	if !strings.Contains(got, `argument \"services\" must be a list of strings`) {
+		t.Errorf("services of wrong type: got %s", got)
+	}
+
+	big := `{"query": "{ clinics { id } }", "variables": {"pad": "` + strings.Repeat("x", graphqlMaxRequestSize) + `"}}`
+	if got := query(big); !strings.Contains(got, "request body too large") {
+		t.Errorf("oversized request: got %s", got)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// geocoderCheckInterval is how long the result of the geocoder reachability check is reused,
+// so frequent probes don't flood the provider.
+const geocoderCheckInterval = 30 * time.Second
+
+// handleHealthz reports the server is alive.
+func (s *server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz reports whether the server is ready to serve: the dataset is loaded, the geocoder
+// is reachable and the geocoder cache can be saved. It responds 503, if any check fails.
+func (s *server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{
+		"dataset":  "ok",
+		"geocoder": "ok",
+		"cache":    "ok",
+	}
+	ready := true
+	fail := func(name string, err error) {
+		checks[name] = err.Error()
+		ready = false
+	}
+
+	if !s.loaded.Load() {
+		fail("dataset", errors.New("not loaded"))
+	}
+	if err := s.checkGeocoder(r.Context()); err != nil {
+		fail("geocoder", err)
+	}
+	if err := s.geocoder.PingCache(); err != nil {
+		fail("cache", err)
+	}
+
+	status, code := "ok", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	writeJSONResponse(w, code, struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}{status, checks})
+}
+
+// geocoderCheck is the last result of the geocoder reachability check.
+type geocoderCheck struct {
+	mu      sync.Mutex
+	err     error
+	checked time.Time
+}
+
+func (s *server) checkGeocoder(ctx context.Context) error {
+	c := &s.geocoderCheck
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.checked) < geocoderCheckInterval {
+		return c.err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	c.err = s.geocoder.Ping(ctx)
+	c.checked = time.Now()
+	return c.err
+}
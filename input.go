@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/export"
+)
+
+// inputFlags are the flags of commands, that read clinics.
+type inputFlags struct {
+	in      stringsFlag
+	format  *string
+	columns *string
+
+	// stdinFormat is the input format, if clinics are read from stdin.
+	stdinFormat string
+}
+
+func newInputFlags(fs *flag.FlagSet, usage, stdinFormat string) *inputFlags {
+	f := &inputFlags{stdinFormat: stdinFormat}
+	fs.Var(&f.in, "in", usage+", http(s) URL, or - for stdin (default); may be repeated or be a glob, e.g. regions/*.txt, to read several files, which are merged")
+	f.format = fs.String("in-format", "", "input format: text, json, yaml, ndjson, csv or xlsx (default by -in extension, "+stdinFormat+" for stdin)")
+	f.columns = fs.String("map", "", `columns of csv and xlsx input as "field=column,...", where several columns of a field are joined with +, e.g. "name=Клиника,address=Город+Адрес,phone=Телефоны" (fields: `+strings.Join(dmsparse.TableFields, ", ")+`; default by common column names)`)
+	return f
+}
+
+// paths returns the input paths with globs expanded. Without -in, clinics are read from stdin.
+func (f *inputFlags) paths() ([]string, error) {
+	if len(f.in) == 0 {
+		return []string{"-"}, nil
+	}
+	var paths []string
+	for _, p := range f.in {
+		if isURL(p) || !strings.ContainsAny(p, "*?[") {
+			paths = append(paths, p)
+			continue
+		}
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no input files match %q", p)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// isStdin reports whether clinics are read from stdin.
+func (f *inputFlags) isStdin() bool {
+	return len(f.in) == 0 || slices.Contains(f.in, "-")
+}
+
+func (f *inputFlags) formatOf(path string) string {
+	if *f.format == "" && path == "-" {
+		return f.stdinFormat
+	}
+	return *f.format
+}
+
+// read reads clinics from the input and returns them along with the input's checksum. Several input
+// files are parsed concurrently and merged in the order of the files; clinics, which are listed in
+// several files, are kept once, as in the first file, with the programs of all files. The checksum of several files is the checksum of their checksums.
+func (f *inputFlags) read() ([]*dmsparse.Clinic, string, error) {
+	paths, err := f.paths()
+	if err != nil {
+		return nil, "", err
+	}
+	columns, err := dmsparse.ParseColumnMap(*f.columns)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(paths) == 1 {
+		return readInput(paths[0], f.formatOf(paths[0]), columns)
+	}
+
+	type result struct {
+		clinics  []*dmsparse.Clinic
+		checksum string
+		err      error
+	}
+	var (
+		results = make([]result, len(paths))
+		wg      sync.WaitGroup
+	)
+	for i, path := range paths {
+		wg.Add(1)
+		go func(r *result, path string) {
+			defer wg.Done()
+			if r.clinics, r.checksum, r.err = readInput(path, f.formatOf(path), columns); r.err != nil {
+				r.err = fmt.Errorf("%s: %v", path, r.err)
+			}
+		}(&results[i], path)
+	}
+	wg.Wait()
+
+	var (
+		clinics []*dmsparse.Clinic
+		seen    = make(map[string]*dmsparse.Clinic)
+		h       = sha256.New()
+	)
+	for _, r := range results {
+		if r.err != nil {
+			return nil, "", r.err
+		}
+		io.WriteString(h, r.checksum)
+		n := len(clinics)
+		for _, cc := range r.clinics {
+			if first, ok := seen[cc.ID]; ok {
+				dmsparse.MergePrograms(first, cc)
+				continue
+			}
+			clinics = append(clinics, cc)
+		}
+		for _, cc := range clinics[n:] {
+			seen[cc.ID] = cc
+		}
+	}
+	return clinics, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// stream reads clinics from the input one by one, calling fn for each of them, and returns the input's
+// checksum. Only ndjson and text inputs can be streamed. Several input files are read one after another,
+// and clinics, which are listed in several files, aren't merged.
+func (f *inputFlags) stream(fn func(cc *dmsparse.Clinic) error) (string, error) {
+	paths, err := f.paths()
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 1 {
+		return streamInput(paths[0], f.formatOf(paths[0]), fn)
+	}
+	h := sha256.New()
+	for _, path := range paths {
+		checksum, err := streamInput(path, f.formatOf(path), fn)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", path, err)
+		}
+		io.WriteString(h, checksum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func streamInput(path, format string, fn func(cc *dmsparse.Clinic) error) (string, error) {
+	r, path, err := openInput(path)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	in := io.TeeReader(r, h)
+	switch format = inputFormat(path, format); format {
+	case "ndjson":
+		err = export.ReadNDJSONFunc(in, fn)
+	case "text":
+		err = dmsparse.ParseFunc(in, fn)
+	default:
+		return "", fmt.Errorf("%s input can't be streamed, only ndjson and text can", format)
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readInput reads clinics from the input file, from http(s) URL, or from stdin if path is "-", and returns them
+// along with the input's checksum. If format is empty, it's detected by the file's extension:
+// datasets in json, yaml or ndjson are read as is, csv and xlsx tables are read by columns,
+// any other file is parsed as DMS text document.
+func readInput(path, format string, columns dmsparse.ColumnMap) (clinics []*dmsparse.Clinic, checksum string, err error) {
+	r, path, err := openInput(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	in := io.TeeReader(r, h)
+
+	switch inputFormat(path, format) {
+	case "yaml":
+		clinics, err = export.ReadYAML(in)
+	case "json":
+		clinics, err = export.ReadJSON(in)
+	case "ndjson":
+		clinics, err = export.ReadNDJSON(in)
+	case "csv":
+		clinics, err = dmsparse.ReadCSV(in, columns)
+	case "xlsx":
+		clinics, err = dmsparse.ReadXLSX(in, columns)
+	case "text":
+		clinics, err = dmsparse.Parse(in)
+	default:
+		return nil, "", fmt.Errorf("unknown input format: %q", format)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return clinics, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// openInput opens the input file, http(s) URL, or stdin if path is "-". It returns the path of
+// the input, e.g. the path of the URL, to detect the format by.
+func openInput(path string) (io.ReadCloser, string, error) {
+	switch {
+	case isURL(path):
+		resp, err := http.Get(path)
+		if err != nil {
+			return nil, "", err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, "", fmt.Errorf("could not fetch %s: %s", path, resp.Status)
+		}
+		if u, err := url.Parse(path); err == nil {
+			path = u.Path
+		}
+		return resp.Body, path, nil
+	case path != "-":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, "", err
+		}
+		return f, path, nil
+	}
+	return io.NopCloser(os.Stdin), path, nil
+}
+
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// inputFormat returns format, or detects it by the extension of path, if it's empty.
+func inputFormat(path, format string) string {
+	if format != "" {
+		return format
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".json":
+		return "json"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	case ".csv":
+		return "csv"
+	case ".xlsx":
+		return "xlsx"
+	}
+	return "text"
+}
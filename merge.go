@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// mergePolicies are the policies of -merge-conflict, which resolve fields, that differ between
+// a clinic of the existing dataset and the same clinic of the new one.
+var mergePolicies = []string{"new", "existing", "fail"}
+
+// maxMergeConflicts is the number of conflicts, listed in the error of "fail" policy.
+const maxMergeConflicts = 10
+
+// mergeStats are the numbers of clinics of the existing dataset, that were updated or kept as is,
+// and of the new clinics, that were appended to it.
+type mergeStats struct {
+	Updated int
+	Kept    int
+	Added   int
+}
+
+// mergeDataset folds clinics into the existing dataset and returns the merged clinics: a clinic of the
+// existing dataset is updated field by field with the clinic of the same ID, new clinics are appended,
+// and the existing clinics, which aren't among the new ones, are kept. Empty fields never overwrite
+// set ones; of the fields, set in both, the new ones win with "new" policy, the existing ones with
+// "existing" policy, and "fail" policy fails the merge. Manually set points of the existing dataset,
+// e.g. fixed by reviewers, are always kept.
+func mergeDataset(existing, clinics []*dmsparse.Clinic, policy string) ([]*dmsparse.Clinic, mergeStats, error) {
+	var stats mergeStats
+	if !slices.Contains(mergePolicies, policy) {
+		return nil, stats, fmt.Errorf("unknown merge conflict policy %q, expected one of %s", policy, strings.Join(mergePolicies, ", "))
+	}
+
+	byID := make(map[string]*dmsparse.Clinic, len(clinics))
+	for _, cc := range clinics {
+		byID[cc.ID] = cc
+	}
+	var (
+		merged    = make([]*dmsparse.Clinic, 0, len(existing)+len(clinics))
+		seen      = make(map[string]bool, len(existing))
+		conflicts []string
+	)
+	for _, old := range existing {
+		seen[old.ID] = true
+		cc, ok := byID[old.ID]
+		if !ok {
+			merged = append(merged, old)
+			stats.Kept++
+			continue
+		}
+		m := *old
+		fields := mergeClinic(&m, cc, policy == "new")
+		for _, f := range fields {
+			conflicts = append(conflicts, old.ID+" "+f)
+		}
+		if reflect.DeepEqual(*old, m) {
+			stats.Kept++
+		} else {
+			stats.Updated++
+		}
+		merged = append(merged, &m)
+	}
+	if policy == "fail" && len(conflicts) > 0 {
+		n := len(conflicts)
+		if n > maxMergeConflicts {
+			conflicts = append(conflicts[:maxMergeConflicts], "...")
+		}
+		return nil, stats, fmt.Errorf("%d fields conflict with the existing dataset: %s", n, strings.Join(conflicts, ", "))
+	}
+	for _, cc := range clinics {
+		if !seen[cc.ID] {
+			merged = append(merged, cc)
+			stats.Added++
+		}
+	}
+	return merged, stats, nil
+}
+
+// mergeClinic updates the fields of m, a copy of the existing clinic, with the set fields of cc, and returns
+// the names of the fields, that are set in both and differ. The conflicting fields are updated only if
+// overwrite is set.
+func mergeClinic(m, cc *dmsparse.Clinic, overwrite bool) []string {
+	var conflicts []string
+	str := func(name string, dst *string, src string) {
+		switch {
+		case src == "" || *dst == src:
+		case *dst == "":
+			*dst = src
+		default:
+			conflicts = append(conflicts, name)
+			if overwrite {
+				*dst = src
+			}
+		}
+	}
+	str("name", &m.Name, cc.Name)
+	str("raw_address", &m.RawAddress, cc.RawAddress)
+	str("phone", &m.Phone, cc.Phone)
+	str("address", &m.Address, cc.Address)
+	str("city", &m.City, cc.City)
+	str("district", &m.District, cc.District)
+
+	// the point, its precision and confidence go together
+	_, _, oldOK := m.LatLon()
+	if _, _, ok := cc.LatLon(); ok && m.Precision != overridePrecision {
+		switch {
+		case !oldOK:
+			m.Points, m.Precision, m.Confidence = cc.Points, cc.Precision, cc.Confidence
+		case !slices.Equal(m.Points, cc.Points):
+			conflicts = append(conflicts, "points")
+			if overwrite {
+				m.Points, m.Precision, m.Confidence = cc.Points, cc.Precision, cc.Confidence
+			}
+		}
+	}
+
+	list := func(name string, dst *[]string, src []string) {
+		switch {
+		case len(src) == 0 || slices.Equal(*dst, src):
+		case len(*dst) == 0:
+			*dst = src
+		default:
+			conflicts = append(conflicts, name)
+			if overwrite {
+				*dst = src
+			}
+		}
+	}
+	list("categories", &m.Categories, cc.Categories)
+	list("programs", &m.Programs, cc.Programs)
+
+	// the data of external sources doesn't conflict, as it's looked up anew; it's replaced as a whole,
+	// unless the existing data wins
+	if cc.License != nil && (m.License == nil || overwrite) {
+		m.License = cc.License
+	}
+	if cc.Place != nil && (m.Place == nil || overwrite) {
+		m.Place = cc.Place
+	}
+	if cc.Firm != nil && (m.Firm == nil || overwrite) {
+		m.Firm = cc.Firm
+	}
+	if cc.Travel != nil && (m.Travel == nil || overwrite) {
+		m.Travel = cc.Travel
+	}
+	return conflicts
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/narqo/vtb-dms/geocode"
+)
+
+// latencyBuckets are the upper bounds of geocoder request latency histogram, in seconds.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metrics are the process-wide metrics, exposed in Prometheus text format.
+var metrics = newMetricSet()
+
+// metricSet collects counters and histograms of geocoding runs.
+type metricSet struct {
+	mu sync.Mutex
+	// requests are the provider requests by provider and status.
+	requests map[[2]string]int64
+	// latency are the histograms of provider request latency by provider.
+	latency map[string]*histogram
+	// clinics are the processed clinics by status: geocoded, failed or skipped (already had points).
+	clinics map[string]int64
+	// cacheLookups is the number of addresses looked up in the cache.
+	cacheLookups int64
+	// cacheHits, if set, returns the number of addresses resolved from the cache.
+	cacheHits func() int64
+	// concurrency, if set, returns the current limit of concurrent provider requests.
+	concurrency func() int
+}
+
+type histogram struct {
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{
+		requests: make(map[[2]string]int64),
+		latency:  make(map[string]*histogram),
+		clinics:  make(map[string]int64),
+	}
+}
+
+// ObserveRequest records a provider request.
+func (m *metricSet) ObserveRequest(provider string, err error, d time.Duration) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[[2]string{provider, status}]++
+	h := m.latency[provider]
+	if h == nil {
+		h = &histogram{counts: make([]int64, len(latencyBuckets))}
+		m.latency[provider] = h
+	}
+	sec := d.Seconds()
+	for i, le := range latencyBuckets {
+		if sec <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += sec
+	h.count++
+}
+
+// ClinicProcessed records a processed clinic.
+func (m *metricSet) ClinicProcessed(status string) {
+	m.mu.Lock()
+	m.clinics[status]++
+	m.mu.Unlock()
+}
+
+// CacheLookup records an address looked up in the cache.
+func (m *metricSet) CacheLookup() {
+	m.mu.Lock()
+	m.cacheLookups++
+	m.mu.Unlock()
+}
+
+// SetCacheHits sets the source of the number of cache hits.
+func (m *metricSet) SetCacheHits(fn func() int64) {
+	m.mu.Lock()
+	m.cacheHits = fn
+	m.mu.Unlock()
+}
+
+// SetConcurrency sets the source of the current limit of concurrent provider requests.
+func (m *metricSet) SetConcurrency(fn func() int) {
+	m.mu.Lock()
+	m.concurrency = fn
+	m.mu.Unlock()
+}
+
+// Write writes metrics in Prometheus text exposition format.
+func (m *metricSet) Write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b bytes.Buffer
+
+	metricHeader(&b, "gen_points_geocode_requests_total", "counter", "Geocoder provider requests by provider and status.")
+	keys := make([][2]string, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		fmt.Fprintf(&b, "gen_points_geocode_requests_total{provider=%q,status=%q} %d\n", k[0], k[1], m.requests[k])
+	}
+
+	metricHeader(&b, "gen_points_geocode_request_duration_seconds", "histogram", "Geocoder provider request latency.")
+	for _, provider := range sortedKeys(m.latency) {
+		h := m.latency[provider]
+		for i, le := range latencyBuckets {
+			fmt.Fprintf(&b, "gen_points_geocode_request_duration_seconds_bucket{provider=%q,le=\"%g\"} %d\n", provider, le, h.counts[i])
+		}
+		fmt.Fprintf(&b, "gen_points_geocode_request_duration_seconds_bucket{provider=%q,le=\"+Inf\"} %d\n", provider, h.count)
+		fmt.Fprintf(&b, "gen_points_geocode_request_duration_seconds_sum{provider=%q} %g\n", provider, h.sum)
+		fmt.Fprintf(&b, "gen_points_geocode_request_duration_seconds_count{provider=%q} %d\n", provider, h.count)
+	}
+
+	metricHeader(&b, "gen_points_clinics_processed_total", "counter", "Processed clinics by status.")
+	for _, status := range sortedKeys(m.clinics) {
+		fmt.Fprintf(&b, "gen_points_clinics_processed_total{status=%q} %d\n", status, m.clinics[status])
+	}
+
+	if m.cacheHits != nil {
+		metricHeader(&b, "gen_points_geocode_cache_lookups_total", "counter", "Addresses looked up in geocoder cache.")
+		fmt.Fprintf(&b, "gen_points_geocode_cache_lookups_total %d\n", m.cacheLookups)
+		metricHeader(&b, "gen_points_geocode_cache_hits_total", "counter", "Addresses resolved from geocoder cache.")
+		fmt.Fprintf(&b, "gen_points_geocode_cache_hits_total %d\n", m.cacheHits())
+	}
+
+	if m.concurrency != nil {
+		metricHeader(&b, "gen_points_geocode_concurrency", "gauge", "Current limit of concurrent geocoder provider requests.")
+		fmt.Fprintf(&b, "gen_points_geocode_concurrency %d\n", m.concurrency())
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WriteFile writes metrics to the file at path, e.g. for node_exporter's textfile collector.
+// The file is replaced atomically, so the collector never reads it partially written.
+func (m *metricSet) WriteFile(path string) error {
+	var b bytes.Buffer
+	if err := m.Write(&b); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, b.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ServeHTTP serves metrics endpoint.
+func (m *metricSet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.Write(w)
+}
+
+func metricHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// instrumentedGeocoder is Geocoder, that records requests to the provider in metrics.
+type instrumentedGeocoder struct {
+	geocode.Geocoder
+	provider string
+}
+
+func (g instrumentedGeocoder) Geocode(address string) (*geocode.Result, error) {
+	start := time.Now()
+	res, err := g.Geocoder.Geocode(address)
+	metrics.ObserveRequest(g.provider, err, time.Since(start))
+	return res, err
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Webhook delivery parameters.
+const (
+	notifyAttempts = 3
+	notifyTimeout  = 10 * time.Second
+)
+
+// runNotification is the body of the webhook, posted when a run finishes.
+type runNotification struct {
+	Event string `json:"event"`
+	// Status is "ok", or "failed", if too many clinics failed or vanished; Error tells why.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// DatasetVersion identifies the content of the dataset, see datasetHash.
+	DatasetVersion string      `json:"dataset_version,omitempty"`
+	SourceSHA256   string      `json:"source_sha256,omitempty"`
+	Summary        *runSummary `json:"summary"`
+	Artifacts      []artifact  `json:"artifacts"`
+	FinishedAt     time.Time   `json:"finished_at"`
+}
+
+func newRunNotification(summary *runSummary, version, checksum string, artifacts []artifact, err error) *runNotification {
+	n := &runNotification{
+		Event:          "run.finished",
+		Status:         "ok",
+		DatasetVersion: version,
+		SourceSHA256:   checksum,
+		Summary:        summary,
+		Artifacts:      artifacts,
+		FinishedAt:     time.Now().UTC(),
+	}
+	if err != nil {
+		n.Status, n.Error = "failed", err.Error()
+	}
+	if n.Artifacts == nil {
+		n.Artifacts = []artifact{}
+	}
+	return n
+}
+
+// artifact is an output of the run. Checksum and size are only known for local files.
+type artifact struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+	SHA256 string `json:"sha256,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// artifacts describes the outputs, configured with flags, after they were written.
+func (f *exportFlags) artifacts() []artifact {
+	targets, err := f.targets()
+	if err != nil {
+		return nil
+	}
+	var list []artifact
+	for _, t := range targets {
+		if t.Path == "" || t.Path == "-" {
+			continue
+		}
+		a := artifact{Path: redactURL(t.Path), Format: t.Format}
+		if sum, size, err := fileChecksum(t.Path); err == nil {
+			a.SHA256, a.Size = sum, size
+		}
+		list = append(list, a)
+	}
+	return list
+}
+
+// fileChecksum returns SHA-256 checksum and the size of the regular file at path.
+func fileChecksum(path string) (string, int64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+	if !fi.Mode().IsRegular() {
+		return "", 0, fmt.Errorf("%s isn't a regular file", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// notifyWebhook posts the notification to url as json, retrying failed deliveries. Delivery errors
+// are logged, but don't fail the run, as the outputs are written already.
+func notifyWebhook(url string, n *runNotification) {
+	body, err := json.Marshal(n)
+	if err != nil {
+		slog.Error("could not encode webhook notification", "err", err)
+		return
+	}
+	client := &http.Client{Timeout: notifyTimeout}
+	for attempt := 1; ; attempt++ {
+		err = postJSON(client, url, body)
+		if err == nil {
+			slog.Info("notified webhook", "url", redactURL(url), "status", n.Status)
+			return
+		}
+		if attempt == notifyAttempts {
+			break
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	slog.Error("could not notify webhook", "url", redactURL(url), "attempts", notifyAttempts, "err", err)
+}
+
+func postJSON(client *http.Client, url string, body []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("bad response status: %s", resp.Status)
+	}
+	return nil
+}
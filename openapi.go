@@ -0,0 +1,402 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// openAPISpec is OpenAPI 3.1 document of serve mode REST API. Every route of the server must be
+// described here: newServer refuses to register a route, missing in the document.
+const openAPISpec = `{
+  "openapi": "3.1.0",
+  "info": {
+    "title": "VTB DMS clinics",
+    "description": "Geocoded clinics of VTB DMS program, served by gen_points serve.",
+    "version": "1"
+  },
+  "security": [{}, {"bearer": []}],
+  "paths": {
+    "/clinics": {
+      "get": {
+        "operationId": "listClinics",
+        "summary": "List clinics. Repeated filter parameters are alternative values.",
+        "parameters": [
+          {"name": "id", "in": "query", "schema": {"type": "string"}},
+          {"name": "city", "in": "query", "schema": {"type": "string"}},
+          {"name": "precision", "in": "query", "schema": {"type": "string"}},
+          {"name": "geocoded", "in": "query", "schema": {"type": "boolean"}},
+          {"name": "program", "in": "query", "description": "DMS program as titled in the source document.", "schema": {"type": "string"}},
+          {"name": "category", "in": "query", "description": "Category, e.g. dental, polyclinic, hospital, lab or pediatric.", "schema": {"type": "string"}},
+          {"name": "limit", "in": "query", "description": "Paginate the list ordered by ID. Without limit and cursor the whole list is returned.", "schema": {"type": "integer", "minimum": 1, "maximum": 1000, "default": 100}},
+          {"$ref": "#/components/parameters/cursor"}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/ClinicsPage"},
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/clinics/nearest": {
+      "get": {
+        "operationId": "nearestClinics",
+        "summary": "List geocoded clinics nearest to the point, closest first.",
+        "parameters": [
+          {"$ref": "#/components/parameters/lat"},
+          {"$ref": "#/components/parameters/lon"},
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 100, "default": 10}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/ClinicDistances"},
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/clinics/within": {
+      "get": {
+        "operationId": "clinicsWithin",
+        "summary": "List geocoded clinics within the radius of the point, closest first, or within the bounding box.",
+        "parameters": [
+          {"name": "lat", "in": "query", "schema": {"type": "number", "minimum": -90, "maximum": 90}},
+          {"name": "lon", "in": "query", "schema": {"type": "number", "minimum": -180, "maximum": 180}},
+          {"name": "radius_m", "in": "query", "schema": {"type": "number", "exclusiveMinimum": 0, "maximum": 100000}},
+          {"name": "bbox", "in": "query", "description": "minLat,minLon,maxLat,maxLon; takes precedence over the point and radius.", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/ClinicDistances"},
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/clinics/search": {
+      "get": {
+        "operationId": "searchClinics",
+        "summary": "Search clinics by name and address, tolerating typos; best matches first.",
+        "parameters": [
+          {"name": "q", "in": "query", "required": true, "schema": {"type": "string"}},
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 100, "default": 20}},
+          {"$ref": "#/components/parameters/cursor"}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/ClinicsPage"},
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/clinics/{id}": {
+      "get": {
+        "operationId": "getClinic",
+        "parameters": [{"$ref": "#/components/parameters/id"}],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Clinic"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/clinics/{id}/geocode": {
+      "post": {
+        "operationId": "geocodeClinic",
+        "summary": "Geocode the clinic again, bypassing the geocoder cache.",
+        "parameters": [{"$ref": "#/components/parameters/id"}],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Clinic"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "422": {"$ref": "#/components/responses/Error"},
+          "502": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/clusters": {
+      "get": {
+        "operationId": "clusterClinics",
+        "summary": "Cluster geocoded clinics within the map viewport at the zoom level.",
+        "parameters": [
+          {"name": "zoom", "in": "query", "required": true, "schema": {"type": "integer", "minimum": 0, "maximum": 23}},
+          {"name": "bbox", "in": "query", "required": true, "description": "minLat,minLon,maxLat,maxLon", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "Clusters.",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Cluster"}}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/graphql": {
+      "get": {
+        "operationId": "graphqlGet",
+        "summary": "GraphQL query, see /graphql/schema.",
+        "parameters": [
+          {"name": "query", "in": "query", "required": true, "schema": {"type": "string"}},
+          {"name": "variables", "in": "query", "description": "JSON object.", "schema": {"type": "string"}}
+        ],
+        "responses": {"200": {"$ref": "#/components/responses/GraphQL"}}
+      },
+      "post": {
+        "operationId": "graphqlPost",
+        "summary": "GraphQL query, see /graphql/schema.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["query"],
+                "properties": {
+                  "query": {"type": "string"},
+                  "variables": {"type": "object"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {"200": {"$ref": "#/components/responses/GraphQL"}}
+      }
+    },
+    "/graphql/schema": {
+      "get": {
+        "operationId": "graphqlSchema",
+        "responses": {
+          "200": {"description": "GraphQL schema.", "content": {"text/plain": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/review": {
+      "get": {
+        "operationId": "reviewPage",
+        "security": [{}],
+        "summary": "Web UI for reviewing clinics, that failed geocoding or have low precision.",
+        "responses": {
+          "200": {"description": "HTML page.", "content": {"text/html": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/review/clinics": {
+      "get": {
+        "operationId": "listReviewClinics",
+        "summary": "List clinics, that need review.",
+        "responses": {"200": {"$ref": "#/components/responses/Clinics"}}
+      }
+    },
+    "/review/clinics/{id}": {
+      "post": {
+        "operationId": "fixClinicPoint",
+        "summary": "Set the clinic's point and save it to the overrides file.",
+        "parameters": [{"$ref": "#/components/parameters/id"}],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["lat", "lon"],
+                "properties": {
+                  "lat": {"type": "number", "minimum": -90, "maximum": 90},
+                  "lon": {"type": "number", "minimum": -180, "maximum": 180}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"$ref": "#/components/responses/Clinic"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/metrics": {
+      "get": {
+        "operationId": "metrics",
+        "security": [{}],
+        "responses": {
+          "200": {"description": "Prometheus metrics.", "content": {"text/plain": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/healthz": {
+      "get": {
+        "operationId": "healthz",
+        "summary": "Liveness probe.",
+        "security": [{}],
+        "responses": {
+          "200": {"description": "The server is alive.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Health"}}}}
+        }
+      }
+    },
+    "/readyz": {
+      "get": {
+        "operationId": "readyz",
+        "summary": "Readiness probe: the dataset is loaded, the geocoder is reachable and the geocoder cache can be saved.",
+        "security": [{}],
+        "responses": {
+          "200": {"description": "The server is ready.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Health"}}}},
+          "503": {"description": "Some checks failed.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Health"}}}}
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "operationId": "openapi",
+        "security": [{}],
+        "responses": {
+          "200": {"description": "This document.", "content": {"application/json": {"schema": {"type": "object"}}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearer": {
+        "type": "http",
+        "scheme": "bearer",
+        "description": "Static token, required if the server runs with -token. POST requests, except GraphQL queries, need a token of write scope. Missing or invalid token is answered with 401, insufficient scope with 403."
+      }
+    },
+    "parameters": {
+      "id": {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}},
+      "cursor": {"name": "cursor", "in": "query", "description": "Opaque cursor of the page, taken from Link header of the previous page.", "schema": {"type": "string"}},
+      "lat": {"name": "lat", "in": "query", "required": true, "schema": {"type": "number", "minimum": -90, "maximum": 90}},
+      "lon": {"name": "lon", "in": "query", "required": true, "schema": {"type": "number", "minimum": -180, "maximum": 180}}
+    },
+    "responses": {
+      "Clinic": {
+        "description": "Clinic.",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Clinic"}}}
+      },
+      "Clinics": {
+        "description": "Clinics.",
+        "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Clinic"}}}}
+      },
+      "ClinicsPage": {
+        "description": "Page of clinics.",
+        "headers": {
+          "Link": {"description": "URL of the next page as <url>; rel=\"next\", if there is one.", "schema": {"type": "string"}}
+        },
+        "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Clinic"}}}}
+      },
+      "ClinicDistances": {
+        "description": "Clinics with the distance to the point; the distance is omitted for bounding box queries.",
+        "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/ClinicDistance"}}}}
+      },
+      "GraphQL": {
+        "description": "GraphQL response; errors are reported in the errors field.",
+        "content": {
+          "application/json": {
+            "schema": {
+              "type": "object",
+              "properties": {
+                "data": {"type": "object"},
+                "errors": {"type": "array", "items": {"type": "object", "properties": {"message": {"type": "string"}}}}
+              }
+            }
+          }
+        }
+      },
+      "Error": {
+        "description": "Error.",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      }
+    },
+    "schemas": {
+      "Clinic": {
+        "type": "object",
+        "required": ["id", "name", "raw_address", "phone", "points"],
+        "properties": {
+          "id": {"type": "string", "description": "Stable clinic identifier, derived from name and raw address."},
+          "name": {"type": "string"},
+          "raw_address": {"type": "string", "description": "Address as written in the source document."},
+          "phone": {"type": "string", "description": "Comma-separated phone numbers."},
+          "address": {"type": "string", "description": "Address normalized by geocoder."},
+          "city": {"type": "string"},
+          "categories": {"type": "array", "items": {"type": "string"}, "description": "Categories by name, e.g. dental or pediatric."},
+          "programs": {"type": "array", "items": {"type": "string"}, "description": "DMS programs, that cover the clinic."},
+          "precision": {"type": "string", "description": "Geocoder precision, e.g. exact, number, street, or manual."},
+          "confidence": {"type": "number", "minimum": 0, "maximum": 1, "description": "How much the points can be trusted; omitted if clinic wasn't geocoded."},
+          "points": {
+            "description": "Latitude and longitude; null if clinic wasn't geocoded.",
+            "oneOf": [
+              {"type": "null"},
+              {"type": "array", "items": {"type": "number"}, "minItems": 2, "maxItems": 2}
+            ]
+          }
+        }
+      },
+      "ClinicDistance": {
+        "allOf": [
+          {"$ref": "#/components/schemas/Clinic"},
+          {"type": "object", "properties": {"distance_m": {"type": "number", "description": "Distance to the point, in meters."}}}
+        ]
+      },
+      "Cluster": {
+        "type": "object",
+        "required": ["lat", "lon", "count", "bbox"],
+        "properties": {
+          "lat": {"type": "number"},
+          "lon": {"type": "number"},
+          "count": {"type": "integer", "minimum": 1},
+          "id": {"type": "string", "description": "ID of the clinic, if the cluster is a single clinic."},
+          "bbox": {"type": "array", "items": {"type": "number"}, "minItems": 4, "maxItems": 4, "description": "minLat, minLon, maxLat, maxLon of the clinics."}
+        }
+      },
+      "Health": {
+        "type": "object",
+        "required": ["status"],
+        "properties": {
+          "status": {"type": "string", "enum": ["ok", "unavailable"]},
+          "checks": {"type": "object", "additionalProperties": {"type": "string"}, "description": "Result of each check: ok or the error."}
+        }
+      },
+      "Error": {
+        "type": "object",
+        "required": ["error"],
+        "properties": {"error": {"type": "string"}}
+      }
+    }
+  }
+}
+`
+
+// openAPIRoutes are the routes, described in openAPISpec, as "METHOD /path" patterns of http.ServeMux.
+var openAPIRoutes = func() map[string]bool {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(openAPISpec), &spec); err != nil {
+		panic("invalid openapi spec: " + err.Error())
+	}
+	routes := make(map[string]bool)
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			routes[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	return routes
+}()
+
+// openAPIMux is http.ServeMux, that accepts only routes described in openAPISpec, so the handlers
+// and the document can't drift apart unnoticed.
+type openAPIMux struct {
+	*http.ServeMux
+}
+
+func (m openAPIMux) Handle(pattern string, h http.Handler) {
+	if !openAPIRoutes[pattern] {
+		panic(fmt.Sprintf("route %q is missing in openapi spec", pattern))
+	}
+	m.ServeMux.Handle(pattern, h)
+}
+
+func (m openAPIMux) HandleFunc(pattern string, h func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(h))
+}
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	io.WriteString(w, openAPISpec)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/export"
+	"github.com/narqo/vtb-dms/geocode"
+)
+
+// overridePrecision is the precision of points, that were set by a human.
+const overridePrecision = geocode.ManualPrecision
+
+// overrides are manually fixed locations and fields of clinics. They're kept in a yaml file in the
+// dataset format, or in a CSV file with id, raw_address, name, phone, address, city, district, precision,
+// lat and lon columns, so the file can be edited by hand too. An override is keyed by clinic ID or, if
+// it has no ID, by the normalized raw address, so it still applies, when the clinic's name changes.
+// Overridden points and non-empty fields take precedence over geocoding results.
+type overrides struct {
+	path string
+
+	mu        sync.Mutex
+	clinics   []*dmsparse.Clinic
+	byID      map[string]*dmsparse.Clinic
+	byAddress map[string]*dmsparse.Clinic
+}
+
+// loadOverrides reads overrides from the file at path. A missing file is no overrides.
+func loadOverrides(path string) (*overrides, error) {
+	o := &overrides{
+		path:      path,
+		byID:      make(map[string]*dmsparse.Clinic),
+		byAddress: make(map[string]*dmsparse.Clinic),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return o, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if o.isCSV() {
+		o.clinics, err = readOverridesCSV(bytes.NewReader(data))
+	} else {
+		o.clinics, err = export.ReadYAML(bytes.NewReader(data))
+		for _, cc := range o.clinics {
+			// ReadYAML fills in missing IDs, an override without name and ID is keyed by address
+			if cc.Name == "" && cc.ID == dmsparse.ClinicID(cc) {
+				cc.ID = ""
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("overrides %s: %v", path, err)
+	}
+	for _, cc := range o.clinics {
+		o.index(cc)
+	}
+	return o, nil
+}
+
+func (o *overrides) isCSV() bool {
+	return strings.EqualFold(filepath.Ext(o.path), ".csv")
+}
+
+func (o *overrides) index(ov *dmsparse.Clinic) {
+	if ov.ID != "" {
+		o.byID[ov.ID] = ov
+	} else if ov.RawAddress != "" {
+		o.byAddress[dmsparse.AddressKey(ov.RawAddress)] = ov
+	}
+}
+
+// lookup returns the override of the clinic. The caller must hold o.mu.
+func (o *overrides) lookup(cc *dmsparse.Clinic) (*dmsparse.Clinic, bool) {
+	if ov, ok := o.byID[cc.ID]; ok {
+		return ov, true
+	}
+	ov, ok := o.byAddress[dmsparse.AddressKey(cc.RawAddress)]
+	return ov, ok
+}
+
+// Len returns the number of overrides.
+func (o *overrides) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.clinics)
+}
+
+// Apply replaces locations and fields of the clinics with the overridden ones. It returns the number
+// of overridden clinics.
+func (o *overrides) Apply(clinics []*dmsparse.Clinic) (n int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, cc := range clinics {
+		if ov, ok := o.lookup(cc); ok {
+			applyOverride(cc, ov)
+			n++
+		}
+	}
+	return n
+}
+
+func applyOverride(cc, ov *dmsparse.Clinic) {
+	if _, _, ok := ov.LatLon(); ok {
+		cc.Points = ov.Points
+		cc.Precision = ov.Precision
+		if cc.Precision == "" {
+			cc.Precision = overridePrecision
+		}
+		cc.Confidence = geocode.Confidence(cc, nil)
+	}
+	if ov.Name != "" {
+		cc.Name = ov.Name
+	}
+	if ov.Phone != "" {
+		cc.Phone = ov.Phone
+	}
+	if ov.Address != "" {
+		cc.Address = ov.Address
+	}
+	if ov.City != "" {
+		cc.City = ov.City
+	}
+	if ov.District != "" {
+		cc.District = ov.District
+	}
+}
+
+// Set overrides the point of the clinic and saves the overrides to the file.
+func (o *overrides) Set(cc *dmsparse.Clinic, lat, lon float64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	ov, ok := o.lookup(cc)
+	if !ok {
+		// the name isn't kept, as it would override the name from the source
+		ov = &dmsparse.Clinic{
+			ID:         cc.ID,
+			RawAddress: cc.RawAddress,
+		}
+		o.clinics = append(o.clinics, ov)
+		o.index(ov)
+	}
+	ov.Points = []float64{lat, lon}
+	ov.Precision = overridePrecision
+
+	var (
+		buf bytes.Buffer
+		err error
+	)
+	if o.isCSV() {
+		err = writeOverridesCSV(&buf, o.clinics)
+	} else {
+		err = export.WriteYAML(&buf, o.clinics)
+	}
+	if err != nil {
+		return err
+	}
+	tmp := o.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.path)
+}
+
+var overridesCSVHeader = []string{"id", "raw_address", "name", "phone", "address", "city", "district", "precision", "lat", "lon"}
+
+// readOverridesCSV reads overrides from CSV with a header. Columns may go in any order and
+// may be omitted, but either id or raw_address column is required.
+func readOverridesCSV(r io.Reader) ([]*dmsparse.Clinic, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	cols := make(map[string]int)
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	_, hasID := cols["id"]
+	_, hasAddr := cols["raw_address"]
+	if !hasID && !hasAddr {
+		return nil, fmt.Errorf("csv must have id or raw_address column")
+	}
+
+	var (
+		clinics []*dmsparse.Clinic
+		errs    = &export.SchemaError{}
+	)
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if pe, ok := err.(*csv.ParseError); ok {
+			errs.Add(pe.Line, "", "%v", pe.Err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		get := func(col string) string {
+			if i, ok := cols[col]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		cc := &dmsparse.Clinic{
+			ID:         get("id"),
+			RawAddress: get("raw_address"),
+			Name:       get("name"),
+			Phone:      get("phone"),
+			Address:    get("address"),
+			City:       get("city"),
+			District:   get("district"),
+			Precision:  get("precision"),
+		}
+		if cc.ID == "" && cc.RawAddress == "" {
+			errs.Add(line, "id", "no id or raw_address")
+			continue
+		}
+		if lat, lon := get("lat"), get("lon"); lat != "" || lon != "" {
+			la, err1 := strconv.ParseFloat(lat, 64)
+			lo, err2 := strconv.ParseFloat(lon, 64)
+			if err1 != nil || err2 != nil || la < -90 || la > 90 || lo < -180 || lo > 180 {
+				errs.Add(line, "lat", "invalid point %q, %q", lat, lon)
+				continue
+			}
+			cc.Points = []float64{la, lo}
+		}
+		clinics = append(clinics, cc)
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return clinics, nil
+}
+
+func writeOverridesCSV(w io.Writer, clinics []*dmsparse.Clinic) error {
+	cw := csv.NewWriter(w)
+	cw.Write(overridesCSVHeader)
+	for _, cc := range clinics {
+		var lat, lon string
+		if la, lo, ok := cc.LatLon(); ok {
+			lat, lon = strconv.FormatFloat(la, 'f', -1, 64), strconv.FormatFloat(lo, 'f', -1, 64)
+		}
+		cw.Write([]string{cc.ID, cc.RawAddress, cc.Name, cc.Phone, cc.Address, cc.City, cc.District, cc.Precision, lat, lon})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+func TestOverridesCSVRoundTrip(t *testing.T) {
+	want := []*dmsparse.Clinic{
+		{
+			ID:         "a",
+			RawAddress: "г. Москва, ул. Новая, 1",
+			Name:       "Клиника",
+			Phone:      "8 (495) 000-00-00",
+			Address:    "Россия, Москва, улица Новая, 1",
+			City:       "Москва",
+			District:   "Центральный административный округ",
+			Precision:  "manual",
+			Points:     []float64{55.75, 37.61},
+		},
+		{RawAddress: "г. Химки, ул. Старая, 2", District: "Левобережный"},
+	}
+	var buf bytes.Buffer
+	if err := writeOverridesCSV(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readOverridesCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip: got %+v, want %+v", got, want)
+	}
+}
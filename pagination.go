@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// maxPageLimit limits the number of clinics in a page of the list.
+const maxPageLimit = 1000
+
+// defaultPageLimit is the page size, if the request has a cursor, but no limit.
+const defaultPageLimit = 100
+
+// pageQuery returns ?limit= and ?cursor= of the request. The cursor is opaque to clients:
+// they pass the one from the Link header of the previous page.
+func pageQuery(r *http.Request, defLimit, maxLimit int) (limit int, cursor string, err error) {
+	q := r.URL.Query()
+	limit = defLimit
+	if v := q.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return 0, "", fmt.Errorf("limit must be from 1 to %d", maxLimit)
+		}
+	}
+	if v := q.Get("cursor"); v != "" {
+		data, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil || len(data) == 0 {
+			return 0, "", fmt.Errorf("invalid cursor")
+		}
+		cursor = string(data)
+	}
+	return limit, cursor, nil
+}
+
+// setNextPage sets Link header to the URL of the next page, that starts after cursor.
+func setNextPage(w http.ResponseWriter, r *http.Request, cursor string) {
+	u := *r.URL
+	q := u.Query()
+	q.Set("cursor", base64.RawURLEncoding.EncodeToString([]byte(cursor)))
+	u.RawQuery = q.Encode()
+	w.Header().Set("Link", "<"+u.RequestURI()+`>; rel="next"`)
+}
+
+// pageByID returns up to limit clinics with IDs greater than after, ordered by ID, so pages
+// stay stable, even if the dataset changes between requests. The cursor of the next page
+// is empty, if it's the last page.
+func pageByID(clinics []*dmsparse.Clinic, after string, limit int) (page []*dmsparse.Clinic, next string) {
+	sorted := make([]*dmsparse.Clinic, len(clinics))
+	copy(sorted, clinics)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+	i := sort.Search(len(sorted), func(i int) bool {
+		return sorted[i].ID > after
+	})
+	page = sorted[i:]
+	if len(page) > limit {
+		page = page[:limit]
+		next = page[limit-1].ID
+	}
+	return page, next
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressLogInterval is how often progress is logged, when it isn't drawn as a bar.
+const progressLogInterval = 10 * time.Second
+
+// progress reports how many clinics of total were processed. On a terminal it draws a progress bar,
+// otherwise it periodically logs progress lines. A nil progress reports nothing.
+type progress struct {
+	mu      sync.Mutex
+	w       io.Writer
+	bar     bool
+	total   int
+	done    int
+	failed  int
+	start   time.Time
+	lastLog time.Time
+}
+
+// newProgress creates a progress of total clinics. Mode is one of "auto", "bar", "log" or "none";
+// in "auto" mode the bar is drawn only if stderr is a terminal. If total is unknown, i.e. negative,
+// progress is only logged.
+func newProgress(mode string, total int) (*progress, error) {
+	p := &progress{w: os.Stderr, total: total, start: time.Now()}
+	p.lastLog = p.start
+	switch mode {
+	case "auto":
+		p.bar = isTerminal(os.Stderr)
+	case "bar":
+		p.bar = true
+	case "log":
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown progress mode: %q", mode)
+	}
+	if total == 0 {
+		return nil, nil
+	}
+	if total < 0 {
+		p.bar = false
+	}
+	return p, nil
+}
+
+// Add records a processed clinic.
+func (p *progress) Add(ok bool) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done++
+	if !ok {
+		p.failed++
+	}
+	if p.bar {
+		p.draw()
+		return
+	}
+	if now := time.Now(); now.Sub(p.lastLog) >= progressLogInterval {
+		p.lastLog = now
+		if p.total < 0 {
+			slog.Info("progress", "done", p.done, "failed", p.failed)
+			return
+		}
+		slog.Info("progress", "done", p.done, "total", p.total, "failed", p.failed, "eta", p.eta().Round(time.Second))
+	}
+}
+
+// Finish completes the progress bar, so the following output starts from a new line.
+func (p *progress) Finish() {
+	if p == nil || !p.bar {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintln(p.w)
+}
+
+// eta estimates the time left from the average speed so far.
+func (p *progress) eta() time.Duration {
+	if p.done == 0 {
+		return 0
+	}
+	elapsed := time.Since(p.start)
+	return elapsed / time.Duration(p.done) * time.Duration(p.total-p.done)
+}
+
+func (p *progress) draw() {
+	const width = 30
+	n := width * p.done / p.total
+	fmt.Fprintf(p.w, "\r[%s%s] %d/%d, failed %d, eta %s ",
+		strings.Repeat("=", n), strings.Repeat(" ", width-n), p.done, p.total, p.failed, p.eta().Round(time.Second))
+}
+
+// isTerminal reports whether f is a character device, e.g. a terminal, rather than a file or a pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// needsReview reports whether clinic failed geocoding, or its point wasn't matched precisely to the building.
+func needsReview(cc *dmsparse.Clinic) bool {
+	if _, _, ok := cc.LatLon(); !ok {
+		return true
+	}
+	switch cc.Precision {
+	case "exact", "number", overridePrecision:
+		return false
+	}
+	return true
+}
+
+func (s *server) handleReviewPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, reviewPage)
+}
+
+func (s *server) handleReviewList(w http.ResponseWriter, r *http.Request) {
+	clinics := []*dmsparse.Clinic{}
+	for _, cc := range s.data.Load().clinics {
+		if needsReview(cc) {
+			clinics = append(clinics, cc)
+		}
+	}
+	writeJSONResponse(w, http.StatusOK, clinics)
+}
+
+// handleReviewFix accepts the point, picked by a reviewer, and saves it to the overrides.
+func (s *server) handleReviewFix(w http.ResponseWriter, r *http.Request) {
+	if s.overrides == nil {
+		writeErrorResponse(w, http.StatusConflict, "server runs without -overrides file, fixes can't be saved")
+		return
+	}
+	var req struct {
+		Lat *float64 `json:"lat"`
+		Lon *float64 `json:"lon"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Lat == nil || req.Lon == nil {
+		writeErrorResponse(w, http.StatusBadRequest, `expected {"lat": .., "lon": ..}`)
+		return
+	}
+	if *req.Lat < -90 || *req.Lat > 90 || *req.Lon < -180 || *req.Lon > 180 {
+		writeErrorResponse(w, http.StatusBadRequest, "point is out of range")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.data.Load().byID[r.PathValue("id")]
+	if !ok {
+		writeErrorResponse(w, http.StatusNotFound, "clinic not found")
+		return
+	}
+	if err := s.overrides.Set(old, *req.Lat, *req.Lon); err != nil {
+		slog.Error("could not save overrides", "err", err)
+		writeErrorResponse(w, http.StatusInternalServerError, "could not save overrides")
+		return
+	}
+	slog.Info("clinic point fixed in review", "id", old.ID, "lat", *req.Lat, "lon", *req.Lon)
+
+	cc := *old
+	cc.Points = []float64{*req.Lat, *req.Lon}
+	cc.Precision = overridePrecision
+	cc.Confidence = 1
+	// the lock is held since old was loaded, so the dataset couldn't change
+	if err := s.replace(old, &cc); err != nil {
+		writeErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, &cc)
+}
+
+// reviewPage lists clinics to review. Selecting a clinic shows its current point and the candidates,
+// geocoded in the browser; the reviewer picks a candidate, or clicks the map, and accepts the point.
+const reviewPage = `<!DOCTYPE html>
+<html>
+<head>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <meta http-equiv="Content-Type" content="text/html; charset=utf-8" />
+    <title>Geocoding review</title>
+    <script src="https://api-maps.yandex.ru/2.1/?lang=ru_RU" type="text/javascript"></script>
+    <style>
+        html, body { height: 100%; margin: 0; font: 14px sans-serif; }
+        body { display: flex; }
+        #list { width: 360px; overflow-y: auto; border-right: 1px solid #ccc; }
+        #list div { padding: 8px; border-bottom: 1px solid #eee; cursor: pointer; }
+        #list div.active { background: #def; }
+        #list small { color: #777; }
+        #main { flex: 1; display: flex; flex-direction: column; }
+        #map { flex: 1; }
+        #bar { padding: 8px; }
+    </style>
+</head>
+<body>
+    <div id="list"></div>
+    <div id="main">
+        <div id="bar"><span id="info">Select a clinic</span> <button id="accept" disabled>Accept point</button></div>
+        <div id="map"></div>
+    </div>
+    <script>
+        let clinics = [], current = null, picked = null;
+        const list = document.getElementById('list');
+        const info = document.getElementById('info');
+        const accept = document.getElementById('accept');
+
+        // api calls the API with the token, asked once and kept in localStorage, if the server requires one.
+        async function api(path, opts = {}) {
+            for (;;) {
+                const token = localStorage.getItem('token');
+                const headers = Object.assign({}, opts.headers, token ? {'Authorization': 'Bearer ' + token} : {});
+                const resp = await fetch(path, Object.assign({}, opts, {headers}));
+                if (resp.status !== 401 && resp.status !== 403) {
+                    return resp;
+                }
+                const t = prompt('API token with write scope');
+                if (!t) {
+                    return resp;
+                }
+                localStorage.setItem('token', t);
+            }
+        }
+
+        function escape(s) {
+            return (s || '').replace(/[&<>"]/g, c => ({'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;'}[c]));
+        }
+
+        ymaps.ready(async () => {
+            const map = new ymaps.Map('map', {center: [55.751574, 37.573856], zoom: 10});
+            const pick = new ymaps.Placemark([0, 0], {}, {preset: 'islands#redDotIcon', draggable: true, visible: false});
+            map.geoObjects.add(pick);
+
+            function setPicked(coords) {
+                picked = coords;
+                pick.geometry.setCoordinates(coords);
+                pick.options.set('visible', true);
+                accept.disabled = false;
+                info.textContent = (current ? current.name + ': ' : '') + coords.map(c => c.toFixed(6)).join(', ');
+            }
+            pick.events.add('dragend', () => setPicked(pick.geometry.getCoordinates()));
+            map.events.add('click', e => current && setPicked(e.get('coords')));
+
+            const candidates = new ymaps.GeoObjectCollection({}, {preset: 'islands#blueDotIcon'});
+            map.geoObjects.add(candidates);
+
+            async function select(cc, el) {
+                current = cc;
+                picked = null;
+                accept.disabled = true;
+                pick.options.set('visible', false);
+                candidates.removeAll();
+                list.querySelectorAll('.active').forEach(e => e.classList.remove('active'));
+                el.classList.add('active');
+                info.textContent = cc.name + ': pick a candidate or click the map';
+
+                if (cc.points && cc.points.length === 2) {
+                    setPicked(cc.points);
+                    map.setCenter(cc.points, 16);
+                }
+                const res = await ymaps.geocode(cc.raw_address, {results: 5});
+                res.geoObjects.each(obj => {
+                    const coords = obj.geometry.getCoordinates();
+                    const p = new ymaps.Placemark(coords, {hintContent: obj.getAddressLine()});
+                    p.events.add('click', () => setPicked(coords));
+                    candidates.add(p);
+                });
+                if (!picked && candidates.getLength() > 0) {
+                    map.setBounds(candidates.getBounds(), {checkZoomRange: true, zoomMargin: 40});
+                }
+            }
+
+            accept.onclick = async () => {
+                const resp = await api('review/clinics/' + encodeURIComponent(current.id), {
+                    method: 'POST',
+                    headers: {'Content-Type': 'application/json'},
+                    body: JSON.stringify({lat: picked[0], lon: picked[1]}),
+                });
+                if (!resp.ok) {
+                    alert((await resp.json()).error);
+                    return;
+                }
+                list.querySelector('.active').remove();
+                clinics = clinics.filter(cc => cc !== current);
+                current = null;
+                accept.disabled = true;
+                info.textContent = 'Saved. Select the next clinic';
+            };
+
+            clinics = await (await api('review/clinics')).json();
+            clinics.forEach(cc => {
+                const el = document.createElement('div');
+                el.innerHTML = '<strong>' + escape(cc.name) + '</strong><br>' + escape(cc.raw_address) +
+                    '<br><small>' + escape(cc.precision || 'not geocoded') + '</small>';
+                el.onclick = () => select(cc, el);
+                list.appendChild(el);
+            });
+            if (clinics.length === 0) {
+                info.textContent = 'Nothing to review';
+            }
+        });
+    </script>
+</body>
+</html>
+`
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule is a cron-like schedule. It's either a standard 5-field cron expression
+// "minute hour day-of-month month day-of-week", where each field is "*", a number, a range "1-5",
+// a step "*/15" or "1-30/5", or a comma-separated list of those; or "@every <duration>",
+// "@hourly", "@daily" and "@weekly".
+type schedule struct {
+	every time.Duration
+	// fields are the allowed values of minute, hour, day of month, month and day of week.
+	fields [5]map[int]bool
+	// anyDom and anyDow are set if day of month or day of week is "*". As in cron, if both
+	// days are restricted, a time matches if either of them matches.
+	anyDom, anyDow bool
+}
+
+var cronFieldRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+// scheduleHorizon is how far ahead Next looks for a matching time. Leap days may be 8 years apart,
+// e.g. from 2096 to 2104.
+const scheduleHorizon = 8
+
+// parseSchedule parses the schedule. Schedules, that never match, e.g. "0 0 31 2 *", are rejected.
+
+func parseSchedule(s string) (*schedule, error) {
+	switch s {
+	case "@hourly":
+		s = "0 * * * *"
+	case "@daily":
+		s = "0 0 * * *"
+	case "@weekly":
+		s = "0 0 * * 0"
+	}
+	if d, ok := strings.CutPrefix(s, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || every <= 0 {
+			return nil, fmt.Errorf("invalid schedule %q", s)
+		}
+		return &schedule{every: every}, nil
+	}
+
+	parts := strings.Fields(s)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields", s)
+	}
+	sched := &schedule{
+		anyDom: parts[2] == "*",
+		anyDow: parts[4] == "*",
+	}
+	for i, part := range parts {
+		field, err := parseCronField(part, cronFieldRanges[i][0], cronFieldRanges[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", s, err)
+		}
+		sched.fields[i] = field
+	}
+	if sched.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("invalid schedule %q: no time within %d years matches it", s, scheduleHorizon)
+	}
+	return sched, nil
+}
+
+func parseCronField(s string, min, max int) (map[int]bool, error) {
+	field := make(map[int]bool)
+	for _, item := range strings.Split(s, ",") {
+		rng, step := item, 1
+		if r, st, ok := strings.Cut(item, "/"); ok {
+			n, err := strconv.Atoi(st)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("bad step in %q", item)
+			}
+			rng, step = r, n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return nil, fmt.Errorf("bad value in %q", item)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return nil, fmt.Errorf("bad range in %q", item)
+				}
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%q is out of range %d-%d", item, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			field[v] = true
+		}
+	}
+	return field, nil
+}
+
+// Next returns the first time after t, that matches the schedule, or zero time, if no time within
+// scheduleHorizon years does.
+func (s *schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(scheduleHorizon, 0, 0); t.Before(end); {
+		if !s.fields[3][int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.fields[1][t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.fields[0][t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *schedule) matchDay(t time.Time) bool {
+	dom, dow := s.fields[2][t.Day()], s.fields[4][int(t.Weekday())]
+	switch {
+	case s.anyDom && s.anyDow:
+		return true
+	case s.anyDom:
+		return dow
+	case s.anyDow:
+		return dom
+	}
+	return dom || dow
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScheduleNeverMatches(t *testing.T) {
+	for _, s := range []string{"0 0 31 2 *", "0 0 30 2 *", "0 0 31 4,6,9,11 *"} {
+		_, err := parseSchedule(s)
+		if err == nil || !strings.Contains(err.Error(), "no time within") {
+			t.Errorf("parseSchedule(%q) error = %v, want no matching time", s, err)
+		}
+	}
+	// leap days and days, restricted along with days of week, do match
+	for _, s := range []string{"0 0 29 2 *", "0 0 31 2 1", "0 0 31 * *"} {
+		if _, err := parseSchedule(s); err != nil {
+			t.Errorf("parseSchedule(%q): %v", s, err)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2097, 3, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		sched string
+		want  time.Time
+	}{
+		{"@every 90m", from.Add(90 * time.Minute)},
+		{"*/15 * * * *", time.Date(2097, 3, 1, 10, 45, 0, 0, time.UTC)},
+		{"@daily", time.Date(2097, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 3 * * 1-5", time.Date(2097, 3, 4, 3, 0, 0, 0, time.UTC)},
+		// 2100 isn't a leap year
+		{"0 0 29 2 *", time.Date(2104, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		sched, err := parseSchedule(tt.sched)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sched.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Next() of %q = %v, want %v", tt.sched, got, tt.want)
+		}
+	}
+
+	sched := &schedule{anyDow: true}
+	sched.fields[0], sched.fields[1] = map[int]bool{0: true}, map[int]bool{0: true}
+	sched.fields[2], sched.fields[3] = map[int]bool{31: true}, map[int]bool{2: true}
+	if got := sched.Next(from); !got.IsZero() {
+		t.Errorf("Next() of Feb 31 = %v, want zero time", got)
+	}
+}
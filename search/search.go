@@ -0,0 +1,208 @@
+// Package search implements full-text search over clinic names and addresses, tolerant to
+// incomplete words and typos, e.g. "стоматолог марьино" finds "Стоматологическая клиника" in Марьино.
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// Weights of the fields, in which a word was found.
+const (
+	nameWeight    = 2
+	addressWeight = 1
+)
+
+// Weights of the ways a query word matches a word of a clinic.
+const (
+	exactMatch  = 3
+	prefixMatch = 2
+	fuzzyMatch  = 1
+)
+
+// Index is an inverted index of words of clinic names and addresses.
+type Index struct {
+	clinics []*dmsparse.Clinic
+	// postings are the clinics and the weights of the field, that contain the word.
+	postings map[string][]posting
+	// words are the indexed words in sorted order, to look up words by prefix.
+	words []string
+}
+
+type posting struct {
+	clinic int
+	weight int
+}
+
+// Hit is a clinic found by a query.
+type Hit struct {
+	Clinic *dmsparse.Clinic
+	Score  int
+}
+
+// New indexes clinics.
+func New(clinics []*dmsparse.Clinic) *Index {
+	ix := &Index{
+		clinics:  clinics,
+		postings: make(map[string][]posting),
+	}
+	for i, cc := range clinics {
+		weights := make(map[string]int)
+		for _, w := range Tokenize(cc.Name) {
+			weights[w] = nameWeight
+		}
+		for _, text := range []string{cc.RawAddress, cc.Address} {
+			for _, w := range Tokenize(text) {
+				if weights[w] < addressWeight {
+					weights[w] = addressWeight
+				}
+			}
+		}
+		for w, weight := range weights {
+			ix.postings[w] = append(ix.postings[w], posting{i, weight})
+		}
+	}
+	ix.words = make([]string, 0, len(ix.postings))
+	for w := range ix.postings {
+		ix.words = append(ix.words, w)
+	}
+	sort.Strings(ix.words)
+	return ix
+}
+
+// Search returns up to limit clinics, that match every word of the query, with the best matches first.
+// A query word matches a word of a clinic exactly, as its prefix, or with a typo: one edit for words
+// of 4 to 7 letters and two edits for longer words.
+func (ix *Index) Search(query string, limit int) []Hit {
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var scores map[int]int
+	for _, term := range terms {
+		// the best score of the term in each clinic
+		termScores := make(map[int]int)
+		for w, match := range ix.matches(term) {
+			for _, p := range ix.postings[w] {
+				if s := match * p.weight; s > termScores[p.clinic] {
+					termScores[p.clinic] = s
+				}
+			}
+		}
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for i, s := range scores {
+			if ts, ok := termScores[i]; ok {
+				scores[i] = s + ts
+			} else {
+				delete(scores, i)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for i, s := range scores {
+		hits = append(hits, Hit{ix.clinics[i], s})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Clinic.Name != hits[j].Clinic.Name {
+			return hits[i].Clinic.Name < hits[j].Clinic.Name
+		}
+		return hits[i].Clinic.ID < hits[j].Clinic.ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// matches returns indexed words, that match the term, with the weights of the matches.
+func (ix *Index) matches(term string) map[string]int {
+	m := make(map[string]int)
+	if _, ok := ix.postings[term]; ok {
+		m[term] = exactMatch
+	}
+	for i := sort.SearchStrings(ix.words, term); i < len(ix.words) && strings.HasPrefix(ix.words[i], term); i++ {
+		if ix.words[i] != term {
+			m[ix.words[i]] = prefixMatch
+		}
+	}
+
+	maxEdits := 0
+	switch n := len([]rune(term)); {
+	case n >= 8:
+		maxEdits = 2
+	case n >= 4:
+		maxEdits = 1
+	}
+	if maxEdits == 0 {
+		return m
+	}
+	for _, w := range ix.words {
+		if _, ok := m[w]; ok {
+			continue
+		}
+		// compare with the prefix of the word of the term's length too, so typos in incomplete words are tolerated
+		if withinEdits(term, w, maxEdits) || withinEdits(term, runePrefix(w, len([]rune(term))), maxEdits) {
+			m[w] = fuzzyMatch
+		}
+	}
+	return m
+}
+
+func runePrefix(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// withinEdits reports whether Levenshtein distance between a and b is at most max.
+func withinEdits(a, b string, max int) bool {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > max || -d > max {
+		return false
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > max {
+			return false
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)] <= max
+}
+
+// Tokenize splits text into lower-case words, with "ё" replaced by "е".
+func Tokenize(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,214 @@
+// Package spatial indexes points on the Earth's surface for nearest-neighbour and radius queries.
+package spatial
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+const earthRadius = 6371000 // meters
+
+// Index is a static k-d tree over points on the sphere. Points are indexed by their 3D coordinates
+// on the unit sphere, where the straight-line (chord) distance grows monotonically with the
+// great-circle distance, so the tree answers queries exactly without the special cases of
+// latitude/longitude, e.g. near the poles or the antimeridian.
+type Index struct {
+	// items are the points laid out as implicit balanced tree: the root of items[lo:hi] is
+	// at the middle of the range, its subtrees to the left and to the right of it.
+	items []item
+	// pos are the positions of items by ID.
+	pos []int
+}
+
+type item struct {
+	xyz      [3]float64
+	lat, lon float64
+	// ID is the caller's identifier of the point, e.g. its index in a slice.
+	ID int
+}
+
+// Hit is a point found by a query.
+type Hit struct {
+	ID int
+	// Distance is the great-circle distance to the point in meters.
+	Distance float64
+}
+
+// New builds an index of points, given as lat, lon pairs in degrees. The IDs of the points
+// in query results are their indexes in lats and lons.
+func New(lats, lons []float64) *Index {
+	ix := &Index{items: make([]item, len(lats))}
+	for i := range lats {
+		ix.items[i] = item{xyz: toXYZ(lats[i], lons[i]), lat: lats[i], lon: lons[i], ID: i}
+	}
+	build(ix.items, 0)
+	ix.pos = make([]int, len(ix.items))
+	for i, it := range ix.items {
+		ix.pos[it.ID] = i
+	}
+	return ix
+}
+
+// byID returns the indexed item by its ID.
+func (ix *Index) byID(id int) item {
+	return ix.items[ix.pos[id]]
+}
+
+// Len returns the number of indexed points.
+func (ix *Index) Len() int {
+	return len(ix.items)
+}
+
+func build(items []item, axis int) {
+	if len(items) <= 1 {
+		return
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].xyz[axis] < items[j].xyz[axis] })
+	mid := len(items) / 2
+	build(items[:mid], (axis+1)%3)
+	build(items[mid+1:], (axis+1)%3)
+}
+
+func toXYZ(lat, lon float64) [3]float64 {
+	phi, lambda := lat*math.Pi/180, lon*math.Pi/180
+	return [3]float64{math.Cos(phi) * math.Cos(lambda), math.Cos(phi) * math.Sin(lambda), math.Sin(phi)}
+}
+
+// distance returns great-circle distance between two points in meters.
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	return chordToMeters(chord2(toXYZ(lat1, lon1), toXYZ(lat2, lon2)))
+}
+
+func chord2(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
+
+// chordToMeters converts squared chord distance on the unit sphere into great-circle distance.
+func chordToMeters(c2 float64) float64 {
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(c2)/2))
+}
+
+func metersToChord(m float64) float64 {
+	if m >= math.Pi*earthRadius {
+		return 2
+	}
+	return 2 * math.Sin(m/(2*earthRadius))
+}
+
+// Nearest returns up to k points nearest to lat, lon, sorted by distance.
+func (ix *Index) Nearest(lat, lon float64, k int) []Hit {
+	if k <= 0 {
+		return nil
+	}
+	q := toXYZ(lat, lon)
+	h := &maxHeap{}
+	var search func(items []item, axis int)
+	search = func(items []item, axis int) {
+		if len(items) == 0 {
+			return
+		}
+		mid := len(items) / 2
+		it := items[mid]
+		d := chord2(q, it.xyz)
+		if h.Len() < k {
+			heap.Push(h, candidate{it.ID, d})
+		} else if d < (*h)[0].d {
+			(*h)[0] = candidate{it.ID, d}
+			heap.Fix(h, 0)
+		}
+
+		diff := q[axis] - it.xyz[axis]
+		near, far := items[:mid], items[mid+1:]
+		if diff > 0 {
+			near, far = far, near
+		}
+		next := (axis + 1) % 3
+		search(near, next)
+		if h.Len() < k || diff*diff < (*h)[0].d {
+			search(far, next)
+		}
+	}
+	search(ix.items, 0)
+
+	hits := make([]Hit, h.Len())
+	for i := len(hits) - 1; i >= 0; i-- {
+		c := heap.Pop(h).(candidate)
+		hits[i] = Hit{ID: c.id, Distance: chordToMeters(c.d)}
+	}
+	return hits
+}
+
+// Within returns points within radius meters of lat, lon, sorted by distance.
+func (ix *Index) Within(lat, lon, radius float64) []Hit {
+	q := toXYZ(lat, lon)
+	r := metersToChord(radius)
+	r2 := r * r
+
+	var hits []Hit
+	var search func(items []item, axis int)
+	search = func(items []item, axis int) {
+		if len(items) == 0 {
+			return
+		}
+		mid := len(items) / 2
+		it := items[mid]
+		if d := chord2(q, it.xyz); d <= r2 {
+			hits = append(hits, Hit{ID: it.ID, Distance: chordToMeters(d)})
+		}
+		diff := q[axis] - it.xyz[axis]
+		next := (axis + 1) % 3
+		if diff <= r {
+			search(items[:mid], next)
+		}
+		if diff >= -r {
+			search(items[mid+1:], next)
+		}
+	}
+	search(ix.items, 0)
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Distance < hits[j].Distance })
+	return hits
+}
+
+// InBox returns points within the bounding box, sorted by ID. The box must not cross the antimeridian.
+func (ix *Index) InBox(minLat, minLon, maxLat, maxLon float64) []int {
+	// search the circle around the box's center, that covers the box, then keep the points in the box
+	clat, clon := (minLat+maxLat)/2, (minLon+maxLon)/2
+	var radius float64
+	for _, lat := range []float64{minLat, clat, maxLat} {
+		for _, lon := range []float64{minLon, clon, maxLon} {
+			radius = math.Max(radius, distance(clat, clon, lat, lon))
+		}
+	}
+
+	var ids []int
+	for _, h := range ix.Within(clat, clon, radius*1.001+1) {
+		it := ix.byID(h.ID)
+		if it.lat >= minLat && it.lat <= maxLat && it.lon >= minLon && it.lon <= maxLon {
+			ids = append(ids, h.ID)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+type candidate struct {
+	id int
+	d  float64
+}
+
+// maxHeap keeps the farthest of the candidates found so far on top.
+type maxHeap []candidate
+
+func (h maxHeap) Len() int            { return len(h) }
+func (h maxHeap) Less(i, j int) bool  { return h[i].d > h[j].d }
+func (h maxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *maxHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
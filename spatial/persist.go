@@ -0,0 +1,63 @@
+package spatial
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// indexMagic starts the serialized index, its last byte is the version of the format.
+const indexMagic = "SPIDX\x01"
+
+// WriteTo writes the index in binary format, that ReadIndex reads back without rebuilding the tree.
+func (ix *Index) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, 0, len(indexMagic)+8+len(ix.items)*24)
+	buf = append(buf, indexMagic...)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(ix.items)))
+	for _, it := range ix.items {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(it.lat))
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(it.lon))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(it.ID))
+	}
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadIndex reads the index, written by WriteTo.
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	head := make([]byte, len(indexMagic)+8)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, fmt.Errorf("could not read index: %w", err)
+	}
+	if string(head[:len(indexMagic)]) != indexMagic {
+		return nil, errors.New("not a spatial index or unsupported version")
+	}
+	n := binary.LittleEndian.Uint64(head[len(indexMagic):])
+	if n > math.MaxInt32 {
+		return nil, fmt.Errorf("invalid index size %d", n)
+	}
+
+	ix := &Index{items: make([]item, n), pos: make([]int, n)}
+	for i := range ix.pos {
+		ix.pos[i] = -1
+	}
+	var rec [24]byte
+	for i := range ix.items {
+		if _, err := io.ReadFull(br, rec[:]); err != nil {
+			return nil, fmt.Errorf("could not read index: %w", err)
+		}
+		lat := math.Float64frombits(binary.LittleEndian.Uint64(rec[0:]))
+		lon := math.Float64frombits(binary.LittleEndian.Uint64(rec[8:]))
+		id := binary.LittleEndian.Uint64(rec[16:])
+		if id >= n || ix.pos[id] >= 0 {
+			return nil, fmt.Errorf("invalid index: bad point ID %d", id)
+		}
+		ix.items[i] = item{xyz: toXYZ(lat, lon), lat: lat, lon: lon, ID: int(id)}
+		ix.pos[id] = i
+	}
+	return ix, nil
+}
@@ -0,0 +1,72 @@
+package spatial
+
+// Route orders points, given as lat, lon pairs in degrees, into a short route from the start point, which
+// visits each of them once, and returns their indexes in the order of the route along with its great-circle
+// length in meters. If roundTrip is set, the route returns to the start. The route is built with the nearest
+// neighbour heuristic and improved with 2-opt, which is close to optimal for the few dozens of points of a trip.
+func Route(startLat, startLon float64, lats, lons []float64, roundTrip bool) ([]int, float64) {
+	n := len(lats)
+	// node 0 is the start, node i+1 is the point i
+	xyz := make([][3]float64, n+1)
+	xyz[0] = toXYZ(startLat, startLon)
+	for i := range lats {
+		xyz[i+1] = toXYZ(lats[i], lons[i])
+	}
+	dist := make([][]float64, n+1)
+	for i := range dist {
+		dist[i] = make([]float64, n+1)
+		for j := range dist[i] {
+			dist[i][j] = chordToMeters(chord2(xyz[i], xyz[j]))
+		}
+	}
+
+	path := []int{0}
+	visited := make([]bool, n+1)
+	for len(path) <= n {
+		last, next := path[len(path)-1], -1
+		for j := 1; j <= n; j++ {
+			if !visited[j] && (next < 0 || dist[last][j] < dist[last][next]) {
+				next = j
+			}
+		}
+		visited[next] = true
+		path = append(path, next)
+	}
+	if roundTrip {
+		path = append(path, 0)
+	}
+
+	// 2-opt: reverse the segment path[i:k+1], while it shortens the route; the start stays first,
+	// and so does the return to it, while the last stop of an open route may move
+	end := len(path) - 1
+	if roundTrip {
+		end--
+	}
+	for improved := true; improved; {
+		improved = false
+		for i := 1; i < end; i++ {
+			for k := i + 1; k <= end; k++ {
+				delta := dist[path[i-1]][path[k]] - dist[path[i-1]][path[i]]
+				if k+1 < len(path) {
+					delta += dist[path[i]][path[k+1]] - dist[path[k]][path[k+1]]
+				}
+				if delta < -1e-6 {
+					for a, b := i, k; a < b; a, b = a+1, b-1 {
+						path[a], path[b] = path[b], path[a]
+					}
+					improved = true
+				}
+			}
+		}
+	}
+
+	order := make([]int, 0, n)
+	var length float64
+	for i := 1; i < len(path); i++ {
+		length += dist[path[i-1]][path[i]]
+		if path[i] != 0 {
+			order = append(order, path[i]-1)
+		}
+	}
+	return order, length
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/narqo/vtb-dms/spatial"
+)
+
+// spatialIndexExt is the extension of the spatial index file, saved next to the dataset.
+const spatialIndexExt = ".spatial"
+
+// loadSpatialIndex returns the spatial index of the points, reading it from path, if it was saved
+// for the same version of the dataset. Otherwise the index is built and saved into path for the
+// next start. An unreadable or stale index file isn't an error, as the index can always be rebuilt.
+func loadSpatialIndex(path, hash string, lats, lons []float64) *spatial.Index {
+	start := time.Now()
+	ix, err := readSpatialIndex(path, hash)
+	if err == nil && ix.Len() == len(lats) {
+		slog.Debug("loaded spatial index", "path", path, "points", ix.Len(), "elapsed", time.Since(start))
+		return ix
+	}
+	if err != nil && !os.IsNotExist(err) {
+		slog.Info("rebuilding spatial index", "path", path, "reason", err)
+	}
+
+	ix = spatial.New(lats, lons)
+	if err := writeSpatialIndex(path, hash, ix); err != nil {
+		slog.Warn("could not save spatial index", "path", path, "err", err)
+	}
+	return ix
+}
+
+// readSpatialIndex reads the index from the file, that starts with the line with the hash of the
+// dataset, the index was built for.
+func readSpatialIndex(path, hash string) (*spatial.Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("could not read index header: %v", err)
+	}
+	if strings.TrimSpace(line) != hash {
+		return nil, fmt.Errorf("dataset changed")
+	}
+	return spatial.ReadIndex(br)
+}
+
+func writeSpatialIndex(path, hash string, ix *spatial.Index) error {
+	var buf bytes.Buffer
+	buf.WriteString(hash + "\n")
+	if _, err := ix.WriteTo(&buf); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/enrich"
+	"github.com/narqo/vtb-dms/export"
+)
+
+// geocodeStreaming reads, geocodes and writes clinics one by one through bounded channels, so
+// the memory doesn't grow with the size of the input, e.g. of a national dataset. The input must
+// be ndjson or DMS text document, and the output a single local ndjson file, where clinics are written
+// in the order they are geocoded. The file is replaced only when the run succeeds. Clinics, which are
+// listed several times, e.g. in several sections, are written once, with the programs of the first one.
+func geocodeStreaming(inf *inputFlags, ef *exportFlags, g *geocoder, provider string, concurrency int, ov *overrides, categories *enrich.Categories, progress *progress) (*runSummary, error) {
+	targets, err := ef.targets()
+	if err != nil {
+		return nil, usageError(err)
+	}
+	if len(targets) != 1 || targets[0].Format != "ndjson" {
+		return nil, usageError(fmt.Errorf("-streaming requires a single ndjson output"))
+	}
+	if strings.HasPrefix(targets[0].Path, export.S3Scheme) {
+		return nil, usageError(fmt.Errorf("-streaming doesn't support s3 outputs"))
+	}
+	if *ef.splitBy != "" || *ef.within != "" || *ef.bbox != "" {
+		return nil, usageError(fmt.Errorf("-streaming doesn't support -split-by, -within and -bbox"))
+	}
+	filter, err := parseFilter(*ef.filter)
+	if err != nil {
+		return nil, usageError(err)
+	}
+	rules, err := ef.scrubRules()
+	if err != nil {
+		return nil, usageError(err)
+	}
+	opts, err := ef.options()
+	if err != nil {
+		return nil, inputError(err)
+	}
+	opts.Provider = provider
+	path := targets[0].Path
+	if path == "" {
+		path = "-"
+	}
+	out, err := export.NewAtomicStreamWriter(path, opts)
+	if err != nil {
+		return nil, outputError(err)
+	}
+
+	var (
+		in      = make(chan *dmsparse.Clinic, concurrency)
+		done    = make(chan *dmsparse.Clinic, concurrency)
+		readErr error
+	)
+	go func() {
+		defer close(in)
+		// only IDs are kept, so the memory grows much slower than with the clinics
+		seen := make(map[string]bool)
+		_, readErr = inf.stream(func(cc *dmsparse.Clinic) error {
+			if cc.ID != "" && seen[cc.ID] {
+				return nil
+			}
+			seen[cc.ID] = true
+			if ov != nil {
+				ov.Apply([]*dmsparse.Clinic{cc})
+			}
+			categories.Enrich(cc)
+			in <- cc
+			return nil
+		})
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for cc := range in {
+				if _, _, ok := cc.LatLon(); ok {
+					metrics.ClinicProcessed("skipped")
+				} else {
+					err := geocodeClinic(g, provider, cc)
+					progress.Add(err == nil)
+				}
+				done <- cc
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	summary := newRunSummary(nil, 0, 0, 0)
+	for cc := range done {
+		summary.add(cc)
+		if filter.Match(cc) {
+			out.Write(export.Scrub([]*dmsparse.Clinic{cc}, rules)[0])
+		}
+	}
+	progress.Finish()
+
+	if readErr != nil {
+		out.Abort()
+		return nil, inputError(readErr)
+	}
+	if err := out.Close(); err != nil {
+		return nil, outputError(err)
+	}
+	if opts.Checksum && path != "-" {
+		if err := export.WriteChecksum(path, opts.SignKey); err != nil {
+			return nil, outputError(err)
+		}
+	}
+	if err := g.Close(); err != nil {
+		return nil, outputError(fmt.Errorf("could not save geocoder cache: %v", err))
+	}
+	summary.APICalls, summary.CacheHits = g.Calls(), g.CacheHits()
+	return summary, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/narqo/vtb-dms/enrich"
+	"github.com/narqo/vtb-dms/export"
+)
+
+func runStreaming(t *testing.T, input string, args ...string) error {
+	t.Helper()
+	in := filepath.Join(t.TempDir(), "in.ndjson")
+	if err := os.WriteFile(in, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("geocode", flag.ContinueOnError)
+	inf := newInputFlags(fs, "", "ndjson")
+	ef := newExportFlags(fs)
+	if err := fs.Parse(append([]string{"-in", in}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	moscow := &staticGeocoder{Lat: 55.75, Lon: 37.61, Address: "Россия, Москва, улица Новая, 1", City: "Москва", Precision: "exact"}
+	g := &geocoder{Geocoder: moscow, provider: moscow}
+	_, err := geocodeStreaming(inf, ef, g, "static", 2, nil, enrich.NewCategories(), nil)
+	return err
+}
+
+func TestGeocodeStreaming(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "clinics.ndjson")
+	input := `{"id":"1","name":"Клиника","raw_address":"г. Москва, ул. Новая, д. 1","programs":["Поликлиника"]}
+{"id":"2","name":"Стоматология","raw_address":"г. Москва, ул. Новая, д. 1"}
+{"id":"1","name":"Клиника","raw_address":"г. Москва, ул. Новая, д. 1","programs":["Стоматология"]}
+`
+	if err := runStreaming(t, input, "-out", out, "-format", "ndjson", "-checksum"); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	clinics, err := export.ReadNDJSON(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clinics) != 2 {
+		t.Errorf("got %d clinics, want the repeated clinic written once", len(clinics))
+	}
+	if _, err := os.Stat(out + ".sha256"); err != nil {
+		t.Errorf("checksum wasn't written: %v", err)
+	}
+}
+
+func TestGeocodeStreamingKeepsFileOnError(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "clinics.ndjson")
+	const prev = `{"id":"1","name":"Клиника"}` + "\n"
+	if err := os.WriteFile(out, []byte(prev), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	input := `{"id":"2","name":"Стоматология","raw_address":"г. Москва, ул. Новая, д. 1"}
+not a clinic
+`
+	if err := runStreaming(t, input, "-out", out, "-format", "ndjson"); err == nil {
+		t.Fatal("invalid input: no error")
+	}
+	if data, _ := os.ReadFile(out); string(data) != prev {
+		t.Errorf("failed run changed the output: %q", data)
+	}
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(out), ".*"))
+	if len(matches) != 0 {
+		t.Errorf("temporary files are left: %v", matches)
+	}
+}
+
+func TestGeocodeStreamingRejectsS3(t *testing.T) {
+	err := runStreaming(t, "", "-out", "s3://bucket/clinics.ndjson", "-format", "ndjson")
+	if err == nil || !strings.Contains(err.Error(), "s3") {
+		t.Errorf("s3 output: got %v, want error", err)
+	}
+}
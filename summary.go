@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/validate"
+)
+
+// runSummary is the statistics of a single run.
+type runSummary struct {
+	Parsed    int            `json:"parsed"`
+	Geocoded  int            `json:"geocoded"`
+	Failed    int            `json:"failed"`
+	APICalls  int64          `json:"api_calls"`
+	CacheHits int64          `json:"cache_hits"`
+	Cities    map[string]int `json:"cities"`
+	Precision map[string]int `json:"precision"`
+	// Collapsed are groups of clinics with different addresses, geocoded to the same point.
+	Collapsed []validate.CollapsedGroup `json:"collapsed,omitempty"`
+	// Previous is the number of clinics in the previous dataset, Vanished are the ones missing now.
+	Previous int              `json:"previous,omitempty"`
+	Vanished []vanishedClinic `json:"vanished,omitempty"`
+	WallTime float64          `json:"wall_time_sec"`
+	Version  string           `json:"version"`
+}
+
+func newRunSummary(clinics []*dmsparse.Clinic, apiCalls, cacheHits int64, wallTime time.Duration) *runSummary {
+	s := &runSummary{
+		APICalls:  apiCalls,
+		CacheHits: cacheHits,
+		Cities:    make(map[string]int),
+		Precision: make(map[string]int),
+		WallTime:  wallTime.Seconds(),
+		Version:   buildVersion(),
+	}
+	for _, cc := range clinics {
+		s.add(cc)
+	}
+	s.Parsed = len(clinics)
+	s.Collapsed = validate.Collapsed(clinics, validate.CollapseDistance, validate.CollapseMinAddresses)
+	return s
+}
+
+// add counts a clinic in the summary.
+func (s *runSummary) add(cc *dmsparse.Clinic) {
+	s.Parsed++
+	if _, _, ok := cc.LatLon(); ok {
+		s.Geocoded++
+	} else {
+		s.Failed++
+	}
+	city := cc.City
+	if city == "" {
+		city = "unknown"
+	}
+	s.Cities[city]++
+	if cc.Precision != "" {
+		s.Precision[cc.Precision]++
+	}
+}
+
+func (s *runSummary) Print(w io.Writer) {
+	if len(s.Vanished) > 0 {
+		fmt.Fprintf(w, "WARNING: %d of %d clinics of the previous dataset vanished:\n", len(s.Vanished), s.Previous)
+		for _, cc := range s.Vanished {
+			fmt.Fprintf(w, "  %s %s, %s\n", cc.ID, cc.Name, cc.RawAddress)
+		}
+	}
+	fmt.Fprintf(w, "parsed %d, geocoded %d, failed %d, api calls %d, cache hits %d, wall time %.1fs\n",
+		s.Parsed, s.Geocoded, s.Failed, s.APICalls, s.CacheHits, s.WallTime)
+	printCounts(w, "cities", s.Cities)
+	printCounts(w, "precision", s.Precision)
+	if len(s.Collapsed) > 0 {
+		fmt.Fprintf(w, "collapsed points, re-geocode or override these clinics:\n")
+		for _, g := range s.Collapsed {
+			fmt.Fprintf(w, "  %.6f, %.6f: %d clinics\n", g.Lat, g.Lon, len(g.Clinics))
+			for _, cc := range g.Clinics {
+				fmt.Fprintf(w, "    %s %s\n", cc.ID, cc.RawAddress)
+			}
+		}
+	}
+}
+
+func (s *runSummary) WriteFile(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// printCounts prints counts sorted by value in descending order.
+func printCounts(w io.Writer, title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	fmt.Fprintf(w, "%s:\n", title)
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %s: %d\n", k, counts[k])
+	}
+}
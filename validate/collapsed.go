@@ -0,0 +1,79 @@
+package validate
+
+import (
+	"fmt"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/spatial"
+)
+
+// Defaults of collapsed points detection.
+const (
+	// CollapseDistance is the distance, within which points are considered identical, in meters.
+	CollapseDistance = 5
+	// CollapseMinAddresses is the number of distinct addresses at the same point, from which the point
+	// is considered collapsed. Several clinics at the same address, e.g. departments of a medical
+	// center, are legitimate.
+	CollapseMinAddresses = 3
+)
+
+// CollapsedGroup is a group of clinics with different addresses, geocoded to (nearly) the same point,
+// which is the usual symptom of the geocoder falling back to the centroid of the city or the street.
+type CollapsedGroup struct {
+	Lat     float64            `json:"lat"`
+	Lon     float64            `json:"lon"`
+	Clinics []*dmsparse.Clinic `json:"-"`
+	IDs     []string           `json:"ids"`
+}
+
+// Collapsed returns groups of clinics, whose points are within distance meters from each other,
+// and that have at least minAddresses distinct addresses.
+func Collapsed(clinics []*dmsparse.Clinic, distance float64, minAddresses int) []CollapsedGroup {
+	var (
+		lats, lons []float64
+		located    []*dmsparse.Clinic
+	)
+	for _, cc := range clinics {
+		if lat, lon, ok := cc.LatLon(); ok {
+			lats, lons = append(lats, lat), append(lons, lon)
+			located = append(located, cc)
+		}
+	}
+	ix := spatial.New(lats, lons)
+
+	var (
+		groups  []CollapsedGroup
+		grouped = make([]bool, len(located))
+	)
+	for i := range located {
+		if grouped[i] {
+			continue
+		}
+		g := CollapsedGroup{Lat: lats[i], Lon: lons[i]}
+		addresses := make(map[string]bool)
+		for _, h := range ix.Within(lats[i], lons[i], distance) {
+			if grouped[h.ID] {
+				continue
+			}
+			grouped[h.ID] = true
+			g.Clinics = append(g.Clinics, located[h.ID])
+			g.IDs = append(g.IDs, located[h.ID].ID)
+			addresses[dmsparse.AddressKey(located[h.ID].RawAddress)] = true
+		}
+		if len(addresses) >= minAddresses {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// checkCollapsed reports clinics of collapsed groups.
+func checkCollapsed(clinics []*dmsparse.Clinic) map[*dmsparse.Clinic]string {
+	issues := make(map[*dmsparse.Clinic]string)
+	for _, g := range Collapsed(clinics, CollapseDistance, CollapseMinAddresses) {
+		for _, cc := range g.Clinics {
+			issues[cc] = fmt.Sprintf("%d clinics share point %.6f, %.6f", len(g.Clinics), g.Lat, g.Lon)
+		}
+	}
+	return issues
+}
@@ -0,0 +1,212 @@
+package validate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+	"github.com/narqo/vtb-dms/geocode"
+)
+
+// Defaults of near-duplicates detection.
+const (
+	// DuplicateSimilarity is the similarity of addresses, from which clinics are considered duplicates.
+	DuplicateSimilarity = 0.85
+	// DuplicateDistance is the distance between points of duplicates, in meters, beyond which they
+	// are considered different clinics, even if their addresses are similar.
+	DuplicateDistance = 150
+	// duplicateNameSimilarity is the similarity of names, below which clinics at the same address,
+	// e.g. different medical centers in one building, aren't duplicates.
+	duplicateNameSimilarity = 0.5
+	// maxTokenFrequency is the number of clinics, from which a word, e.g. the name of the city, is
+	// too common to find duplicate candidates by.
+	maxTokenFrequency = 50
+)
+
+// Duplicate is a suggestion to merge clinic Merge into clinic Keep, which is the more complete of the two.
+type Duplicate struct {
+	Keep       *dmsparse.Clinic `json:"-"`
+	Merge      *dmsparse.Clinic `json:"-"`
+	KeepID     string           `json:"keep_id"`
+	MergeID    string           `json:"merge_id"`
+	Similarity float64          `json:"similarity"`
+	// Distance is the distance between points of the clinics in meters, or -1 if either isn't geocoded.
+	Distance float64 `json:"distance"`
+}
+
+// Duplicates returns pairs of clinics with different IDs, whose normalized addresses are at least
+// minSimilarity similar and whose points, if both are geocoded, are within distance meters, sorted
+// by similarity. Similarity is the share of words the addresses have in common, where words of
+// letters match with a typo. Names must be similar too, so different clinics in one building aren't
+// reported.
+func Duplicates(clinics []*dmsparse.Clinic, minSimilarity, distance float64) []Duplicate {
+	var (
+		addresses = make([][]string, len(clinics))
+		names     = make([][]string, len(clinics))
+		byWord    = make(map[string][]int)
+	)
+	for i, cc := range clinics {
+		addresses[i] = similarityWords(cc.RawAddress)
+		names[i] = similarityWords(cc.Name)
+		for _, w := range addresses[i] {
+			byWord[w] = append(byWord[w], i)
+		}
+	}
+
+	var dups []Duplicate
+	for i, cc := range clinics {
+		// candidates share at least one distinctive word with the clinic, e.g. the street or the house number
+		seen := make(map[int]bool)
+		for _, w := range addresses[i] {
+			if len(byWord[w]) > maxTokenFrequency {
+				continue
+			}
+			for _, j := range byWord[w] {
+				if j <= i || seen[j] {
+					continue
+				}
+				seen[j] = true
+				other := clinics[j]
+				if other.ID == cc.ID {
+					continue
+				}
+				sim := wordSimilarity(addresses[i], addresses[j])
+				if sim < minSimilarity || wordSimilarity(names[i], names[j]) < duplicateNameSimilarity {
+					continue
+				}
+				d := pointDistance(cc, other)
+				if d > distance {
+					continue
+				}
+				keep, merge := cc, other
+				if completeness(merge) > completeness(keep) {
+					keep, merge = merge, keep
+				}
+				dups = append(dups, Duplicate{keep, merge, keep.ID, merge.ID, round2(sim), d})
+			}
+		}
+	}
+	sort.SliceStable(dups, func(i, j int) bool {
+		return dups[i].Similarity > dups[j].Similarity
+	})
+	return dups
+}
+
+// checkDuplicates reports clinics, that should be merged into other clinics.
+func checkDuplicates(clinics []*dmsparse.Clinic) map[*dmsparse.Clinic]string {
+	issues := make(map[*dmsparse.Clinic]string)
+	for _, d := range Duplicates(clinics, DuplicateSimilarity, DuplicateDistance) {
+		if _, ok := issues[d.Merge]; !ok {
+			issues[d.Merge] = fmt.Sprintf("possibly the same clinic as %s (similarity %.2f)", d.KeepID, d.Similarity)
+		}
+	}
+	return issues
+}
+
+// similarityWords returns lower-case words and numbers of s, except for names of address parts,
+// like "ул" or "улица", and distances to metro stations, which are spelled differently.
+func similarityWords(s string) []string {
+	// e.g. "м.Автозаводская (240 м)"
+	if n := strings.Index(s, "("); n > 0 {
+		s = s[:n]
+	}
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if stopWords[w] {
+			continue
+		}
+		words = append(words, strings.ReplaceAll(w, "ё", "е"))
+	}
+	return words
+}
+
+var stopWords = map[string]bool{
+	"г": true, "город": true, "ул": true, "улица": true, "д": true, "дом": true, "м": true, "метро": true,
+	"стр": true, "строение": true, "корп": true, "корпус": true, "к": true, "пр": true, "т": true,
+	"проспект": true, "пер": true, "переулок": true, "ш": true, "шоссе": true, "б": true, "р": true,
+	"бульвар": true, "обл": true, "область": true, "пл": true, "площадь": true, "наб": true,
+	"набережная": true, "россия": true, "ооо": true, "оао": true, "зао": true, "ао": true,
+}
+
+// wordSimilarity returns the Sørensen–Dice coefficient of the word sets, where words of at least
+// 4 letters match, if they differ by a single edit, and numbers must be equal.
+func wordSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	var (
+		matched int
+		used    = make([]bool, len(b))
+	)
+	for _, wa := range a {
+		for j, wb := range b {
+			if !used[j] && wordsMatch(wa, wb) {
+				used[j] = true
+				matched++
+				break
+			}
+		}
+	}
+	return float64(2*matched) / float64(len(a)+len(b))
+}
+
+func wordsMatch(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < 4 || len(rb) < 4 || unicode.IsDigit(ra[0]) || unicode.IsDigit(rb[0]) {
+		return false
+	}
+	return editDistance(ra, rb) <= 1
+}
+
+// editDistance returns Levenshtein distance between a and b.
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// pointDistance returns the distance between points of the clinics in meters, or -1 if either isn't geocoded.
+func pointDistance(a, b *dmsparse.Clinic) float64 {
+	lat1, lon1, ok1 := a.LatLon()
+	lat2, lon2, ok2 := b.LatLon()
+	if !ok1 || !ok2 {
+		return -1
+	}
+	return geocode.Distance(lat1, lon1, lat2, lon2)
+}
+
+// completeness scores how much is known about the clinic, so the duplicate with more data is kept.
+func completeness(cc *dmsparse.Clinic) float64 {
+	var score float64
+	if _, _, ok := cc.LatLon(); ok {
+		score += 2
+	}
+	score += cc.Confidence
+	score += float64(len(dmsparse.SplitPhones(cc.Phone)))
+	return score
+}
+
+func round2(f float64) float64 {
+	return float64(int(f*100+0.5)) / 100
+}
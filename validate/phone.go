@@ -0,0 +1,110 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// areaCodes are the area codes of cities and regions, keyed like regions. A phone matches the city,
+// if its code starts with one of the city's codes.
+var areaCodes = map[string][]string{
+	"москва":                {"495", "499"},
+	"московская область":    {"495", "496", "498", "499"},
+	"санкт-петербург":       {"812"},
+	"ленинградская область": {"812", "813"},
+	"новосибирск":           {"383"},
+	"екатеринбург":          {"343"},
+	"казань":                {"843"},
+	"нижний новгород":       {"831"},
+	"самара":                {"846"},
+	"ростов-на-дону":        {"863"},
+	"краснодар":             {"861"},
+	"обнинск":               {"484"},
+}
+
+// NormalizePhone returns the phone as +7 followed by 10 digits of the national number. The phone
+// may be prefixed with the country code 7 or the trunk prefix 8, extensions, e.g. "доб. 123", are
+// dropped. The national number must start with a geographic (3, 4, 8) or a mobile (9) code.
+func NormalizePhone(phone string) (string, error) {
+	if n := strings.Index(phone, "доб"); n >= 0 {
+		phone = phone[:n]
+	}
+	var digits []byte
+	for _, r := range phone {
+		if unicode.IsDigit(r) && r < unicode.MaxASCII {
+			digits = append(digits, byte(r))
+		}
+	}
+	switch {
+	case len(digits) == 11 && (digits[0] == '7' || digits[0] == '8'):
+		digits = digits[1:]
+	case len(digits) == 10:
+	case len(digits) < 10:
+		return "", errors.New("too few digits, no area code")
+	default:
+		return "", errors.New("too many digits")
+	}
+	switch digits[0] {
+	case '3', '4', '8', '9':
+	default:
+		return "", fmt.Errorf("no Russian numbers start with %c", digits[0])
+	}
+	return "+7" + string(digits), nil
+}
+
+func checkPhones(cc *dmsparse.Clinic) string {
+	phones := dmsparse.SplitPhones(cc.Phone)
+	if len(phones) == 0 {
+		return "no phone"
+	}
+	var invalid []string
+	for _, p := range phones {
+		if _, err := NormalizePhone(p); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s (%v)", p, err))
+		}
+	}
+	if len(invalid) > 0 {
+		return "invalid phone " + strings.Join(invalid, ", ")
+	}
+	return ""
+}
+
+// checkAreaCode reports geographic phones, whose area code doesn't match the city of the clinic,
+// which is usually a parsing error. Mobile and toll-free numbers match any city.
+func checkAreaCode(cc *dmsparse.Clinic) string {
+	city, _, ok := ExpectedRegion(cc.RawAddress)
+	if !ok {
+		city = cc.City
+	}
+	codes, ok := areaCodes[strings.ToLower(city)]
+	if !ok {
+		return ""
+	}
+	var mismatched []string
+	for _, p := range dmsparse.SplitPhones(cc.Phone) {
+		n, err := NormalizePhone(p)
+		if err != nil || n[2] == '9' || n[2] == '8' {
+			continue
+		}
+		if !hasAreaCode(n[2:], codes) {
+			mismatched = append(mismatched, p)
+		}
+	}
+	if len(mismatched) > 0 {
+		return fmt.Sprintf("area code of %s doesn't match %s", strings.Join(mismatched, ", "), city)
+	}
+	return ""
+}
+
+func hasAreaCode(number string, codes []string) bool {
+	for _, c := range codes {
+		if strings.HasPrefix(number, c) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// Country is the bounding box minLat, minLon, maxLat, maxLon, where all clinics must be.
+// If minLon is greater than maxLon, the box crosses the antimeridian. The default is Russia.
+var Country = [4]float64{41.1, 19.6, 81.9, -168.9}
+
+// regionMargin pads region bounding boxes, in degrees, as the boxes are approximate.
+const regionMargin = 0.05
+
+// regions are the approximate bounding boxes of cities and regions, clinics are claimed to be in.
+var regions = map[string][4]float64{
+	"москва":                {55.14, 36.80, 56.02, 37.97},
+	"московская область":    {54.25, 35.14, 56.96, 40.21},
+	"санкт-петербург":       {59.63, 29.42, 60.25, 30.76},
+	"ленинградская область": {58.41, 27.81, 61.33, 35.70},
+	"новосибирск":           {54.80, 82.75, 55.20, 83.20},
+	"екатеринбург":          {56.65, 60.35, 57.00, 60.85},
+	"казань":                {55.60, 48.80, 55.95, 49.40},
+	"нижний новгород":       {56.15, 43.70, 56.45, 44.20},
+	"самара":                {53.10, 49.90, 53.45, 50.40},
+	"ростов-на-дону":        {47.15, 39.50, 47.37, 39.86},
+	"краснодар":             {44.95, 38.85, 45.20, 39.20},
+	"обнинск":               {55.05, 36.50, 55.15, 36.70},
+}
+
+// regionRe matches the region in the address, e.g. "Московская область" or "Ленинградская обл.".
+var regionRe = regexp.MustCompile(`([А-ЯЁ][а-яё]+ская)\s+обл`)
+
+// ExpectedRegion returns the name and the bounding box of the city or the region, claimed in the
+// address, if it's known.
+func ExpectedRegion(address string) (name string, bbox [4]float64, ok bool) {
+	if city := ClaimedCity(address); city != "" {
+		name = strings.ToLower(city)
+		if bbox, ok = regions[name]; ok {
+			return city, bbox, true
+		}
+	}
+	if m := regionRe.FindStringSubmatch(address); m != nil {
+		name = strings.ToLower(m[1]) + " область"
+		if bbox, ok = regions[name]; ok {
+			return m[1] + " область", bbox, true
+		}
+	}
+	return "", bbox, false
+}
+
+// CheckRegion returns the description of the problem, if clinic's point is outside the country,
+// or outside the city or the region, claimed in its address. It returns empty string otherwise.
+func CheckRegion(cc *dmsparse.Clinic) string {
+	lat, lon, ok := cc.LatLon()
+	if !ok {
+		return ""
+	}
+	if !inBox(Country, lat, lon, 0) {
+		return fmt.Sprintf("point %.6f, %.6f is outside the country", lat, lon)
+	}
+	if name, bbox, ok := ExpectedRegion(cc.RawAddress); ok && !inBox(bbox, lat, lon, regionMargin) {
+		return fmt.Sprintf("point %.6f, %.6f is outside %s", lat, lon, name)
+	}
+	return ""
+}
+
+func inBox(bbox [4]float64, lat, lon, margin float64) bool {
+	if lat < bbox[0]-margin || lat > bbox[2]+margin {
+		return false
+	}
+	if bbox[1] > bbox[3] {
+		// the box crosses the antimeridian
+		return lon >= bbox[1]-margin || lon <= bbox[3]+margin
+	}
+	return lon >= bbox[1]-margin && lon <= bbox[3]+margin
+}
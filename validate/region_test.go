@@ -0,0 +1,49 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+func TestExpectedRegion(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"г. Москва ул.Новая, д. 1", "Москва"},
+		{"г. Москва пр-т Мира, д. 2", "Москва"},
+		{"117556, Россия, Москва, Варшавское ш., 95", "Москва"},
+		{"г. Нижний Новгород, ул. Горького, 10", "Нижний Новгород"},
+		{"г. Ростов-на-Дону, ул. Садовая, 5", "Ростов-на-Дону"},
+		{"Московская обл., г. Балашиха, ул. Ленина, 3", "Московская область"},
+		{"Россия, ул. Новая, д. 1", ""},
+	}
+	for _, tt := range tests {
+		name, _, ok := ExpectedRegion(tt.address)
+		if name != tt.want || ok != (tt.want != "") {
+			t.Errorf("ExpectedRegion(%q) = %q, %v, want %q", tt.address, name, ok, tt.want)
+		}
+	}
+}
+
+func TestCheckRegion(t *testing.T) {
+	tests := []struct {
+		address  string
+		lat, lon float64
+		problem  bool
+	}{
+		{"117556, Россия, Москва, Варшавское ш., 95", 55.65, 37.62, false},
+		{"117556, Россия, Москва, Варшавское ш., 95", 55.03, 82.92, true},
+		{"г. Москва ул.Новая, д. 1", 55.03, 82.92, true},
+		{"г. Москва пр-т Мира, д. 2", 55.78, 37.63, false},
+		{"Московская обл., г. Балашиха, ул. Ленина, 3", 55.80, 37.94, false},
+		{"г. Москва, ул. Новая, д. 1", 48.85, 2.35, true}, // outside the country
+	}
+	for _, tt := range tests {
+		cc := &dmsparse.Clinic{RawAddress: tt.address, Points: []float64{tt.lat, tt.lon}}
+		if got := CheckRegion(cc); (got != "") != tt.problem {
+			t.Errorf("CheckRegion(%q at %v, %v) = %q, want problem %v", tt.address, tt.lat, tt.lon, got, tt.problem)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Package validate checks a dataset of clinics against a set of rules.
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// Severity is the severity of a rule violation.
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity parses severity name: info, warning or error.
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if n == name {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity: %q", name)
+}
+
+// Rule is a check of a single clinic, or of the whole dataset.
+type Rule struct {
+	Name        string
+	Severity    Severity
+	Description string
+	// Check returns the description of clinic's problem, or empty string if the clinic passes the rule.
+	Check func(cc *dmsparse.Clinic) string
+	// CheckAll, if set instead of Check, returns the problems of clinics, that depend on other clinics.
+	CheckAll func(clinics []*dmsparse.Clinic) map[*dmsparse.Clinic]string
+}
+
+// Issue is a violation of a rule by a clinic.
+type Issue struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Message  string   `json:"message"`
+}
+
+// RuleResult is the number of clinics, that violated a rule.
+type RuleResult struct {
+	Name        string   `json:"name"`
+	Severity    Severity `json:"severity"`
+	Description string   `json:"description"`
+	Violations  int      `json:"violations"`
+}
+
+// Report is the result of checking a dataset.
+type Report struct {
+	Clinics int          `json:"clinics"`
+	Rules   []RuleResult `json:"rules"`
+	Issues  []Issue      `json:"issues"`
+}
+
+// Run checks every clinic against every rule.
+func Run(clinics []*dmsparse.Clinic, rules []Rule) *Report {
+	r := &Report{
+		Clinics: len(clinics),
+		Rules:   make([]RuleResult, len(rules)),
+		Issues:  []Issue{},
+	}
+	dataset := make([]map[*dmsparse.Clinic]string, len(rules))
+	for i, rule := range rules {
+		r.Rules[i] = RuleResult{Name: rule.Name, Severity: rule.Severity, Description: rule.Description}
+		if rule.CheckAll != nil {
+			dataset[i] = rule.CheckAll(clinics)
+		}
+	}
+	for _, cc := range clinics {
+		for i, rule := range rules {
+			var msg string
+			if rule.CheckAll != nil {
+				msg = dataset[i][cc]
+			} else {
+				msg = rule.Check(cc)
+			}
+			if msg == "" {
+				continue
+			}
+			r.Rules[i].Violations++
+			r.Issues = append(r.Issues, Issue{
+				Rule:     rule.Name,
+				Severity: rule.Severity,
+				ID:       cc.ID,
+				Name:     cc.Name,
+				Message:  msg,
+			})
+		}
+	}
+	return r
+}
+
+// Count returns the number of issues of at least the given severity.
+func (r *Report) Count(min Severity) (n int) {
+	for _, is := range r.Issues {
+		if is.Severity >= min {
+			n++
+		}
+	}
+	return n
+}
+
+// Rules are the default rules.
+var Rules = []Rule{
+	{"empty-name", Error, "clinic has no name", checkName, nil},
+	{"empty-address", Error, "clinic has no address", checkAddress, nil},
+	{"no-house-number", Warning, "address has no house number", checkHouseNumber, nil},
+	{"invalid-phone", Warning, "phone isn't a valid Russian number", checkPhones, nil},
+	{"phone-area-code", Warning, "phone's area code doesn't match the city", checkAreaCode, nil},
+	{"no-coordinates", Error, "clinic wasn't geocoded", checkCoordinates, nil},
+	{"outside-city", Error, "point is outside the city, claimed in the address", checkCity, nil},
+	{"outside-region", Error, "point is outside the country, or the city or the region of the address", CheckRegion, nil},
+	{"collapsed-point", Warning, "clinics with different addresses share the same point", nil, checkCollapsed},
+	{"near-duplicate", Warning, "clinic is likely a duplicate of another clinic with a differently spelled address", nil, checkDuplicates},
+	{"license-lapsed", Warning, "clinic's medical license was terminated or suspended", checkLicense, nil},
+}
+
+func checkName(cc *dmsparse.Clinic) string {
+	if strings.TrimSpace(cc.Name) == "" {
+		return "empty name"
+	}
+	return ""
+}
+
+func checkAddress(cc *dmsparse.Clinic) string {
+	if strings.TrimSpace(cc.RawAddress) == "" {
+		return "empty address"
+	}
+	return ""
+}
+
+// houseNumberRe matches a house number, e.g. "д. 10", "дом 7", "вл.3", "корп. 1106" in Zelenograd,
+// or a bare ", 15/1" or ", 13-а" part of address, optionally followed by the metro station.
+var houseNumberRe = regexp.MustCompile(`(?i)(?:^|[\s,.])(?:д|дом|вл|влд|владение|корп|корпус)\.?\s*\d|,\s*\d+(?:-?[а-я])?(?:/\d+)?\s*(?:,|$|м\.)`)
+
+func checkHouseNumber(cc *dmsparse.Clinic) string {
+	if cc.RawAddress == "" || houseNumberRe.MatchString(cc.RawAddress) {
+		return ""
+	}
+	return fmt.Sprintf("no house number in %q", cc.RawAddress)
+}
+
+func checkLicense(cc *dmsparse.Clinic) string {
+	if cc.License == nil || cc.License.Active {
+		return ""
+	}
+	return fmt.Sprintf("license %s is %s", cc.License.Number, cc.License.Status)
+}
+
+func checkCoordinates(cc *dmsparse.Clinic) string {
+	if _, _, ok := cc.LatLon(); !ok {
+		return "no coordinates"
+	}
+	return ""
+}
+
+func checkCity(cc *dmsparse.Clinic) string {
+	if _, _, ok := cc.LatLon(); !ok || cc.City == "" {
+		return ""
+	}
+	claimed := ClaimedCity(cc.RawAddress)
+	if claimed == "" || sameCity(claimed, cc.City) {
+		return ""
+	}
+	return fmt.Sprintf("address claims %s, but point is in %s", claimed, cc.City)
+}
+
+var (
+	// cityPrefixRe matches the city marked with "г.", e.g. "г. Москва", "г.Санкт-Петербург" or "г. Нижний Новгород".
+	// Words of the name are capitalised, except for the hyphenated ones, e.g. "Ростов-на-Дону", so the street,
+	// which follows the city without a comma, e.g. "г. Москва ул.Новая", isn't taken for a part of it.
+	cityPrefixRe = regexp.MustCompile(`(?:^|[\s,])г\.\s*([А-ЯЁ][а-яё]+(?:-[А-ЯЁа-яё][а-яё]+| [А-ЯЁ][а-яё]+)*)`)
+	// cityPartRe matches address part, that is a bare city name, e.g. "Москва".
+	cityPartRe = regexp.MustCompile(`^[А-ЯЁ][а-яё]+(?:-[А-ЯЁа-яё][а-яё]+| [А-ЯЁ][а-яё]+)*$`)
+)
+
+// countries are the names of the country, that addresses may start with.
+var countries = []string{"россия", "рф", "российская федерация"}
+
+// ClaimedCity returns the city, claimed in the raw address: either marked with "г." or the first
+// part of the address, that follows the optional postal code and country, e.g. "117556, Россия, Москва".
+// It returns empty string, if the address has no city.
+func ClaimedCity(address string) string {
+	if m := cityPrefixRe.FindStringSubmatch(address); m != nil {
+		return m[1]
+	}
+	for _, part := range strings.Split(address, ",") {
+		part = strings.TrimSpace(part)
+		if isPostalCode(part) || slices.Contains(countries, strings.ToLower(part)) {
+			continue
+		}
+		if cityPartRe.MatchString(part) {
+			return part
+		}
+		return ""
+	}
+	return ""
+}
+
+func isPostalCode(s string) bool {
+	if len(s) != 6 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func sameCity(a, b string) bool {
+	norm := func(s string) string {
+		return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "ё", "е")
+	}
+	return norm(a) == norm(b)
+}
@@ -0,0 +1,31 @@
+package validate
+
+import "testing"
+
+func TestClaimedCity(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"г. Москва, ул. Новая, д. 1", "Москва"},
+		{"г.Санкт-Петербург, Невский пр., 1", "Санкт-Петербург"},
+		{"г. Ростов-на-Дону, ул. Садовая, 5", "Ростов-на-Дону"},
+		{"г. Нижний Новгород, ул. Горького, 10", "Нижний Новгород"},
+		{"г. Москва ул.Новая, д. 1", "Москва"},
+		{"г. Москва пр-т Мира, д. 2", "Москва"},
+		{"Московская обл., г. Химки, ул. Ленина, 3", "Химки"},
+		{"Москва, ул. Новая, д. 1", "Москва"},
+		{"117556, Москва, Варшавское ш., 95", "Москва"},
+		{"117556, Россия, Москва, Варшавское ш., 95", "Москва"},
+		{"Россия, 117556, Москва, Варшавское ш., 95", "Москва"},
+		{"РФ, Казань, ул. Баумана, 1", "Казань"},
+		{"ул. Новая, д. 1", ""},
+		{"117556, ул. Новая, д. 1", ""},
+		{"Россия", ""},
+	}
+	for _, tt := range tests {
+		if got := ClaimedCity(tt.address); got != tt.want {
+			t.Errorf("ClaimedCity(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
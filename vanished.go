@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/narqo/vtb-dms/dmsparse"
+)
+
+// vanishedClinics returns clinics of the previous dataset, that are missing in clinics: there is
+// neither a clinic with the same ID, nor with the same address, i.e. the clinic wasn't just renamed.
+func vanishedClinics(prev, clinics []*dmsparse.Clinic) []*dmsparse.Clinic {
+	var (
+		ids       = make(map[string]bool, len(clinics))
+		addresses = make(map[string]bool, len(clinics))
+		vanished  []*dmsparse.Clinic
+	)
+	for _, cc := range clinics {
+		ids[cc.ID] = true
+		addresses[dmsparse.AddressKey(cc.RawAddress)] = true
+	}
+	for _, cc := range prev {
+		if !ids[cc.ID] && !addresses[dmsparse.AddressKey(cc.RawAddress)] {
+			vanished = append(vanished, cc)
+		}
+	}
+	return vanished
+}
+
+// checkVanished records clinics, that vanished since the previous dataset, in the summary, and
+// returns an error, if there are more of them, than the policy allows.
+func checkVanished(s *runSummary, prev, clinics []*dmsparse.Clinic, policy failurePolicy) error {
+	vanished := vanishedClinics(prev, clinics)
+	s.Previous = len(prev)
+	for _, cc := range vanished {
+		s.Vanished = append(s.Vanished, vanishedClinic{cc.ID, cc.Name, cc.RawAddress})
+	}
+	if policy.Exceeded(len(vanished), len(prev)) {
+		return &exitError{exitVanished, fmt.Errorf("%d of %d clinics of the previous dataset vanished, more than max vanished allow", len(vanished), len(prev))}
+	}
+	return nil
+}
+
+type vanishedClinic struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	RawAddress string `json:"raw_address"`
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"runtime/debug"
+)
+
+// version and commit are set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse --short HEAD)"
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// buildVersion returns the version of the binary, e.g. "v1.2.0 (abc1234)". If commit wasn't set
+// at build time, it's taken from VCS info, which go build stamps into the binary.
+func buildVersion() string {
+	rev, dirty := commit, false
+	if info, ok := debug.ReadBuildInfo(); ok && rev == "" {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				rev = s.Value
+				if len(rev) > 12 {
+					rev = rev[:12]
+				}
+			case "vcs.modified":
+				dirty = s.Value == "true"
+			}
+		}
+	}
+	if rev == "" {
+		return version
+	}
+	if dirty {
+		rev += "-dirty"
+	}
+	return version + " (" + rev + ")"
+}
+
+// generator identifies the tool build in output metadata.
+func generator() string {
+	return "gen_points " + buildVersion()
+}
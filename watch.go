@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// watch calls fn, and calls it again each time any of the files at paths changes. Directories are
+// watched recursively. Errors of fn are logged rather than returned, so a broken input doesn't stop
+// watching; fixing the input triggers the next run.
+//
+// Files are polled every interval. A change is picked up, once files stay unchanged for an interval,
+// so a file saved in several writes triggers a single run.
+func watch(paths []string, interval time.Duration, fn func() error) {
+	paths = slices.DeleteFunc(slices.Clone(paths), func(p string) bool { return p == "" })
+	state := snapshot(paths)
+	for {
+		if err := fn(); err != nil {
+			slog.Error("run failed", "err", err)
+		}
+		slog.Info("watching for changes", "paths", paths)
+
+		for {
+			time.Sleep(interval)
+			next := snapshot(paths)
+			if maps.Equal(state, next) {
+				continue
+			}
+			for {
+				state = next
+				time.Sleep(interval)
+				if next = snapshot(paths); maps.Equal(state, next) {
+					break
+				}
+			}
+			break
+		}
+		slog.Info("input changed, running again")
+	}
+}
+
+// fileState is the modification time and the size of a file, that change when the file is written.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot returns the state of the files at paths. Missing files are omitted, so their creation
+// is noticed as a change too.
+func snapshot(paths []string) map[string]fileState {
+	state := make(map[string]fileState)
+	for _, path := range paths {
+		filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if fi, err := os.Stat(p); err == nil {
+				state[p] = fileState{fi.ModTime(), fi.Size()}
+			}
+			return nil
+		})
+	}
+	return state
+}